@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/document/manager"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/KyleBrandon/scriptoria/pkg/server"
+	"github.com/KyleBrandon/scriptoria/pkg/utils"
+)
+
+// app holds what the subcommands share, the configuration and the database
+type app struct {
+	ctx     context.Context
+	config  config.Config
+	db      *sql.DB
+	queries *database.Queries
+}
+
+// newFlagSet will create the flags for a subcommand with the flags every subcommand accepts
+func newFlagSet(name, usage string, logLevel *string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flags.StringVar(logLevel, "log_level", "info", "The log level to run the command at")
+
+	return flags
+}
+
+// parseArgs will parse the flags wherever they are in the arguments and return the positional arguments,
+// so "process scan.pdf --bundle notes" and "process --bundle notes scan.pdf" are the same.
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	positional := make([]string, 0)
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// configureLogger will send the log to stderr so it does not mix with the command output, in the LOG_FORMAT the
+// server uses
+func configureLogger(logLevel string) {
+	level, err := utils.ParseLogLevel(logLevel)
+	if err != nil {
+		level = config.DefaultLogLevel
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	handler, err := logging.NewHandler(os.Stderr, os.Getenv("LOG_FORMAT"), opts)
+	if err != nil {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+
+	slog.SetDefault(slog.New(handler))
+}
+
+// configFileLocation will return the configuration file the server would use
+func configFileLocation() string {
+	location := os.Getenv("CONFIG_FILE_LOCATION")
+	if len(location) == 0 {
+		location = server.DEFAULT_CONFIG_FILE_LOCATION
+	}
+
+	return location
+}
+
+// newApp will load the configuration and open the database
+func newApp(ctx context.Context) (*app, error) {
+	db, err := openDatabase()
+	if err != nil {
+		return nil, err
+	}
+
+	location := configFileLocation()
+	cfg, err := config.Load(location, manager.Registry())
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", location, err)
+	}
+
+	return &app{
+		ctx:     ctx,
+		config:  cfg,
+		db:      db,
+		queries: database.New(db),
+	}, nil
+}
+
+func (a *app) Close() {
+	a.db.Close()
+}
+
+// newDocumentManager will create a document manager that reads from the given source store.  Nothing is
+// watched, documents are only processed when the command hands them to the manager.
+func (a *app) newDocumentManager(sourceStore string) (*manager.DocumentManager, error) {
+	cfg := a.config
+	cfg.SourceStore = sourceStore
+
+	return manager.New(a.ctx, a.queries, cfg, nil)
+}
+
+// enabledBundles returns the bundles in the database that documents are processed for
+func (a *app) enabledBundles() ([]config.StorageBundle, error) {
+	rows, err := a.queries.ListEnabledBundles(a.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bundles := make([]config.StorageBundle, 0, len(rows))
+	for _, b := range rows {
+		bundles = append(bundles, config.StorageBundle{
+			Name:                  b.Name,
+			SourceFolder:          b.SourceFolder,
+			ArchiveFolder:         b.ArchiveFolder,
+			DestAttachmentsFolder: b.DestAttachmentsFolder,
+			DestNotesFolder:       b.DestNotesFolder,
+		})
+	}
+
+	return bundles, nil
+}
+
+// findBundle will find the bundle by its name or its source folder
+func findBundle(bundles []config.StorageBundle, name string) (config.StorageBundle, error) {
+	for _, b := range bundles {
+		if b.Name == name || b.SourceFolder == name {
+			return b, nil
+		}
+	}
+
+	names := make([]string, 0, len(bundles))
+	for _, b := range bundles {
+		names = append(names, bundleName(b))
+	}
+
+	return config.StorageBundle{}, fmt.Errorf("no bundle named %q, expected one of: %s", name, strings.Join(names, ", "))
+}
+
+// bundleName will return the name of the bundle, or its source folder for bundles without a name
+func bundleName(b config.StorageBundle) string {
+	if len(b.Name) != 0 {
+		return b.Name
+	}
+
+	return b.SourceFolder
+}
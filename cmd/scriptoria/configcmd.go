@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/pkg/document/manager"
+)
+
+const configUsage = `Usage: scriptoria config validate
+
+Load the configuration file the server would use and report every problem with it, such as
+unknown fields, missing or unwritable folders and unknown storages or processors.
+The file is read from CONFIG_FILE_LOCATION or ./config/config.json.
+
+  --log_level  the log level to run the command at
+`
+
+// runConfig will run the config subcommand
+func runConfig(ctx context.Context, args []string) error {
+	var logLevel string
+	flags := newFlagSet("config", configUsage, &logLevel)
+	positional := parseArgs(flags, args)
+	configureLogger(logLevel)
+
+	if len(positional) != 1 || positional[0] != "validate" {
+		flags.Usage()
+		return errors.New("expected validate")
+	}
+
+	location := configFileLocation()
+	cfg, err := config.Load(location, manager.Registry())
+	if err != nil {
+		return fmt.Errorf("%s: %w", location, err)
+	}
+
+	fmt.Printf("%s is valid, %d bundles from %s through %s\n", location, len(cfg.Bundles), cfg.SourceStore, strings.Join(cfg.Processors, ", "))
+
+	return nil
+}
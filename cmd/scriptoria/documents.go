@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/google/uuid"
+)
+
+const listUsage = `Usage: scriptoria list [--limit N] [--offset N]
+
+List the most recently created documents.
+
+  --limit      the number of documents to list
+  --offset     the number of documents to skip
+  --log_level  the log level to run the command at
+`
+
+const statusUsage = `Usage: scriptoria status <document id>
+
+Show a document and the history of every stage it went through.
+
+  --log_level  the log level to run the command at
+`
+
+const reprocessUsage = `Usage: scriptoria reprocess <document id> [--stage <name>]
+
+Run a document through the processors again. The earlier stages are not run again, their
+stored output is used as the input to the stage.
+
+  --stage      the stage to start from, defaults to the first stage
+  --log_level  the log level to run the command at
+`
+
+const logsUsage = `Usage: scriptoria logs <document id>
+
+Show the log lines the server recorded while it processed a document.
+
+  --log_level  the log level to run the command at
+`
+
+const timeFormat = "2006-01-02 15:04:05"
+
+// runList will print a page of documents
+func runList(ctx context.Context, args []string) error {
+	var logLevel string
+	var limit, offset int
+	flags := newFlagSet("list", listUsage, &logLevel)
+	flags.IntVar(&limit, "limit", 20, "The number of documents to list")
+	flags.IntVar(&offset, "offset", 0, "The number of documents to skip")
+	parseArgs(flags, args)
+	configureLogger(logLevel)
+
+	a, err := newApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	docs, err := a.queries.ListDocuments(ctx, database.ListDocumentsParams{
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "ID\tCREATED AT\tNAME\tSTATUS")
+	for _, d := range docs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.ID, d.CreatedAt.Format(timeFormat), d.SourceName, d.ProcessingStatus.String)
+	}
+
+	return nil
+}
+
+// runStatus will print a document and its events
+func runStatus(ctx context.Context, args []string) error {
+	var logLevel string
+	flags := newFlagSet("status", statusUsage, &logLevel)
+	positional := parseArgs(flags, args)
+	configureLogger(logLevel)
+
+	id, err := documentIDArg(positional)
+	if err != nil {
+		flags.Usage()
+		return err
+	}
+
+	a, err := newApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	doc, err := a.queries.GetDocumentById(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to find the document: %w", err)
+	}
+
+	events, err := a.queries.GetDocumentEventsByDocumentId(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "ID:\t%s\n", doc.ID)
+	fmt.Fprintf(w, "Name:\t%s\n", doc.SourceName)
+	fmt.Fprintf(w, "Source:\t%s %s\n", doc.SourceStore, doc.SourceID)
+	fmt.Fprintf(w, "Created At:\t%s\n", doc.CreatedAt.Format(timeFormat))
+	if doc.ProcessedAt.Valid {
+		fmt.Fprintf(w, "Processed At:\t%s\n", doc.ProcessedAt.Time.Format(timeFormat))
+	}
+	fmt.Fprintf(w, "Status:\t%s\n", doc.ProcessingStatus.String)
+	if doc.ResumeStage.Valid {
+		fmt.Fprintf(w, "Resume Stage:\t%s\n", doc.ResumeStage.String)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "TIME\tSTAGE\tSTATUS\tATTEMPT\tDURATION\tERROR")
+	for _, e := range events {
+		duration := "-"
+		if e.DurationMs.Valid {
+			duration = fmt.Sprintf("%dms", e.DurationMs.Int64)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", e.CreatedAt.Format(timeFormat), e.Stage, e.Status, e.Attempt, duration, e.ErrorMessage.String)
+	}
+
+	return nil
+}
+
+// runLogs will print the recorded log of a document
+func runLogs(ctx context.Context, args []string) error {
+	var logLevel string
+	flags := newFlagSet("logs", logsUsage, &logLevel)
+	positional := parseArgs(flags, args)
+	configureLogger(logLevel)
+
+	id, err := documentIDArg(positional)
+	if err != nil {
+		flags.Usage()
+		return err
+	}
+
+	a, err := newApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	_, err = a.queries.GetDocumentById(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to find the document: %w", err)
+	}
+
+	logs, err := a.queries.GetDocumentLogsByDocumentId(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "TIME\tLEVEL\tMESSAGE\tATTRIBUTES")
+	for _, l := range logs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", l.LoggedAt.Local().Format(timeFormat), l.Level, l.Message, formatAttrs(l.Attrs))
+	}
+
+	return nil
+}
+
+// formatAttrs will write the JSON attributes of a log line as sorted key=value pairs
+func formatAttrs(attrs string) string {
+	values := make(map[string]any)
+	err := json.Unmarshal([]byte(attrs), &values)
+	if err != nil {
+		return attrs
+	}
+
+	pairs := make([]string, 0, len(values))
+	for k, v := range values {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+	slices.Sort(pairs)
+
+	return strings.Join(pairs, " ")
+}
+
+// runReprocess will run a document through the processors again starting at a stage
+func runReprocess(ctx context.Context, args []string) error {
+	var logLevel, stage string
+	flags := newFlagSet("reprocess", reprocessUsage, &logLevel)
+	flags.StringVar(&stage, "stage", "", "The stage to start from")
+	positional := parseArgs(flags, args)
+	configureLogger(logLevel)
+
+	id, err := documentIDArg(positional)
+	if err != nil {
+		flags.Usage()
+		return err
+	}
+
+	a, err := newApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	doc, err := a.queries.GetDocumentById(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to find the document: %w", err)
+	}
+
+	// the first stage reads the document from where it was found
+	dm, err := a.newDocumentManager(doc.SourceStore)
+	if err != nil {
+		return err
+	}
+	defer dm.CancelAndWait()
+
+	stages := dm.Stages()
+	if len(stage) == 0 {
+		stage = stages[0]
+	}
+
+	err = dm.ResumeDocument(id, stage)
+	if err != nil {
+		return fmt.Errorf("failed to reprocess %s from %s (stages: %s): %w", doc.SourceName, stage, strings.Join(stages, ", "), err)
+	}
+
+	fmt.Printf("DONE     %s  %s\n", doc.SourceName, doc.ID)
+
+	return nil
+}
+
+// documentIDArg will parse the single document ID the command expects
+func documentIDArg(positional []string) (uuid.UUID, error) {
+	if len(positional) != 1 {
+		return uuid.Nil, errors.New("expected a document ID")
+	}
+
+	id, err := uuid.Parse(positional[0])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid document ID: %w", err)
+	}
+
+	return id, nil
+}
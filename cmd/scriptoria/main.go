@@ -1,14 +1,47 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/KyleBrandon/scriptoria/pkg/server"
+	_ "github.com/lib/pq" // Import for side effects (PostgreSQL driver)
 )
 
+// commands run and exit, without one we start the server
+var commands = map[string]func(ctx context.Context, args []string) error{
+	"migrate":        runMigrate,
+	"process":        runProcess,
+	"list":           runList,
+	"status":         runStatus,
+	"reprocess":      runReprocess,
+	"logs":           runLogs,
+	"watch-channels": runWatchChannels,
+	"config":         runConfig,
+	"token":          runToken,
+	"usage":          runUsage,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			err := command(ctx, os.Args[2:])
+			stop()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s failed: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+
+			return
+		}
+	}
+
 	flag.Parse()
 	err := server.InitializeServer()
 	if err != nil {
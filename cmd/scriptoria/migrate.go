@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/joho/godotenv"
+	"github.com/pressly/goose/v3"
+)
+
+const migrateUsage = `Usage: scriptoria migrate up|down|status
+
+  up      apply all pending migrations
+  down    roll back the most recent migration
+  status  list the migrations and when they were applied
+`
+
+// runMigrate will run the migrate subcommand against the database in DATABASE_URL
+func runMigrate(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, migrateUsage) }
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected one of up, down or status")
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch flags.Arg(0) {
+	case "up":
+		results, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(results) == 0 {
+			fmt.Println("The database is up to date")
+		}
+
+		for _, r := range results {
+			fmt.Printf("Applied %s in %s\n", r.Source.Path, r.Duration)
+		}
+
+	case "down":
+		r, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Rolled back %s in %s\n", r.Source.Path, r.Duration)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		printMigrationStatus(statuses)
+
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown migrate command: %s", flags.Arg(0))
+	}
+
+	return nil
+}
+
+func printMigrationStatus(statuses []*goose.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+	for _, s := range statuses {
+		appliedAt := "-"
+		if s.State == goose.StateApplied {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, appliedAt, s.Source.Path)
+	}
+}
+
+// openDatabase will open the database in the DATABASE_URL environment variable, reading .env if it exists
+func openDatabase() (*sql.DB, error) {
+	godotenv.Load()
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if len(databaseURL) == 0 {
+		return nil, errors.New("no database connection string is configured")
+	}
+
+	return sql.Open("postgres", databaseURL)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/document/manager"
+)
+
+const processUsage = `Usage: scriptoria process <file or folder>... --bundle <name>
+
+Run the configured processors on local PDF files without watching the source storage.
+A folder is processed one PDF at a time. The results are written to the bundle's destination folders.
+
+  --bundle     the name or source folder of the bundle to process the files with
+  --log_level  the log level to run the command at
+`
+
+// runProcess will run the pipeline on the local files passed on the command line
+func runProcess(ctx context.Context, args []string) error {
+	var bundleFlag, logLevel string
+	flags := newFlagSet("process", processUsage, &logLevel)
+	flags.StringVar(&bundleFlag, "bundle", "", "The bundle to process the files with")
+	paths := parseArgs(flags, args)
+	configureLogger(logLevel)
+
+	if len(paths) == 0 || len(bundleFlag) == 0 {
+		flags.Usage()
+		return errors.New("expected at least one file and a bundle")
+	}
+
+	a, err := newApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	files, err := collectFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	dm, err := a.newDocumentManager("Local")
+	if err != nil {
+		return err
+	}
+	defer dm.CancelAndWait()
+
+	// the manager has loaded the enabled bundles from the database
+	bundle, err := findBundle(dm.Config().Bundles, bundleFlag)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		srcDoc, err := localDocument(file, bundle)
+		if err != nil {
+			return err
+		}
+
+		id, err := dm.ProcessDocument(srcDoc)
+		switch {
+		case errors.Is(err, manager.ErrDocumentExists):
+			fmt.Printf("SKIPPED  %s  already processed, use reprocess to run it again\n", file)
+		case err != nil:
+			failed++
+			fmt.Printf("FAILED   %s  %s  %v\n", file, id, err)
+		default:
+			fmt.Printf("DONE     %s  %s\n", file, id)
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d of %d files failed to process", failed, len(files))
+	}
+
+	return nil
+}
+
+// collectFiles will expand the folders into the PDF files they contain
+func collectFiles(paths []string) ([]string, error) {
+	files := make([]string, 0)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ".pdf") {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// localDocument will describe the local file as if it was found in the bundle's source folder
+func localDocument(file string, bundle config.StorageBundle) (*document.Document, error) {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &document.Document{
+		StorageDocumentID: path,
+		StorageFolderID:   bundle.SourceFolder,
+		Name:              info.Name(),
+		CreatedTime:       info.ModTime(),
+		ModifiedTime:      info.ModTime(),
+	}, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/auth"
+	"github.com/google/uuid"
+)
+
+const tokenUsage = `Usage: scriptoria token create --name <name> --scopes <scopes> [--expires <duration>]
+       scriptoria token list
+       scriptoria token revoke <token id>
+
+Manage the tokens used to call the API. The token is only shown when it is created, the database
+stores its hash. A token with a scope can call the routes of the scopes below it.
+
+  --name       a name to recognize the token by
+  --scopes     comma separated list of read, write and admin
+  --expires    how long the token is valid for, such as 720h, defaults to no expiry
+  --log_level  the log level to run the command at
+`
+
+// runToken will run the token subcommand
+func runToken(ctx context.Context, args []string) error {
+	var logLevel, name, scopes string
+	var expires time.Duration
+	flags := newFlagSet("token", tokenUsage, &logLevel)
+	flags.StringVar(&name, "name", "", "A name to recognize the token by")
+	flags.StringVar(&scopes, "scopes", "", "Comma separated list of read, write and admin")
+	flags.DurationVar(&expires, "expires", 0, "How long the token is valid for")
+	positional := parseArgs(flags, args)
+	configureLogger(logLevel)
+
+	if len(positional) == 0 {
+		flags.Usage()
+		return errors.New("expected create, list or revoke")
+	}
+
+	a, err := newApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	switch {
+	case positional[0] == "create" && len(positional) == 1:
+		return a.createToken(name, scopes, expires)
+	case positional[0] == "list" && len(positional) == 1:
+		return a.listTokens()
+	case positional[0] == "revoke" && len(positional) == 2:
+		return a.revokeToken(positional[1])
+	}
+
+	flags.Usage()
+	return errors.New("expected create, list or revoke <token id>")
+}
+
+// createToken will store the hash of a new token and print the token
+func (a *app) createToken(name, scopes string, expires time.Duration) error {
+	if len(name) == 0 {
+		return errors.New("--name is required")
+	}
+
+	parsed, err := auth.ParseScopes(scopes)
+	if err != nil {
+		return err
+	}
+
+	token, hash, prefix, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+
+	var expiresAt sql.NullTime
+	if expires > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().Add(expires), Valid: true}
+	}
+
+	t, err := a.queries.CreateApiToken(a.ctx, database.CreateApiTokenParams{
+		Name:      name,
+		Prefix:    prefix,
+		TokenHash: hash,
+		Scopes:    parsed,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created token %s (%s) with scopes %s\n", t.ID, t.Name, strings.Join(t.Scopes, ", "))
+	fmt.Println("Store the token now, it can not be shown again:")
+	fmt.Println(token)
+
+	return nil
+}
+
+// listTokens will print every token without the secret part
+func (a *app) listTokens() error {
+	tokens, err := a.queries.ListApiTokens(a.ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "ID\tNAME\tPREFIX\tSCOPES\tCREATED AT\tEXPIRES AT\tLAST USED AT\tSTATE")
+	for _, t := range tokens {
+		state := "active"
+		switch {
+		case t.RevokedAt.Valid:
+			state = "revoked"
+		case t.ExpiresAt.Valid && time.Now().After(t.ExpiresAt.Time):
+			state = "expired"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s…\t%s\t%s\t%s\t%s\t%s\n", t.ID, t.Name, t.Prefix, strings.Join(t.Scopes, ","),
+			t.CreatedAt.Format(timeFormat), formatNullTime(t.ExpiresAt), formatNullTime(t.LastUsedAt), state)
+	}
+
+	return nil
+}
+
+// revokeToken will stop the token from being accepted, the row is kept so its use can still be audited
+func (a *app) revokeToken(value string) error {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid token id %q: %w", value, err)
+	}
+
+	t, err := a.queries.RevokeApiToken(a.ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no token with id %s", id)
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Printf("Revoked token %s (%s) at %s\n", t.ID, t.Name, t.RevokedAt.Time.Format(timeFormat))
+
+	return nil
+}
+
+func formatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return "-"
+	}
+
+	return t.Time.Format(timeFormat)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/usage"
+)
+
+const usageUsage = `Usage: scriptoria usage [--from YYYY-MM] [--to YYYY-MM] [--bundle <name>]
+
+Show what the documents of each bundle used from Mathpix and OpenAI per month, and what it cost
+at the prices in the configuration file.
+
+  --from       the first month of the report, defaults to eleven months before --to
+  --to         the last month of the report, defaults to this month
+  --bundle     only report the bundle with this name or source folder
+  --log_level  the log level to run the command at
+`
+
+// runUsage will print the cost of each bundle per month
+func runUsage(ctx context.Context, args []string) error {
+	var logLevel, from, to, bundle string
+	flags := newFlagSet("usage", usageUsage, &logLevel)
+	flags.StringVar(&from, "from", "", "The first month of the report")
+	flags.StringVar(&to, "to", "", "The last month of the report")
+	flags.StringVar(&bundle, "bundle", "", "The bundle to report")
+	parseArgs(flags, args)
+	configureLogger(logLevel)
+
+	start, end, err := usage.MonthRange(from, to, time.Now())
+	if err != nil {
+		flags.Usage()
+		return err
+	}
+
+	a, err := newApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	rows, err := a.queries.GetUsageReport(ctx, database.GetUsageReportParams{FromTime: start, ToTime: end})
+	if err != nil {
+		return err
+	}
+
+	report := usage.NewReport(usage.FilterBundle(rows, bundle), a.config.Prices, start, end)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "MONTH\tBUNDLE\tPROVIDER\tUNIT\tAMOUNT\tDOCUMENTS\tCOST")
+	for _, m := range report.Months {
+		name := m.Bundle
+		if len(name) == 0 {
+			name = m.SourceFolder
+		}
+
+		for _, item := range m.Items {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%.4f\n", m.Month, name, item.Provider, item.Unit, item.Amount, item.Documents, item.Cost)
+		}
+	}
+
+	fmt.Fprintf(w, "\nTotal %s to %s:\t%.2f %s\n", report.From, report.To, report.Cost, report.Currency)
+
+	return nil
+}
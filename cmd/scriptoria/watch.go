@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/pkg/document/storage/gdrive"
+)
+
+const watchChannelsUsage = `Usage: scriptoria watch-channels [--renew]
+
+Show the Google Drive watch channel of every bundle folder.
+
+  --renew      create the missing channels and replace the expired ones
+  --log_level  the log level to run the command at
+
+A running server only accepts notifications for the channels it created, restart it after renewing.
+`
+
+// runWatchChannels will print the watch channels of the bundle folders and optionally renew them
+func runWatchChannels(ctx context.Context, args []string) error {
+	var logLevel string
+	var renew bool
+	flags := newFlagSet("watch-channels", watchChannelsUsage, &logLevel)
+	flags.BoolVar(&renew, "renew", false, "Create the missing channels and replace the expired ones")
+	parseArgs(flags, args)
+	configureLogger(logLevel)
+
+	a, err := newApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	bundles, err := a.enabledBundles()
+	if err != nil {
+		return err
+	}
+
+	if renew {
+		drive := gdrive.New(a.queries, nil)
+		err = drive.Initialize(ctx, bundles)
+		if err != nil {
+			return err
+		}
+
+		err = drive.RenewWatchChannels()
+		drive.CancelAndWait()
+		if err != nil {
+			return err
+		}
+	}
+
+	folders := make([]string, 0, len(bundles))
+	for _, b := range bundles {
+		folders = append(folders, b.SourceFolder)
+	}
+
+	channels, err := a.queries.GetWatchEntriesByFolderIDs(ctx, folders)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "BUNDLE\tFOLDER\tCHANNEL\tEXPIRES AT\tSTATE\tWEBHOOK")
+	for _, b := range bundles {
+		found := false
+		for _, c := range channels {
+			if c.ResourceID != b.SourceFolder {
+				continue
+			}
+
+			found = true
+			expiresAt := time.UnixMilli(c.ExpiresAt)
+			state := "active"
+			if time.Now().After(expiresAt) {
+				state = "expired"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", bundleName(b), b.SourceFolder, c.ChannelID, expiresAt.Format(timeFormat), state, c.WebhookUrl)
+		}
+
+		if !found {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\tmissing\t-\n", bundleName(b), b.SourceFolder)
+		}
+	}
+
+	return nil
+}
@@ -5,26 +5,222 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"reflect"
+	"time"
 )
 
-const DefaultLogLevel = slog.LevelInfo
+const (
+	DefaultLogLevel     = slog.LevelInfo
+	DefaultCacheTTL     = Duration(30 * 24 * time.Hour)
+	DefaultCacheMaxSize = 512
+
+	DefaultShutdownGracePeriod = Duration(30 * time.Second)
+
+	DefaultTraceServiceName = "scriptoria"
+	DefaultTraceExporter    = "otlp"
+
+	DefaultNotifyMaxAttempts    = 5
+	DefaultNotifyInitialBackoff = Duration(10 * time.Second)
+	DefaultSMTPPort             = 587
+
+	DefaultDocumentLogsLevel          = "info"
+	DefaultDocumentLogsMaxPerDocument = 500
+
+	DefaultMaxDocuments = 8
+	DefaultStageWorkers = 4
+
+	DefaultStageTimeout       = Duration(15 * time.Minute)
+	DefaultMathpixPollTimeout = Duration(10 * time.Minute)
+
+	DefaultPriceCurrency = "USD"
+
+	DefaultProfilingAddress     = "localhost:6060"
+	DefaultProfilingMaxDuration = Duration(5 * time.Minute)
+)
+
+// DefaultProcessors is the pipeline documents go through when the config file does not list the processors
+var DefaultProcessors = []string{"temp_storage", "mathpix", "chatgpt", "obsidian", "bundle"}
+
+// MathpixFormats are the conversion formats a bundle can request from Mathpix besides the Markdown of the note
+var MathpixFormats = []string{"docx", "html", "tex.zip"}
+
+// MathpixAlphabets are the alphabets Mathpix can be told to expect or rule out with alphabets_allowed
+var MathpixAlphabets = []string{"bn", "en", "gu", "hi", "ja", "ko", "ru", "ta", "te", "th", "vi", "zh"}
+
+// NotifyEvents are the document events a bundle can send notifications for
+var NotifyEvents = []string{"detected", "failed", "archived", "completed", "canceled"}
 
 type (
 	StorageBundle struct {
-		SourceFolder          string `json:"source_folder"`
-		ArchiveFolder         string `json:"archive_folder"`
-		DestAttachmentsFolder string `json:"dest_attachments_folder"`
-		DestNotesFolder       string `json:"dest_notes_folder"`
+		Name                  string         `json:"name"`
+		SourceFolder          string         `json:"source_folder"`
+		ArchiveFolder         string         `json:"archive_folder"`
+		DestAttachmentsFolder string         `json:"dest_attachments_folder"`
+		DestNotesFolder       string         `json:"dest_notes_folder"`
+		NotifyOn              []string       `json:"notify_on"`
+		Mathpix               MathpixOptions `json:"mathpix"`
+	}
+
+	// MathpixOptions are sent with the PDFs of a bundle in the options_json of the Mathpix PDF API, the options that
+	// are not set use the Mathpix defaults.  The conversion formats are downloaded next to the note.
+	MathpixOptions struct {
+		MathInlineDelimiters  []string        `json:"math_inline_delimiters,omitempty"`
+		MathDisplayDelimiters []string        `json:"math_display_delimiters,omitempty"`
+		RmSpaces              *bool           `json:"rm_spaces,omitempty"`
+		EnableTablesFallback  *bool           `json:"enable_tables_fallback,omitempty"`
+		PageRanges            string          `json:"page_ranges,omitempty"`
+		AlphabetsAllowed      map[string]bool `json:"alphabets_allowed,omitempty"`
+		ConversionFormats     []string        `json:"conversion_formats,omitempty"`
+	}
+
+	// CacheConfig controls the cache of processor results keyed by the source document contents
+	CacheConfig struct {
+		Disabled  bool     `json:"disabled"`
+		Folder    string   `json:"folder"`
+		TTL       Duration `json:"ttl"`
+		MaxSizeMB int64    `json:"max_size_mb"`
+	}
+
+	// TracingConfig controls the OpenTelemetry trace exporter
+	TracingConfig struct {
+		Enabled     bool    `json:"enabled"`
+		Exporter    string  `json:"exporter"`
+		Endpoint    string  `json:"endpoint"`
+		Insecure    bool    `json:"insecure"`
+		ServiceName string  `json:"service_name"`
+		SampleRatio float64 `json:"sample_ratio"`
+	}
+
+	// NotificationsConfig lists where notifications are sent and how failed deliveries are retried
+	NotificationsConfig struct {
+		MaxAttempts    int                  `json:"max_attempts"`
+		InitialBackoff Duration             `json:"initial_backoff"`
+		Targets        []NotificationTarget `json:"targets"`
+	}
+
+	// NotificationTarget is a webhook, an ntfy topic or an email address list notifications are sent to
+	NotificationTarget struct {
+		Name   string     `json:"name"`
+		Type   string     `json:"type"`
+		URL    string     `json:"url"`
+		Secret string     `json:"secret"`
+		Token  string     `json:"token"`
+		SMTP   SMTPConfig `json:"smtp"`
+	}
+
+	// SMTPConfig is the mail server and addresses of an email notification target
+	SMTPConfig struct {
+		Host     string   `json:"host"`
+		Port     int      `json:"port"`
+		Username string   `json:"username"`
+		Password string   `json:"password"`
+		From     string   `json:"from"`
+		To       []string `json:"to"`
+	}
+
+	// DocumentLogsConfig controls the log lines of each document that are kept in the database
+	DocumentLogsConfig struct {
+		Disabled       bool   `json:"disabled"`
+		Level          string `json:"level"`
+		MaxPerDocument int    `json:"max_per_document"`
+	}
+
+	// ConcurrencyConfig limits the documents in flight and the documents each stage processes at the same time
+	ConcurrencyConfig struct {
+		MaxDocuments int            `json:"max_documents"`
+		Workers      int            `json:"workers"`
+		Stages       map[string]int `json:"stages"`
+	}
+
+	// TimeoutsConfig limits how long a stage may process a document, and how long Mathpix may take to convert a PDF
+	TimeoutsConfig struct {
+		Stage       Duration            `json:"stage"`
+		Stages      map[string]Duration `json:"stages"`
+		MathpixPoll Duration            `json:"mathpix_poll"`
+	}
+
+	// ProviderLimits are the rate limits of an external API and the budget of what it may use, zero is unlimited.
+	// Mathpix budgets are in pages and OpenAI budgets are in tokens.
+	ProviderLimits struct {
+		RequestsPerMinute int   `json:"requests_per_minute"`
+		TokensPerMinute   int   `json:"tokens_per_minute"`
+		DailyBudget       int64 `json:"daily_budget"`
+		MonthlyBudget     int64 `json:"monthly_budget"`
+	}
+
+	// LimitsConfig are the limits of each external API the processors call
+	LimitsConfig struct {
+		Mathpix ProviderLimits `json:"mathpix"`
+		OpenAI  ProviderLimits `json:"openai"`
+	}
+
+	// PricesConfig are the prices the usage report works out the cost with, in the currency of the report
+	PricesConfig struct {
+		Currency                         string  `json:"currency"`
+		MathpixPerPage                   float64 `json:"mathpix_per_page"`
+		OpenAIPerMillionPromptTokens     float64 `json:"openai_per_million_prompt_tokens"`
+		OpenAIPerMillionCompletionTokens float64 `json:"openai_per_million_completion_tokens"`
+	}
+
+	// ProfilingConfig controls the profiling server, it listens on its own address and requires an admin token
+	ProfilingConfig struct {
+		Enabled     bool     `json:"enabled"`
+		Address     string   `json:"address"`
+		Folder      string   `json:"folder"`
+		MaxDuration Duration `json:"max_duration"`
 	}
 
 	// TODO: Update so that each storage config can have settings and add Processor configs
 	Config struct {
-		TempStorageFolder string          `json:"temp_storage_folder"`
-		SourceStore       string          `json:"source_store"`
-		Bundles           []StorageBundle `json:"bundles"`
+		TempStorageFolder     string              `json:"temp_storage_folder"`
+		ArtifactStorageFolder string              `json:"artifact_storage_folder"`
+		SourceStore           string              `json:"source_store"`
+		Processors            []string            `json:"processors"`
+		Cache                 CacheConfig         `json:"cache"`
+		Tracing               TracingConfig       `json:"tracing"`
+		ShutdownGracePeriod   Duration            `json:"shutdown_grace_period"`
+		Concurrency           ConcurrencyConfig   `json:"concurrency"`
+		Timeouts              TimeoutsConfig      `json:"timeouts"`
+		Limits                LimitsConfig        `json:"limits"`
+		Prices                PricesConfig        `json:"prices"`
+		Notifications         NotificationsConfig `json:"notifications"`
+		DocumentLogs          DocumentLogsConfig  `json:"document_logs"`
+		Profiling             ProfilingConfig     `json:"profiling"`
+		Bundles               []StorageBundle     `json:"bundles"`
 	}
 )
 
+// StageWorkers returns the number of documents the stage processes at the same time
+func (c ConcurrencyConfig) StageWorkers(stage string) int {
+	if workers, ok := c.Stages[stage]; ok {
+		return workers
+	}
+
+	return c.Workers
+}
+
+// StageTimeout returns how long the stage may process a document
+func (c TimeoutsConfig) StageTimeout(stage string) time.Duration {
+	if timeout, ok := c.Stages[stage]; ok {
+		return timeout.Duration()
+	}
+
+	return c.Stage.Duration()
+}
+
+// Provider returns the limits of the external API by the name it is measured with
+func (c LimitsConfig) Provider(name string) ProviderLimits {
+	switch name {
+	case "mathpix":
+		return c.Mathpix
+	case "openai":
+		return c.OpenAI
+	default:
+		return ProviderLimits{}
+	}
+}
+
 func LoadConfigSettings(filename string) (Config, error) {
 	var config Config
 	file, err := os.Open(filename)
@@ -39,10 +235,114 @@ func LoadConfigSettings(filename string) (Config, error) {
 		return config, err
 	}
 
+	// report every unknown field and value of the wrong type before decoding
+	var raw any
+	err = json.Unmarshal(bytes, &raw)
+	if err != nil {
+		return config, err
+	}
+
+	var errs ValidationErrors
+	checkFields(&errs, raw, reflect.TypeOf(config), "")
+	if len(errs) != 0 {
+		return config, errs
+	}
+
 	err = json.Unmarshal(bytes, &config)
 	if err != nil {
 		return config, err
 	}
 
+	if len(config.Processors) == 0 {
+		config.Processors = DefaultProcessors
+	}
+
+	// default the artifacts to live under the temp storage
+	if len(config.ArtifactStorageFolder) == 0 && len(config.TempStorageFolder) != 0 {
+		config.ArtifactStorageFolder = filepath.Join(config.TempStorageFolder, "artifacts")
+	}
+
+	if len(config.Cache.Folder) == 0 && len(config.TempStorageFolder) != 0 {
+		config.Cache.Folder = filepath.Join(config.TempStorageFolder, "cache")
+	}
+
+	if config.Cache.TTL == 0 {
+		config.Cache.TTL = DefaultCacheTTL
+	}
+
+	if config.Cache.MaxSizeMB == 0 {
+		config.Cache.MaxSizeMB = DefaultCacheMaxSize
+	}
+
+	if config.ShutdownGracePeriod == 0 {
+		config.ShutdownGracePeriod = DefaultShutdownGracePeriod
+	}
+
+	if config.Notifications.MaxAttempts == 0 {
+		config.Notifications.MaxAttempts = DefaultNotifyMaxAttempts
+	}
+
+	if config.Notifications.InitialBackoff == 0 {
+		config.Notifications.InitialBackoff = DefaultNotifyInitialBackoff
+	}
+
+	for i := range config.Notifications.Targets {
+		if config.Notifications.Targets[i].Type == "smtp" && config.Notifications.Targets[i].SMTP.Port == 0 {
+			config.Notifications.Targets[i].SMTP.Port = DefaultSMTPPort
+		}
+	}
+
+	if config.Concurrency.MaxDocuments == 0 {
+		config.Concurrency.MaxDocuments = DefaultMaxDocuments
+	}
+
+	if config.Concurrency.Workers == 0 {
+		config.Concurrency.Workers = DefaultStageWorkers
+	}
+
+	if config.Timeouts.Stage == 0 {
+		config.Timeouts.Stage = DefaultStageTimeout
+	}
+
+	if config.Timeouts.MathpixPoll == 0 {
+		config.Timeouts.MathpixPoll = DefaultMathpixPollTimeout
+	}
+
+	if len(config.Prices.Currency) == 0 {
+		config.Prices.Currency = DefaultPriceCurrency
+	}
+
+	if len(config.DocumentLogs.Level) == 0 {
+		config.DocumentLogs.Level = DefaultDocumentLogsLevel
+	}
+
+	if config.DocumentLogs.MaxPerDocument == 0 {
+		config.DocumentLogs.MaxPerDocument = DefaultDocumentLogsMaxPerDocument
+	}
+
+	if len(config.Profiling.Address) == 0 {
+		config.Profiling.Address = DefaultProfilingAddress
+	}
+
+	if len(config.Profiling.Folder) == 0 && len(config.TempStorageFolder) != 0 {
+		config.Profiling.Folder = filepath.Join(config.TempStorageFolder, "profiles")
+	}
+
+	if config.Profiling.MaxDuration == 0 {
+		config.Profiling.MaxDuration = DefaultProfilingMaxDuration
+	}
+
+	if len(config.Tracing.ServiceName) == 0 {
+		config.Tracing.ServiceName = DefaultTraceServiceName
+	}
+
+	if len(config.Tracing.Exporter) == 0 {
+		config.Tracing.Exporter = DefaultTraceExporter
+	}
+
+	if config.Tracing.SampleRatio == 0 {
+		config.Tracing.SampleRatio = 1
+	}
+
 	return config, nil
 }
@@ -0,0 +1,557 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/KyleBrandon/scriptoria/pkg/utils"
+)
+
+type (
+	// ValidationError is a problem with one setting, the path is the JSON path of the setting such as bundles[0].source_folder
+	ValidationError struct {
+		Path    string `json:"path"`
+		Message string `json:"message"`
+	}
+
+	// ValidationErrors are all the problems found in the configuration file
+	ValidationErrors []ValidationError
+
+	// Registry lists the names the configuration file can reference
+	Registry struct {
+		Storages       []string
+		Processors     []string
+		TraceExporters []string
+		Notifiers      []string
+	}
+)
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	if len(e) == 1 {
+		lines = append(lines, "the configuration has a problem:")
+	} else {
+		lines = append(lines, fmt.Sprintf("the configuration has %d problems:", len(e)))
+	}
+	for _, v := range e {
+		lines = append(lines, "  "+v.Error())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (e *ValidationErrors) add(path, format string, args ...any) {
+	*e = append(*e, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Load will read the configuration file and validate it against the registry.  Every problem
+// found is returned in a ValidationErrors.
+func Load(filename string, registry Registry) (Config, error) {
+	config, err := LoadConfigSettings(filename)
+	if err != nil {
+		return config, err
+	}
+
+	return config, config.Validate(registry)
+}
+
+// Validate will check the settings and that the storages and processors they reference are registered
+func (c Config) Validate(registry Registry) error {
+	var errs ValidationErrors
+
+	if len(c.TempStorageFolder) == 0 {
+		errs.add("temp_storage_folder", "is required")
+	} else {
+		checkWritableFolder(&errs, "temp_storage_folder", c.TempStorageFolder)
+	}
+
+	checkCreatableFolder(&errs, "artifact_storage_folder", c.ArtifactStorageFolder)
+
+	if len(c.SourceStore) == 0 {
+		errs.add("source_store", "is required, expected one of %s", quoteAll(registry.Storages))
+	} else if !slices.Contains(registry.Storages, c.SourceStore) {
+		errs.add("source_store", "unknown storage %q, expected one of %s", c.SourceStore, quoteAll(registry.Storages))
+	}
+
+	if !c.Cache.Disabled {
+		checkCreatableFolder(&errs, "cache.folder", c.Cache.Folder)
+	}
+
+	if c.Cache.TTL < 0 {
+		errs.add("cache.ttl", "must not be negative")
+	}
+
+	if c.Cache.MaxSizeMB < 0 {
+		errs.add("cache.max_size_mb", "must not be negative")
+	}
+
+	if c.Tracing.Enabled && !slices.Contains(registry.TraceExporters, c.Tracing.Exporter) {
+		errs.add("tracing.exporter", "unknown exporter %q, expected one of %s", c.Tracing.Exporter, quoteAll(registry.TraceExporters))
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs.add("tracing.sample_ratio", "must be between 0 and 1")
+	}
+
+	if c.ShutdownGracePeriod < 0 {
+		errs.add("shutdown_grace_period", "must not be negative")
+	}
+
+	seenProcessors := make(map[string]int)
+	for i, name := range c.Processors {
+		path := fmt.Sprintf("processors[%d]", i)
+		if !slices.Contains(registry.Processors, name) {
+			errs.add(path, "unknown processor %q, expected one of %s", name, quoteAll(registry.Processors))
+		} else if first, ok := seenProcessors[name]; ok {
+			errs.add(path, "processor %q is already in the pipeline at processors[%d]", name, first)
+		}
+
+		seenProcessors[name] = i
+	}
+
+	c.validateConcurrency(&errs)
+	c.validateTimeouts(&errs)
+	c.Limits.Mathpix.validate(&errs, "limits.mathpix.")
+	c.Limits.OpenAI.validate(&errs, "limits.openai.")
+
+	// Mathpix is billed by the page, there is no token quota to pace
+	if c.Limits.Mathpix.TokensPerMinute != 0 {
+		errs.add("limits.mathpix.tokens_per_minute", "is only supported for openai")
+	}
+
+	if c.Prices.MathpixPerPage < 0 {
+		errs.add("prices.mathpix_per_page", "must not be negative")
+	}
+
+	if c.Prices.OpenAIPerMillionPromptTokens < 0 {
+		errs.add("prices.openai_per_million_prompt_tokens", "must not be negative")
+	}
+
+	if c.Prices.OpenAIPerMillionCompletionTokens < 0 {
+		errs.add("prices.openai_per_million_completion_tokens", "must not be negative")
+	}
+
+	if _, err := utils.ParseLogLevel(c.DocumentLogs.Level); err != nil {
+		errs.add("document_logs.level", "unknown level %q, expected debug, info, warn or error", c.DocumentLogs.Level)
+	}
+
+	if c.DocumentLogs.MaxPerDocument < 0 {
+		errs.add("document_logs.max_per_document", "must not be negative")
+	}
+
+	if c.Profiling.Enabled {
+		if _, _, err := net.SplitHostPort(c.Profiling.Address); err != nil {
+			errs.add("profiling.address", "%q is not a host:port address", c.Profiling.Address)
+		}
+
+		checkCreatableFolder(&errs, "profiling.folder", c.Profiling.Folder)
+	}
+
+	if c.Profiling.MaxDuration < 0 {
+		errs.add("profiling.max_duration", "must not be negative")
+	}
+
+	c.validateNotifications(&errs, registry)
+	c.validateBundles(&errs)
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+func (c Config) validateConcurrency(errs *ValidationErrors) {
+	if c.Concurrency.MaxDocuments < 1 {
+		errs.add("concurrency.max_documents", "must be at least 1")
+	}
+
+	if c.Concurrency.Workers < 1 {
+		errs.add("concurrency.workers", "must be at least 1")
+	}
+
+	stages := make([]string, 0, len(c.Concurrency.Stages))
+	for stage := range c.Concurrency.Stages {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+
+	for _, stage := range stages {
+		path := "concurrency.stages." + stage
+		if !slices.Contains(c.Processors, stage) {
+			errs.add(path, "unknown stage %q, expected one of the processors %s", stage, quoteAll(c.Processors))
+		}
+
+		if c.Concurrency.Stages[stage] < 1 {
+			errs.add(path, "must be at least 1")
+		}
+	}
+}
+
+func (c Config) validateTimeouts(errs *ValidationErrors) {
+	if c.Timeouts.Stage < 0 {
+		errs.add("timeouts.stage", "must not be negative")
+	}
+
+	if c.Timeouts.MathpixPoll < 0 {
+		errs.add("timeouts.mathpix_poll", "must not be negative")
+	}
+
+	stages := make([]string, 0, len(c.Timeouts.Stages))
+	for stage := range c.Timeouts.Stages {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+
+	for _, stage := range stages {
+		path := "timeouts.stages." + stage
+		if !slices.Contains(c.Processors, stage) {
+			errs.add(path, "unknown stage %q, expected one of the processors %s", stage, quoteAll(c.Processors))
+		}
+
+		if c.Timeouts.Stages[stage] <= 0 {
+			errs.add(path, "must be greater than 0")
+		}
+	}
+}
+
+func (l ProviderLimits) validate(errs *ValidationErrors, path string) {
+	if l.RequestsPerMinute < 0 {
+		errs.add(path+"requests_per_minute", "must not be negative")
+	}
+
+	if l.TokensPerMinute < 0 {
+		errs.add(path+"tokens_per_minute", "must not be negative")
+	}
+
+	if l.DailyBudget < 0 {
+		errs.add(path+"daily_budget", "must not be negative")
+	}
+
+	if l.MonthlyBudget < 0 {
+		errs.add(path+"monthly_budget", "must not be negative")
+	}
+}
+
+func (c Config) validateNotifications(errs *ValidationErrors, registry Registry) {
+	if c.Notifications.MaxAttempts < 0 {
+		errs.add("notifications.max_attempts", "must not be negative")
+	}
+
+	if c.Notifications.InitialBackoff < 0 {
+		errs.add("notifications.initial_backoff", "must not be negative")
+	}
+
+	names := make(map[string]int)
+	for i, t := range c.Notifications.Targets {
+		path := fmt.Sprintf("notifications.targets[%d].", i)
+
+		if len(t.Name) == 0 {
+			errs.add(path+"name", "is required")
+		} else if first, ok := names[t.Name]; ok {
+			errs.add(path+"name", "%q is already used by notifications.targets[%d]", t.Name, first)
+		} else {
+			names[t.Name] = i
+		}
+
+		if !slices.Contains(registry.Notifiers, t.Type) {
+			errs.add(path+"type", "unknown notifier %q, expected one of %s", t.Type, quoteAll(registry.Notifiers))
+			continue
+		}
+
+		if t.Type == "smtp" {
+			if len(t.SMTP.Host) == 0 {
+				errs.add(path+"smtp.host", "is required")
+			}
+
+			if t.SMTP.Port < 1 || t.SMTP.Port > 65535 {
+				errs.add(path+"smtp.port", "must be between 1 and 65535")
+			}
+
+			if len(t.SMTP.From) == 0 {
+				errs.add(path+"smtp.from", "is required")
+			}
+
+			if len(t.SMTP.To) == 0 {
+				errs.add(path+"smtp.to", "needs at least one address")
+			}
+
+			continue
+		}
+
+		u, err := url.Parse(t.URL)
+		if len(t.URL) == 0 {
+			errs.add(path+"url", "is required")
+		} else if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			errs.add(path+"url", "%q is not an http or https URL", t.URL)
+		}
+	}
+}
+
+// bundles can also be added through the API so the config file does not need any
+func (c Config) validateBundles(errs *ValidationErrors) {
+	folders := make(map[string]int)
+	names := make(map[string]int)
+	for i, b := range c.Bundles {
+		path := fmt.Sprintf("bundles[%d].", i)
+
+		if len(b.Name) != 0 {
+			if first, ok := names[b.Name]; ok {
+				errs.add(path+"name", "%q is already used by bundles[%d]", b.Name, first)
+			} else {
+				names[b.Name] = i
+			}
+		}
+
+		if first, ok := folders[b.SourceFolder]; ok && len(b.SourceFolder) != 0 {
+			errs.add(path+"source_folder", "%q is already used by bundles[%d]", b.SourceFolder, first)
+		} else {
+			folders[b.SourceFolder] = i
+		}
+
+		b.validate(errs, path)
+	}
+}
+
+// Validate will check the settings of a single bundle, the paths of the problems are the field names
+func (b StorageBundle) Validate() error {
+	var errs ValidationErrors
+	b.validate(&errs, "")
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+func (b StorageBundle) validate(errs *ValidationErrors, path string) {
+	if len(b.SourceFolder) == 0 {
+		errs.add(path+"source_folder", "is required")
+	}
+
+	if len(b.ArchiveFolder) == 0 {
+		errs.add(path+"archive_folder", "is required")
+	}
+
+	checkWritableFolder(errs, path+"dest_attachments_folder", b.DestAttachmentsFolder)
+	checkWritableFolder(errs, path+"dest_notes_folder", b.DestNotesFolder)
+
+	for i, event := range b.NotifyOn {
+		if !slices.Contains(NotifyEvents, event) {
+			errs.add(fmt.Sprintf("%snotify_on[%d]", path, i), "unknown event %q, expected one of %s", event, quoteAll(NotifyEvents))
+		}
+	}
+
+	b.Mathpix.validate(errs, path+"mathpix.")
+}
+
+// pageRangesPattern matches page ranges such as "1-3,5" where negative pages count from the end of the PDF
+var pageRangesPattern = regexp.MustCompile(`^-?[0-9]+(--?[0-9]+)?(,-?[0-9]+(--?[0-9]+)?)*$`)
+
+func (o MathpixOptions) validate(errs *ValidationErrors, path string) {
+	if o.MathInlineDelimiters != nil && len(o.MathInlineDelimiters) != 2 {
+		errs.add(path+"math_inline_delimiters", "must be the opening and closing delimiter")
+	}
+
+	if o.MathDisplayDelimiters != nil && len(o.MathDisplayDelimiters) != 2 {
+		errs.add(path+"math_display_delimiters", "must be the opening and closing delimiter")
+	}
+
+	if len(o.PageRanges) != 0 && !pageRangesPattern.MatchString(o.PageRanges) {
+		errs.add(path+"page_ranges", "%q is not a list of pages and ranges such as \"1-3,5\"", o.PageRanges)
+	}
+
+	alphabets := make([]string, 0, len(o.AlphabetsAllowed))
+	for alphabet := range o.AlphabetsAllowed {
+		alphabets = append(alphabets, alphabet)
+	}
+	sort.Strings(alphabets)
+
+	for _, alphabet := range alphabets {
+		if !slices.Contains(MathpixAlphabets, alphabet) {
+			errs.add(path+"alphabets_allowed."+alphabet, "unknown alphabet %q, expected one of %s", alphabet, quoteAll(MathpixAlphabets))
+		}
+	}
+
+	for i, format := range o.ConversionFormats {
+		p := fmt.Sprintf("%sconversion_formats[%d]", path, i)
+		if !slices.Contains(MathpixFormats, format) {
+			errs.add(p, "unknown format %q, expected one of %s", format, quoteAll(MathpixFormats))
+		} else if slices.Index(o.ConversionFormats, format) != i {
+			errs.add(p, "format %q is already requested", format)
+		}
+	}
+}
+
+// checkWritableFolder will make sure the folder exists and a file can be created in it
+func checkWritableFolder(errs *ValidationErrors, path, folder string) {
+	if len(folder) == 0 {
+		errs.add(path, "is required")
+		return
+	}
+
+	info, err := os.Stat(folder)
+	if errors.Is(err, os.ErrNotExist) {
+		errs.add(path, "folder %q does not exist", folder)
+		return
+	}
+
+	if err != nil {
+		errs.add(path, "%v", err)
+		return
+	}
+
+	if !info.IsDir() {
+		errs.add(path, "%q is not a folder", folder)
+		return
+	}
+
+	file, err := os.CreateTemp(folder, ".validate-*")
+	if err != nil {
+		errs.add(path, "folder %q is not writable: %v", folder, err)
+		return
+	}
+
+	file.Close()
+	os.Remove(file.Name())
+}
+
+// checkCreatableFolder will make sure the folder is writable, or that it can be created if it does not exist yet
+func checkCreatableFolder(errs *ValidationErrors, path, folder string) {
+	if len(folder) == 0 {
+		return
+	}
+
+	// find the closest parent that exists, that is where the folder will be created
+	existing := folder
+	for {
+		_, err := os.Stat(existing)
+		if err == nil {
+			break
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+
+		existing = parent
+	}
+
+	if existing == folder {
+		checkWritableFolder(errs, path, folder)
+		return
+	}
+
+	var parentErrs ValidationErrors
+	checkWritableFolder(&parentErrs, path, existing)
+	if len(parentErrs) != 0 {
+		errs.add(path, "folder %q can not be created in %q", folder, existing)
+	}
+}
+
+// checkFields will compare the JSON with the Config struct and report every unknown field and every
+// value of the wrong type with its path.
+func checkFields(errs *ValidationErrors, raw any, t reflect.Type, path string) {
+	switch {
+	case t.Kind() == reflect.Struct:
+		obj, ok := raw.(map[string]any)
+		if !ok {
+			errs.add(pathOrRoot(path), "expected an object")
+			return
+		}
+
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if len(name) != 0 && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fieldPath := k
+			if len(path) != 0 {
+				fieldPath = path + "." + k
+			}
+
+			ft, ok := fields[k]
+			if !ok {
+				errs.add(fieldPath, "unknown field")
+				continue
+			}
+
+			checkFields(errs, obj[k], ft, fieldPath)
+		}
+
+	case t.Kind() == reflect.Slice:
+		arr, ok := raw.([]any)
+		if !ok {
+			if raw != nil {
+				errs.add(path, "expected a list")
+			}
+			return
+		}
+
+		for i, item := range arr {
+			checkFields(errs, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+
+	default:
+		// decode the single value to find out if it has the right type
+		b, err := json.Marshal(raw)
+		if err != nil {
+			errs.add(path, "%v", err)
+			return
+		}
+
+		err = json.Unmarshal(b, reflect.New(t).Interface())
+		if err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				errs.add(path, "expected a %s, got a %s", typeErr.Type, typeErr.Value)
+				return
+			}
+
+			errs.add(path, "%v", err)
+		}
+	}
+}
+
+func pathOrRoot(path string) string {
+	if len(path) == 0 {
+		return "$"
+	}
+
+	return path
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, fmt.Sprintf("%q", v))
+	}
+
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,324 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testRegistry = Registry{
+	Storages:       []string{"Google Drive", "Local"},
+	Processors:     []string{"bundle", "chatgpt", "mathpix", "obsidian", "temp_storage"},
+	TraceExporters: []string{"otlp", "stdout"},
+	Notifiers:      []string{"smtp", "webhook"},
+}
+
+// writeConfig will write the JSON to a config file and return its path
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(filename, []byte(contents), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filename
+}
+
+// validConfig returns a configuration with writable folders that has no problems
+func validConfig(t *testing.T) Config {
+	t.Helper()
+
+	folder := t.TempDir()
+	contents := fmt.Sprintf(`{
+		"temp_storage_folder": %q,
+		"source_store": "Google Drive",
+		"bundles": [
+			{"name": "notes", "source_folder": "a", "archive_folder": "b", "dest_attachments_folder": %q, "dest_notes_folder": %q}
+		]
+	}`, folder, folder, folder)
+
+	c, err := LoadConfigSettings(writeConfig(t, contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Validate(testRegistry)
+	if err != nil {
+		t.Fatalf("the base configuration is not valid: %v", err)
+	}
+
+	return c
+}
+
+// problemPaths returns the paths of the problems in the error
+func problemPaths(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors, got %T: %v", err, err)
+	}
+
+	paths := make([]string, 0, len(errs))
+	for _, e := range errs {
+		paths = append(paths, e.Path)
+	}
+
+	return paths
+}
+
+func TestLoadConfigSettingsFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     []string
+	}{
+		{
+			name:     "known fields",
+			contents: `{"source_store": "Google Drive", "concurrency": {"stages": {"mathpix": 2}}}`,
+		},
+		{
+			name:     "unknown top level field",
+			contents: `{"source_stor": "Google Drive"}`,
+			want:     []string{"source_stor"},
+		},
+		{
+			name:     "unknown nested field",
+			contents: `{"cache": {"ttl": "1h", "size": 5}}`,
+			want:     []string{"cache.size"},
+		},
+		{
+			name:     "unknown field in a list",
+			contents: `{"bundles": [{"name": "a"}, {"name": "b", "folder": "c"}]}`,
+			want:     []string{"bundles[1].folder"},
+		},
+		{
+			name:     "wrong type",
+			contents: `{"concurrency": {"workers": "four"}, "processors": "mathpix"}`,
+			want:     []string{"concurrency.workers", "processors"},
+		},
+		{
+			name:     "bad duration",
+			contents: `{"timeouts": {"stage": "soon"}}`,
+			want:     []string{"timeouts.stage"},
+		},
+		{
+			name:     "unknown mathpix option",
+			contents: `{"bundles": [{"name": "a", "mathpix": {"rm_spaces": true, "language": "en"}}]}`,
+			want:     []string{"bundles[0].mathpix.language"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfigSettings(writeConfig(t, tt.contents))
+			got := problemPaths(t, err)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("problems = %v, want %v (%v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigSettingsDefaults(t *testing.T) {
+	c, err := LoadConfigSettings(writeConfig(t, `{"temp_storage_folder": "/tmp/scriptoria"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(c.Processors, DefaultProcessors) {
+		t.Errorf("Processors = %v, want %v", c.Processors, DefaultProcessors)
+	}
+
+	if c.ArtifactStorageFolder != filepath.Join("/tmp/scriptoria", "artifacts") {
+		t.Errorf("ArtifactStorageFolder = %q", c.ArtifactStorageFolder)
+	}
+
+	if c.Concurrency.Workers != DefaultStageWorkers || c.Concurrency.MaxDocuments != DefaultMaxDocuments {
+		t.Errorf("Concurrency = %+v", c.Concurrency)
+	}
+
+	if c.Timeouts.Stage != DefaultStageTimeout || c.Timeouts.MathpixPoll != DefaultMathpixPollTimeout {
+		t.Errorf("Timeouts = %+v", c.Timeouts)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []string
+	}{
+		{
+			name:   "valid",
+			modify: func(c *Config) {},
+		},
+		{
+			name:   "missing temp folder",
+			modify: func(c *Config) { c.TempStorageFolder = "" },
+			want:   []string{"temp_storage_folder"},
+		},
+		{
+			name:   "temp folder does not exist",
+			modify: func(c *Config) { c.TempStorageFolder = filepath.Join(c.TempStorageFolder, "missing") },
+			want:   []string{"temp_storage_folder"},
+		},
+		{
+			name:   "unknown storage",
+			modify: func(c *Config) { c.SourceStore = "Dropbox" },
+			want:   []string{"source_store"},
+		},
+		{
+			name:   "unknown and repeated processors",
+			modify: func(c *Config) { c.Processors = []string{"mathpix", "ocr", "mathpix"} },
+			want:   []string{"processors[1]", "processors[2]"},
+		},
+		{
+			name:   "sample ratio out of range",
+			modify: func(c *Config) { c.Tracing.SampleRatio = 2 },
+			want:   []string{"tracing.sample_ratio"},
+		},
+		{
+			name: "concurrency",
+			modify: func(c *Config) {
+				c.Concurrency.Workers = 0
+				c.Concurrency.Stages = map[string]int{"mathpix": 0, "ocr": 2}
+			},
+			want: []string{"concurrency.workers", "concurrency.stages.mathpix", "concurrency.stages.ocr"},
+		},
+		{
+			name: "timeouts",
+			modify: func(c *Config) {
+				c.Timeouts.MathpixPoll = Duration(-time.Second)
+				c.Timeouts.Stages = map[string]Duration{"chatgpt": 0, "ocr": Duration(time.Minute)}
+			},
+			want: []string{"timeouts.mathpix_poll", "timeouts.stages.chatgpt", "timeouts.stages.ocr"},
+		},
+		{
+			name: "limits",
+			modify: func(c *Config) {
+				c.Limits.Mathpix.TokensPerMinute = 100
+				c.Limits.OpenAI.DailyBudget = -1
+			},
+			want: []string{"limits.openai.daily_budget", "limits.mathpix.tokens_per_minute"},
+		},
+		{
+			name:   "negative price",
+			modify: func(c *Config) { c.Prices.MathpixPerPage = -0.01 },
+			want:   []string{"prices.mathpix_per_page"},
+		},
+		{
+			name:   "unknown notifier",
+			modify: func(c *Config) { c.Notifications.Targets = []NotificationTarget{{Name: "chat", Type: "irc"}} },
+			want:   []string{"notifications.targets[0].type"},
+		},
+		{
+			name: "repeated bundle",
+			modify: func(c *Config) {
+				c.Bundles = append(c.Bundles, c.Bundles[0])
+			},
+			want: []string{"bundles[1].name", "bundles[1].source_folder"},
+		},
+		{
+			name: "bundle settings",
+			modify: func(c *Config) {
+				c.Bundles[0].ArchiveFolder = ""
+				c.Bundles[0].NotifyOn = []string{"completed", "started"}
+			},
+			want: []string{"bundles[0].archive_folder", "bundles[0].notify_on[1]"},
+		},
+		{
+			name: "mathpix options",
+			modify: func(c *Config) {
+				c.Bundles[0].Mathpix = MathpixOptions{
+					MathInlineDelimiters:  []string{"$"},
+					MathDisplayDelimiters: []string{"$$", "$$"},
+					PageRanges:            "1-3,x",
+					AlphabetsAllowed:      map[string]bool{"hi": false, "xx": true},
+					ConversionFormats:     []string{"docx", "pdf", "docx"},
+				}
+			},
+			want: []string{
+				"bundles[0].mathpix.math_inline_delimiters",
+				"bundles[0].mathpix.page_ranges",
+				"bundles[0].mathpix.alphabets_allowed.xx",
+				"bundles[0].mathpix.conversion_formats[1]",
+				"bundles[0].mathpix.conversion_formats[2]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig(t)
+			tt.modify(&c)
+
+			got := problemPaths(t, c.Validate(testRegistry))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("problems = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMathpixPageRanges(t *testing.T) {
+	tests := []struct {
+		pageRanges string
+		valid      bool
+	}{
+		{pageRanges: "1", valid: true},
+		{pageRanges: "2,4-6", valid: true},
+		{pageRanges: "1--1", valid: true},
+		{pageRanges: "-2", valid: true},
+		{pageRanges: "1-", valid: false},
+		{pageRanges: "1,,2", valid: false},
+		{pageRanges: "one", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pageRanges, func(t *testing.T) {
+			var errs ValidationErrors
+			MathpixOptions{PageRanges: tt.pageRanges}.validate(&errs, "")
+			if (len(errs) == 0) != tt.valid {
+				t.Errorf("page ranges %q valid = %v, want %v", tt.pageRanges, len(errs) == 0, tt.valid)
+			}
+		})
+	}
+}
+
+func TestStageLookups(t *testing.T) {
+	concurrency := ConcurrencyConfig{Workers: 4, Stages: map[string]int{"mathpix": 2}}
+	timeouts := TimeoutsConfig{Stage: Duration(time.Minute), Stages: map[string]Duration{"chatgpt": Duration(time.Second)}}
+
+	tests := []struct {
+		stage       string
+		wantWorkers int
+		wantTimeout time.Duration
+	}{
+		{stage: "mathpix", wantWorkers: 2, wantTimeout: time.Minute},
+		{stage: "chatgpt", wantWorkers: 4, wantTimeout: time.Second},
+		{stage: "bundle", wantWorkers: 4, wantTimeout: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.stage, func(t *testing.T) {
+			if got := concurrency.StageWorkers(tt.stage); got != tt.wantWorkers {
+				t.Errorf("StageWorkers(%q) = %d, want %d", tt.stage, got, tt.wantWorkers)
+			}
+
+			if got := timeouts.StageTimeout(tt.stage); got != tt.wantTimeout {
+				t.Errorf("StageTimeout(%q) = %s, want %s", tt.stage, got, tt.wantTimeout)
+			}
+		})
+	}
+}
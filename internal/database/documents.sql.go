@@ -14,19 +14,25 @@ import (
 
 const createDocument = `-- name: CreateDocument :one
 INSERT INTO documents (
-    source_store, source_id, source_name
-) VALUES ( $1, $2, $3)
-RETURNING id, created_at, updated_at, source_store, source_id, source_name, processed_at, processing_status
+    source_store, source_id, source_name, source_folder_id
+) VALUES ( $1, $2, $3, $4)
+RETURNING id, created_at, updated_at, source_store, source_id, source_name, processed_at, processing_status, source_folder_id, resume_stage
 `
 
 type CreateDocumentParams struct {
-	SourceStore string
-	SourceID    string
-	SourceName  string
+	SourceStore    string
+	SourceID       string
+	SourceName     string
+	SourceFolderID string
 }
 
 func (q *Queries) CreateDocument(ctx context.Context, arg CreateDocumentParams) (Document, error) {
-	row := q.db.QueryRowContext(ctx, createDocument, arg.SourceStore, arg.SourceID, arg.SourceName)
+	row := q.db.QueryRowContext(ctx, createDocument,
+		arg.SourceStore,
+		arg.SourceID,
+		arg.SourceName,
+		arg.SourceFolderID,
+	)
 	var i Document
 	err := row.Scan(
 		&i.ID,
@@ -37,12 +43,14 @@ func (q *Queries) CreateDocument(ctx context.Context, arg CreateDocumentParams)
 		&i.SourceName,
 		&i.ProcessedAt,
 		&i.ProcessingStatus,
+		&i.SourceFolderID,
+		&i.ResumeStage,
 	)
 	return i, err
 }
 
 const findDocumentBySourceId = `-- name: FindDocumentBySourceId :one
-SELECT id, created_at, updated_at, source_store, source_id, source_name, processed_at, processing_status FROM documents
+SELECT id, created_at, updated_at, source_store, source_id, source_name, processed_at, processing_status, source_folder_id, resume_stage FROM documents
 WHERE source_id = $1
 `
 
@@ -58,12 +66,14 @@ func (q *Queries) FindDocumentBySourceId(ctx context.Context, sourceID string) (
 		&i.SourceName,
 		&i.ProcessedAt,
 		&i.ProcessingStatus,
+		&i.SourceFolderID,
+		&i.ResumeStage,
 	)
 	return i, err
 }
 
 const getDocumentById = `-- name: GetDocumentById :one
-SELECT id, created_at, updated_at, source_store, source_id, source_name, processed_at, processing_status FROM documents
+SELECT id, created_at, updated_at, source_store, source_id, source_name, processed_at, processing_status, source_folder_id, resume_stage FROM documents
 WHERE id = $1
 `
 
@@ -79,17 +89,172 @@ func (q *Queries) GetDocumentById(ctx context.Context, id uuid.UUID) (Document,
 		&i.SourceName,
 		&i.ProcessedAt,
 		&i.ProcessingStatus,
+		&i.SourceFolderID,
+		&i.ResumeStage,
 	)
 	return i, err
 }
 
+const getResumableDocuments = `-- name: GetResumableDocuments :many
+SELECT id, created_at, updated_at, source_store, source_id, source_name, processed_at, processing_status, source_folder_id, resume_stage FROM documents
+WHERE resume_stage IS NOT NULL
+ORDER BY created_at
+`
+
+func (q *Queries) GetResumableDocuments(ctx context.Context) ([]Document, error) {
+	rows, err := q.db.QueryContext(ctx, getResumableDocuments)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Document
+	for rows.Next() {
+		var i Document
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SourceStore,
+			&i.SourceID,
+			&i.SourceName,
+			&i.ProcessedAt,
+			&i.ProcessingStatus,
+			&i.SourceFolderID,
+			&i.ResumeStage,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDocuments = `-- name: ListDocuments :many
+SELECT id, created_at, updated_at, source_store, source_id, source_name, processed_at, processing_status, source_folder_id, resume_stage FROM documents
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2
+`
+
+type ListDocumentsParams struct {
+	Limit  int32
+	Offset int32
+}
+
+func (q *Queries) ListDocuments(ctx context.Context, arg ListDocumentsParams) ([]Document, error) {
+	rows, err := q.db.QueryContext(ctx, listDocuments, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Document
+	for rows.Next() {
+		var i Document
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SourceStore,
+			&i.SourceID,
+			&i.SourceName,
+			&i.ProcessedAt,
+			&i.ProcessingStatus,
+			&i.SourceFolderID,
+			&i.ResumeStage,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFailedDocuments = `-- name: ListFailedDocuments :many
+SELECT d.id, d.created_at, d.updated_at, d.source_store, d.source_id, d.source_name, d.processed_at, d.processing_status, d.source_folder_id, d.resume_stage FROM documents d
+WHERE (
+    SELECT e.status FROM document_events e
+    WHERE e.document_id = d.id
+    ORDER BY e.created_at DESC
+    LIMIT 1
+) = 'failed'
+ORDER BY d.created_at DESC
+LIMIT $1 OFFSET $2
+`
+
+type ListFailedDocumentsParams struct {
+	Limit  int32
+	Offset int32
+}
+
+func (q *Queries) ListFailedDocuments(ctx context.Context, arg ListFailedDocumentsParams) ([]Document, error) {
+	rows, err := q.db.QueryContext(ctx, listFailedDocuments, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Document
+	for rows.Next() {
+		var i Document
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SourceStore,
+			&i.SourceID,
+			&i.SourceName,
+			&i.ProcessedAt,
+			&i.ProcessingStatus,
+			&i.SourceFolderID,
+			&i.ResumeStage,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setDocumentResumeStage = `-- name: SetDocumentResumeStage :exec
+UPDATE documents
+SET resume_stage = $2,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type SetDocumentResumeStageParams struct {
+	ID          uuid.UUID
+	ResumeStage sql.NullString
+}
+
+func (q *Queries) SetDocumentResumeStage(ctx context.Context, arg SetDocumentResumeStageParams) error {
+	_, err := q.db.ExecContext(ctx, setDocumentResumeStage, arg.ID, arg.ResumeStage)
+	return err
+}
+
 const updateDocumentProcessed = `-- name: UpdateDocumentProcessed :one
 UPDATE documents
 SET processed_at = $2, 
     processing_status = $3,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = $1
-RETURNING id, created_at, updated_at, source_store, source_id, source_name, processed_at, processing_status
+RETURNING id, created_at, updated_at, source_store, source_id, source_name, processed_at, processing_status, source_folder_id, resume_stage
 `
 
 type UpdateDocumentProcessedParams struct {
@@ -110,6 +275,8 @@ func (q *Queries) UpdateDocumentProcessed(ctx context.Context, arg UpdateDocumen
 		&i.SourceName,
 		&i.ProcessedAt,
 		&i.ProcessingStatus,
+		&i.SourceFolderID,
+		&i.ResumeStage,
 	)
 	return i, err
 }
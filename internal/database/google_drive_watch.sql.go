@@ -14,16 +14,18 @@ import (
 
 const createGoogleDriveWatch = `-- name: CreateGoogleDriveWatch :one
 INSERT INTO google_drive_watch (
-    channel_id, resource_id, expires_at, webhook_url
-) VALUES ( $1, $2, $3, $4)
-RETURNING id, created_at, updated_at, channel_id, resource_id, expires_at, webhook_url
+    channel_id, resource_id, expires_at, webhook_url, token, channel_resource_id
+) VALUES ( $1, $2, $3, $4, $5)
+RETURNING id, created_at, updated_at, channel_id, resource_id, expires_at, webhook_url, token, channel_resource_id
 `
 
 type CreateGoogleDriveWatchParams struct {
-	ChannelID  string
-	ResourceID string
-	ExpiresAt  int64
-	WebhookUrl string
+	ChannelID         string
+	ResourceID        string
+	ExpiresAt         int64
+	WebhookUrl        string
+	Token             string
+	ChannelResourceID string
 }
 
 func (q *Queries) CreateGoogleDriveWatch(ctx context.Context, arg CreateGoogleDriveWatchParams) (GoogleDriveWatch, error) {
@@ -32,6 +34,8 @@ func (q *Queries) CreateGoogleDriveWatch(ctx context.Context, arg CreateGoogleDr
 		arg.ResourceID,
 		arg.ExpiresAt,
 		arg.WebhookUrl,
+		arg.Token,
+		arg.ChannelResourceID,
 	)
 	var i GoogleDriveWatch
 	err := row.Scan(
@@ -42,12 +46,24 @@ func (q *Queries) CreateGoogleDriveWatch(ctx context.Context, arg CreateGoogleDr
 		&i.ResourceID,
 		&i.ExpiresAt,
 		&i.WebhookUrl,
+		&i.Token,
+		&i.ChannelResourceID,
 	)
 	return i, err
 }
 
+const deleteGoogleDriveWatchByFolderID = `-- name: DeleteGoogleDriveWatchByFolderID :exec
+DELETE FROM google_drive_watch
+WHERE resource_id = $1
+`
+
+func (q *Queries) DeleteGoogleDriveWatchByFolderID(ctx context.Context, resourceID string) error {
+	_, err := q.db.ExecContext(ctx, deleteGoogleDriveWatchByFolderID, resourceID)
+	return err
+}
+
 const getLatestGoogleDriveWatch = `-- name: GetLatestGoogleDriveWatch :one
-SELECT id, created_at, updated_at, channel_id, resource_id, expires_at, webhook_url FROM google_drive_watch
+SELECT id, created_at, updated_at, channel_id, resource_id, expires_at, webhook_url, token, channel_resource_id FROM google_drive_watch
 ORDER BY created_at DESC
 LIMIT 1
 `
@@ -63,12 +79,14 @@ func (q *Queries) GetLatestGoogleDriveWatch(ctx context.Context) (GoogleDriveWat
 		&i.ResourceID,
 		&i.ExpiresAt,
 		&i.WebhookUrl,
+		&i.Token,
+		&i.ChannelResourceID,
 	)
 	return i, err
 }
 
 const getWatchEntriesByFolderIDs = `-- name: GetWatchEntriesByFolderIDs :many
-SELECT DISTINCT ON (resource_id) id, created_at, updated_at, channel_id, resource_id, expires_at, webhook_url
+SELECT DISTINCT ON (resource_id) id, created_at, updated_at, channel_id, resource_id, expires_at, webhook_url, token, channel_resource_id
 FROM google_drive_watch
 WHERE resource_id = ANY($1::text[])
 ORDER BY resource_id, created_at DESC
@@ -91,6 +109,8 @@ func (q *Queries) GetWatchEntriesByFolderIDs(ctx context.Context, resourceIds []
 			&i.ResourceID,
 			&i.ExpiresAt,
 			&i.WebhookUrl,
+			&i.Token,
+			&i.ChannelResourceID,
 		); err != nil {
 			return nil, err
 		}
@@ -111,17 +131,21 @@ SET channel_id = $2,
     resource_id = $3,
     expires_at = $4,
     webhook_url = $5,
+    token = $6,
+    channel_resource_id = $7,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = $1
-RETURNING id, created_at, updated_at, channel_id, resource_id, expires_at, webhook_url
+RETURNING id, created_at, updated_at, channel_id, resource_id, expires_at, webhook_url, token, channel_resource_id
 `
 
 type UpdateGoogleDriveWatchParams struct {
-	ID         uuid.UUID
-	ChannelID  string
-	ResourceID string
-	ExpiresAt  int64
-	WebhookUrl string
+	ID                uuid.UUID
+	ChannelID         string
+	ResourceID        string
+	ExpiresAt         int64
+	WebhookUrl        string
+	Token             string
+	ChannelResourceID string
 }
 
 func (q *Queries) UpdateGoogleDriveWatch(ctx context.Context, arg UpdateGoogleDriveWatchParams) (GoogleDriveWatch, error) {
@@ -131,6 +155,8 @@ func (q *Queries) UpdateGoogleDriveWatch(ctx context.Context, arg UpdateGoogleDr
 		arg.ResourceID,
 		arg.ExpiresAt,
 		arg.WebhookUrl,
+		arg.Token,
+		arg.ChannelResourceID,
 	)
 	var i GoogleDriveWatch
 	err := row.Scan(
@@ -141,6 +167,8 @@ func (q *Queries) UpdateGoogleDriveWatch(ctx context.Context, arg UpdateGoogleDr
 		&i.ResourceID,
 		&i.ExpiresAt,
 		&i.WebhookUrl,
+		&i.Token,
+		&i.ChannelResourceID,
 	)
 	return i, err
 }
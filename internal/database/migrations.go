@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+//go:embed sql/schema/*.sql
+var schemaFS embed.FS
+
+// NewMigrator will create a goose provider for the migrations embedded in the binary.  The provider holds a
+// Postgres advisory lock while it runs so two replicas starting at the same time do not race.
+func NewMigrator(db *sql.DB) (*goose.Provider, error) {
+	migrations, err := fs.Sub(schemaFS, "sql/schema")
+	if err != nil {
+		return nil, err
+	}
+
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+
+	return goose.NewProvider(goose.DialectPostgres, db, migrations, goose.WithSessionLocker(locker))
+}
+
+// MigrateUp will apply all of the pending migrations
+func MigrateUp(ctx context.Context, db *sql.DB) ([]*goose.MigrationResult, error) {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrator.Up(ctx)
+}
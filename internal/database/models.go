@@ -11,6 +11,34 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Name       string
+	Prefix     string
+	TokenHash  string
+	Scopes     []string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type Bundle struct {
+	ID                    uuid.UUID
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	Name                  string
+	SourceFolder          string
+	ArchiveFolder         string
+	DestAttachmentsFolder string
+	DestNotesFolder       string
+	Enabled               bool
+	NotifyOn              []string
+	MathpixOptions        string
+	Source                string
+}
+
 type Document struct {
 	ID               uuid.UUID
 	CreatedAt        time.Time
@@ -20,14 +48,75 @@ type Document struct {
 	SourceName       string
 	ProcessedAt      sql.NullTime
 	ProcessingStatus sql.NullString
+	SourceFolderID   string
+	ResumeStage      sql.NullString
 }
 
-type GoogleDriveWatch struct {
+type DocumentEvent struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	DocumentID   uuid.UUID
+	Stage        string
+	Status       string
+	ErrorMessage sql.NullString
+	DurationMs   sql.NullInt64
+	Attempt      int32
+	BytesIn      sql.NullInt64
+	BytesOut     sql.NullInt64
+	ArtifactHash sql.NullString
+}
+
+type DocumentLog struct {
+	ID         int64
+	CreatedAt  time.Time
+	DocumentID uuid.UUID
+	LoggedAt   time.Time
+	Level      string
+	Message    string
+	Attrs      string
+}
+
+type DocumentUsage struct {
 	ID         uuid.UUID
 	CreatedAt  time.Time
-	UpdatedAt  time.Time
-	ChannelID  string
-	ResourceID string
-	ExpiresAt  int64
-	WebhookUrl string
+	DocumentID uuid.UUID
+	Stage      string
+	Provider   string
+	Unit       string
+	Amount     int64
+}
+
+type GoogleDriveWatch struct {
+	ID                uuid.UUID
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	ChannelID         string
+	ResourceID        string
+	ExpiresAt         int64
+	WebhookUrl        string
+	Token             string
+	ChannelResourceID string
+}
+
+type NotificationDelivery struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DocumentID  uuid.UUID
+	EventType   string
+	TargetName  string
+	TargetType  string
+	Payload     string
+	Status      string
+	Attempts    int32
+	LastError   sql.NullString
+	DeliveredAt sql.NullTime
+}
+
+type UsageLedger struct {
+	Day       time.Time
+	Provider  string
+	Unit      string
+	Amount    int64
+	UpdatedAt time.Time
 }
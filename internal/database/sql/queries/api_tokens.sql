@@ -0,0 +1,26 @@
+-- name: CreateApiToken :one
+INSERT INTO api_tokens (
+    name, prefix, token_hash, scopes, expires_at
+) VALUES ( $1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetApiTokenByHash :one
+SELECT * FROM api_tokens
+WHERE token_hash = $1;
+
+-- name: ListApiTokens :many
+SELECT * FROM api_tokens
+ORDER BY created_at;
+
+-- name: RevokeApiToken :one
+UPDATE api_tokens
+SET revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP),
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: TouchApiToken :exec
+UPDATE api_tokens
+SET last_used_at = CURRENT_TIMESTAMP
+WHERE id = $1
+  AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute');
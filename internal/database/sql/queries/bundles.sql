@@ -0,0 +1,61 @@
+-- name: CreateBundle :one
+INSERT INTO bundles (
+    name, source_folder, archive_folder, dest_attachments_folder, dest_notes_folder, enabled, notify_on, mathpix_options
+) VALUES ( $1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: SeedBundle :exec
+INSERT INTO bundles (
+    name, source_folder, archive_folder, dest_attachments_folder, dest_notes_folder, notify_on, mathpix_options, source
+) VALUES ( $1, $2, $3, $4, $5, $6, $7, 'config')
+ON CONFLICT (source_folder) DO UPDATE
+SET name = EXCLUDED.name,
+    archive_folder = EXCLUDED.archive_folder,
+    dest_attachments_folder = EXCLUDED.dest_attachments_folder,
+    dest_notes_folder = EXCLUDED.dest_notes_folder,
+    notify_on = EXCLUDED.notify_on,
+    mathpix_options = EXCLUDED.mathpix_options,
+    source = 'config',
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: DeleteConfigBundlesNotIn :exec
+DELETE FROM bundles
+WHERE source = 'config'
+  AND NOT (source_folder = ANY(sqlc.arg(source_folders)::TEXT[]));
+
+-- name: UpdateBundle :one
+UPDATE bundles
+SET name = $2,
+    source_folder = $3,
+    archive_folder = $4,
+    dest_attachments_folder = $5,
+    dest_notes_folder = $6,
+    notify_on = $7,
+    mathpix_options = $8,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: SetBundleEnabled :one
+UPDATE bundles
+SET enabled = $2,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteBundle :exec
+DELETE FROM bundles
+WHERE id = $1;
+
+-- name: GetBundleById :one
+SELECT * FROM bundles
+WHERE id = $1;
+
+-- name: ListBundles :many
+SELECT * FROM bundles
+ORDER BY name;
+
+-- name: ListEnabledBundles :many
+SELECT * FROM bundles
+WHERE enabled = TRUE
+ORDER BY name;
@@ -0,0 +1,25 @@
+-- name: CreateDocumentEvent :one
+INSERT INTO document_events (
+    document_id, stage, status, error_message, duration_ms, attempt, bytes_in, bytes_out, artifact_hash
+) VALUES ( $1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: GetDocumentEventsByDocumentId :many
+SELECT * FROM document_events
+WHERE document_id = $1
+ORDER BY created_at;
+
+-- name: CountDocumentStageAttempts :one
+SELECT COUNT(*) FROM document_events
+WHERE document_id = $1
+  AND stage = $2
+  AND status IN ('started', 'retried');
+
+-- name: GetLatestStageArtifact :one
+SELECT * FROM document_events
+WHERE document_id = $1
+  AND stage = $2
+  AND status = 'succeeded'
+  AND artifact_hash IS NOT NULL
+ORDER BY created_at DESC
+LIMIT 1;
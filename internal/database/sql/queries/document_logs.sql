@@ -0,0 +1,19 @@
+-- name: CreateDocumentLog :exec
+INSERT INTO document_logs (
+    document_id, logged_at, level, message, attrs
+) VALUES ( $1, $2, $3, $4, $5);
+
+-- name: GetDocumentLogsByDocumentId :many
+SELECT * FROM document_logs
+WHERE document_id = $1
+ORDER BY id;
+
+-- name: TrimDocumentLogs :exec
+DELETE FROM document_logs
+WHERE document_id = $1
+  AND id <= (
+    SELECT id FROM document_logs AS newest
+    WHERE newest.document_id = $1
+    ORDER BY newest.id DESC
+    OFFSET sqlc.arg(keep)::int LIMIT 1
+  );
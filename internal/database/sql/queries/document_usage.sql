@@ -0,0 +1,26 @@
+-- name: CreateDocumentUsage :exec
+INSERT INTO document_usage (
+    document_id, stage, provider, unit, amount
+) VALUES ( $1, $2, $3, $4, $5);
+
+-- name: GetDocumentUsageByDocumentId :many
+SELECT * FROM document_usage
+WHERE document_id = $1
+ORDER BY created_at, provider, unit;
+
+-- name: GetUsageReport :many
+SELECT
+    documents.source_folder_id,
+    COALESCE(bundles.name, '')::TEXT AS bundle_name,
+    to_char(document_usage.created_at, 'YYYY-MM')::TEXT AS month,
+    document_usage.provider,
+    document_usage.unit,
+    SUM(document_usage.amount)::BIGINT AS amount,
+    COUNT(DISTINCT document_usage.document_id)::BIGINT AS documents
+FROM document_usage
+JOIN documents ON documents.id = document_usage.document_id
+LEFT JOIN bundles ON bundles.source_folder = documents.source_folder_id
+WHERE document_usage.created_at >= sqlc.arg(from_time)
+  AND document_usage.created_at < sqlc.arg(to_time)
+GROUP BY documents.source_folder_id, bundles.name, month, document_usage.provider, document_usage.unit
+ORDER BY month, bundle_name, documents.source_folder_id, document_usage.provider, document_usage.unit;
@@ -1,7 +1,7 @@
 -- name: CreateDocument :one
 INSERT INTO documents (
-    source_store, source_id, source_name
-) VALUES ( $1, $2, $3)
+    source_store, source_id, source_name, source_folder_id
+) VALUES ( $1, $2, $3, $4)
 RETURNING *;
 
 
@@ -22,3 +22,30 @@ SELECT * FROM documents
 WHERE source_id = $1
 ;
 
+
+-- name: SetDocumentResumeStage :exec
+UPDATE documents
+SET resume_stage = $2,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: GetResumableDocuments :many
+SELECT * FROM documents
+WHERE resume_stage IS NOT NULL
+ORDER BY created_at;
+
+-- name: ListDocuments :many
+SELECT * FROM documents
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2;
+
+-- name: ListFailedDocuments :many
+SELECT d.* FROM documents d
+WHERE (
+    SELECT e.status FROM document_events e
+    WHERE e.document_id = d.id
+    ORDER BY e.created_at DESC
+    LIMIT 1
+) = 'failed'
+ORDER BY d.created_at DESC
+LIMIT $1 OFFSET $2;
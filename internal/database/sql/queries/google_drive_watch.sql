@@ -1,7 +1,7 @@
 -- name: CreateGoogleDriveWatch :one
 INSERT INTO google_drive_watch (
-    channel_id, resource_id, expires_at, webhook_url
-) VALUES ( $1, $2, $3, $4)
+    channel_id, resource_id, expires_at, webhook_url, token, channel_resource_id
+) VALUES ( $1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: UpdateGoogleDriveWatch :one
@@ -10,6 +10,8 @@ SET channel_id = $2,
     resource_id = $3,
     expires_at = $4,
     webhook_url = $5,
+    token = $6,
+    channel_resource_id = $7,
     updated_at = CURRENT_TIMESTAMP
 WHERE id = $1
 RETURNING *;
@@ -24,3 +26,7 @@ SELECT DISTINCT ON (resource_id) *
 FROM google_drive_watch
 WHERE resource_id = ANY(sqlc.arg(resource_ids)::text[])
 ORDER BY resource_id, created_at DESC;
+
+-- name: DeleteGoogleDriveWatchByFolderID :exec
+DELETE FROM google_drive_watch
+WHERE resource_id = $1;
@@ -0,0 +1,30 @@
+-- name: CreateNotificationDelivery :one
+INSERT INTO notification_deliveries (
+    document_id, event_type, target_name, target_type, payload, status
+) VALUES ( $1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: UpdateNotificationDelivery :one
+UPDATE notification_deliveries
+SET status = $2,
+    attempts = $3,
+    last_error = $4,
+    delivered_at = $5,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: ListNotificationDeliveries :many
+SELECT * FROM notification_deliveries
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2;
+
+-- name: GetNotificationDeliveriesByDocumentId :many
+SELECT * FROM notification_deliveries
+WHERE document_id = $1
+ORDER BY created_at;
+
+-- name: GetPendingNotificationDeliveries :many
+SELECT * FROM notification_deliveries
+WHERE status = 'pending'
+ORDER BY created_at;
@@ -0,0 +1,14 @@
+-- name: AddUsage :exec
+INSERT INTO usage_ledger (
+    day, provider, unit, amount
+) VALUES ( $1, $2, $3, $4)
+ON CONFLICT (day, provider, unit) DO UPDATE
+SET amount = usage_ledger.amount + EXCLUDED.amount,
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: GetUsageTotal :one
+SELECT COALESCE(SUM(amount), 0)::BIGINT AS total FROM usage_ledger
+WHERE provider = sqlc.arg(provider)
+  AND unit = ANY(sqlc.arg(units)::TEXT[])
+  AND day >= sqlc.arg(from_day)
+  AND day <= sqlc.arg(to_day);
@@ -7,7 +7,7 @@ DROP COLUMN destination_name;
 
 
 -- +goose Down
-ALTER TABLE plunges 
+ALTER TABLE documents
 ADD COLUMN transferred_at TIMESTAMP,
 ADD COLUMN destination_store TEXT,
 ADD COLUMN destination_id TEXT UNIQUE,
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE document_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+
+    stage TEXT NOT NULL,
+    status TEXT NOT NULL,
+    error_message TEXT,
+    duration_ms BIGINT,
+    attempt INTEGER NOT NULL DEFAULT 1,
+    bytes_in BIGINT,
+    bytes_out BIGINT
+);
+
+CREATE INDEX document_events_document_id_idx ON document_events (document_id, created_at);
+
+
+-- +goose Down
+DROP TABLE document_events;
@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE documents
+ADD COLUMN source_folder_id TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE document_events
+ADD COLUMN artifact_hash TEXT;
+
+
+-- +goose Down
+ALTER TABLE document_events
+DROP COLUMN artifact_hash;
+
+ALTER TABLE documents
+DROP COLUMN source_folder_id;
@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE documents
+ADD COLUMN resume_stage TEXT;
+
+
+-- +goose Down
+ALTER TABLE documents
+DROP COLUMN resume_stage;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE bundles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    name TEXT NOT NULL UNIQUE,
+    source_folder TEXT NOT NULL UNIQUE,
+    archive_folder TEXT NOT NULL,
+    dest_attachments_folder TEXT NOT NULL,
+    dest_notes_folder TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+
+-- +goose Down
+DROP TABLE bundles;
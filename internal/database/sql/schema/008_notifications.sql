@@ -0,0 +1,28 @@
+-- +goose Up
+ALTER TABLE bundles
+ADD COLUMN notify_on TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE notification_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+
+    event_type TEXT NOT NULL,
+    target_name TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX notification_deliveries_document_id_idx ON notification_deliveries (document_id, created_at);
+
+
+-- +goose Down
+DROP TABLE notification_deliveries;
+
+ALTER TABLE bundles
+DROP COLUMN notify_on;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE api_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+ALTER TABLE google_drive_watch
+ADD COLUMN token VARCHAR(100) NOT NULL DEFAULT '';
+
+
+-- +goose Down
+ALTER TABLE google_drive_watch
+DROP COLUMN token;
+
+DROP TABLE api_tokens;
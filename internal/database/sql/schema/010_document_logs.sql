@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE document_logs (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+
+    logged_at TIMESTAMP NOT NULL,
+    level TEXT NOT NULL,
+    message TEXT NOT NULL,
+    attrs TEXT NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX document_logs_document_id_idx ON document_logs (document_id, id);
+
+
+-- +goose Down
+DROP TABLE document_logs;
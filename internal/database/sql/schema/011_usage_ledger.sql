@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE usage_ledger (
+    day DATE NOT NULL,
+    provider TEXT NOT NULL,
+    unit TEXT NOT NULL,
+    amount BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (day, provider, unit)
+);
+
+
+-- +goose Down
+DROP TABLE usage_ledger;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE document_usage (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+
+    stage TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    unit TEXT NOT NULL,
+    amount BIGINT NOT NULL
+);
+
+CREATE INDEX document_usage_document_id_idx ON document_usage (document_id);
+CREATE INDEX document_usage_created_at_idx ON document_usage (created_at);
+
+
+-- +goose Down
+DROP TABLE document_usage;
@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE bundles
+ADD COLUMN mathpix_options TEXT NOT NULL DEFAULT '{}';
+
+
+-- +goose Down
+ALTER TABLE bundles
+DROP COLUMN mathpix_options;
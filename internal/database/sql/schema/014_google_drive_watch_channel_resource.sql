@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE google_drive_watch
+ADD COLUMN channel_resource_id VARCHAR(500) NOT NULL DEFAULT '';
+
+
+-- +goose Down
+ALTER TABLE google_drive_watch
+DROP COLUMN channel_resource_id;
@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE bundles
+ADD COLUMN source TEXT NOT NULL DEFAULT 'api';
+
+
+-- +goose Down
+ALTER TABLE bundles
+DROP COLUMN source;
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/utils"
+)
+
+// NewMiddleware will create the middleware for the /v1 routes registered on the mux.  GET and HEAD requests
+// require the read scope and every other method requires write, unless the route was given a scope with Require.
+func NewMiddleware(store TokenStore, mux *http.ServeMux) *Middleware {
+	return &Middleware{
+		store:    store,
+		mux:      mux,
+		prefixes: map[string]Scope{protectedPrefix: ScopeRead},
+		scopes:   make(map[string]Scope),
+		public:   make(map[string]bool),
+	}
+}
+
+// Protect will require a token with at least the scope for the routes under the path prefix
+func (m *Middleware) Protect(prefix string, scope Scope) {
+	m.prefixes[prefix] = scope
+}
+
+// Require will set the scope needed to call the route pattern
+func (m *Middleware) Require(pattern string, scope Scope) {
+	m.scopes[pattern] = scope
+}
+
+// Exempt will let the route pattern be called without a token, the route must protect itself
+func (m *Middleware) Exempt(pattern string) {
+	m.public[pattern] = true
+}
+
+// Handler will reject the requests to a /v1 route without a valid token that has the scope the route requires
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the pattern is empty for requests that do not match a route, those still need a token
+		_, pattern := m.mux.Handler(r)
+		minimum, protected := m.protectedBy(r.URL.Path)
+		if m.public[pattern] || !protected {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		value := bearerToken(r)
+		if len(value) == 0 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scriptoria"`)
+			utils.RespondWithError(w, http.StatusUnauthorized, "An API token is required", nil)
+			return
+		}
+
+		token, err := m.authenticate(r, value)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scriptoria", error="invalid_token"`)
+			utils.RespondWithError(w, http.StatusUnauthorized, "The API token is not valid", err)
+			return
+		}
+
+		required := m.requiredScope(r, pattern, minimum)
+		if !Allows(token.Scopes, required) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scriptoria", error="insufficient_scope", scope="`+string(required)+`"`)
+			utils.RespondWithError(w, http.StatusForbidden, "The API token requires the "+string(required)+" scope", nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate will find the token by its hash and check it has not been revoked or expired
+func (m *Middleware) authenticate(r *http.Request, value string) (database.ApiToken, error) {
+	token, err := m.store.GetApiTokenByHash(r.Context(), HashToken(value))
+	if errors.Is(err, sql.ErrNoRows) {
+		return token, errors.New("unknown token")
+	} else if err != nil {
+		return token, err
+	}
+
+	if token.RevokedAt.Valid {
+		return token, errors.New("token was revoked")
+	}
+
+	if token.ExpiresAt.Valid && time.Now().After(token.ExpiresAt.Time) {
+		return token, errors.New("token expired")
+	}
+
+	// the last use is only recorded once a minute so it is not written on every request
+	err = m.store.TouchApiToken(r.Context(), token.ID)
+	if err != nil {
+		slog.Warn("Failed to record the use of the API token", "tokenID", token.ID, "error", err)
+	}
+
+	return token, nil
+}
+
+// protectedBy returns the lowest scope of the protected prefix the path is under.  When the path is under
+// several prefixes, the longest one is used so a prefix nested in another keeps its own scope.
+func (m *Middleware) protectedBy(path string) (Scope, bool) {
+	match := ""
+	for prefix := range m.prefixes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+
+	if len(match) == 0 {
+		return "", false
+	}
+
+	return m.prefixes[match], true
+}
+
+// requiredScope returns the scope of the route, or the one implied by the method, raised to the minimum of the prefix
+func (m *Middleware) requiredScope(r *http.Request, pattern string, minimum Scope) Scope {
+	if scope, ok := m.scopes[pattern]; ok {
+		return scope
+	}
+
+	scope := ScopeWrite
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		scope = ScopeRead
+	}
+
+	if scopeRank[minimum] > scopeRank[scope] {
+		return minimum
+	}
+
+	return scope
+}
+
+// bearerToken returns the token from the Authorization header, or from the query of a GET request
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if scheme, value, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(value)
+	}
+
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return r.URL.Query().Get(accessTokenParameter)
+	}
+
+	return ""
+}
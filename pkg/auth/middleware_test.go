@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/google/uuid"
+)
+
+// fakeTokenStore holds the tokens by their hash
+type fakeTokenStore map[string]database.ApiToken
+
+func (s fakeTokenStore) GetApiTokenByHash(ctx context.Context, tokenHash string) (database.ApiToken, error) {
+	token, ok := s[tokenHash]
+	if !ok {
+		return token, sql.ErrNoRows
+	}
+
+	return token, nil
+}
+
+func (s fakeTokenStore) TouchApiToken(ctx context.Context, id uuid.UUID) error {
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	store := fakeTokenStore{
+		HashToken("read"):    {Scopes: []string{"read"}},
+		HashToken("write"):   {Scopes: []string{"write"}},
+		HashToken("admin"):   {Scopes: []string{"admin"}},
+		HashToken("revoked"): {Scopes: []string{"admin"}, RevokedAt: sql.NullTime{Time: time.Now(), Valid: true}},
+		HashToken("expired"): {Scopes: []string{"admin"}, ExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}},
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /v1/documents", ok)
+	mux.Handle("POST /v1/documents", ok)
+	mux.Handle("GET /v1/health", ok)
+	mux.Handle("POST /v1/config/reload", ok)
+	mux.Handle("GET /debug/pprof/", ok)
+	mux.Handle("GET /v1/admin/tokens", ok)
+	mux.Handle("GET /", ok)
+
+	m := NewMiddleware(store, mux)
+	m.Exempt("GET /v1/health")
+	m.Require("POST /v1/config/reload", ScopeAdmin)
+	m.Protect("/debug/", ScopeAdmin)
+	m.Protect("/v1/admin/", ScopeAdmin)
+	handler := m.Handler(mux)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+		want   int
+	}{
+		{name: "outside the protected prefixes", method: http.MethodGet, target: "/index.html", want: http.StatusOK},
+		{name: "exempt route", method: http.MethodGet, target: "/v1/health", want: http.StatusOK},
+		{name: "missing token", method: http.MethodGet, target: "/v1/documents", want: http.StatusUnauthorized},
+		{name: "unknown token", method: http.MethodGet, target: "/v1/documents", token: "other", want: http.StatusUnauthorized},
+		{name: "revoked token", method: http.MethodGet, target: "/v1/documents", token: "revoked", want: http.StatusUnauthorized},
+		{name: "expired token", method: http.MethodGet, target: "/v1/documents", token: "expired", want: http.StatusUnauthorized},
+		{name: "unknown route still needs a token", method: http.MethodGet, target: "/v1/missing", want: http.StatusUnauthorized},
+		{name: "read can get", method: http.MethodGet, target: "/v1/documents", token: "read", want: http.StatusOK},
+		{name: "read can not post", method: http.MethodPost, target: "/v1/documents", token: "read", want: http.StatusForbidden},
+		{name: "write can post", method: http.MethodPost, target: "/v1/documents", token: "write", want: http.StatusOK},
+		{name: "write can not call an admin route", method: http.MethodPost, target: "/v1/config/reload", token: "write", want: http.StatusForbidden},
+		{name: "admin can call an admin route", method: http.MethodPost, target: "/v1/config/reload", token: "admin", want: http.StatusOK},
+		{name: "protected prefix needs a token", method: http.MethodGet, target: "/debug/pprof/", want: http.StatusUnauthorized},
+		{name: "protected prefix raises a get to admin", method: http.MethodGet, target: "/debug/pprof/", token: "write", want: http.StatusForbidden},
+		{name: "admin can call the protected prefix", method: http.MethodGet, target: "/debug/pprof/", token: "admin", want: http.StatusOK},
+		{name: "nested prefix needs its own scope", method: http.MethodGet, target: "/v1/admin/tokens", token: "read", want: http.StatusForbidden},
+		{name: "admin can call the nested prefix", method: http.MethodGet, target: "/v1/admin/tokens", token: "admin", want: http.StatusOK},
+		{name: "outer prefix keeps its scope", method: http.MethodGet, target: "/v1/documents", token: "read", want: http.StatusOK},
+		{name: "token in the query of a get", method: http.MethodGet, target: "/v1/documents?access_token=read", want: http.StatusOK},
+		{name: "token in the query of a post is ignored", method: http.MethodPost, target: "/v1/documents?access_token=write", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			if len(tt.token) != 0 {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.target, w.Code, tt.want)
+			}
+
+			if w.Code == http.StatusUnauthorized && len(w.Header().Get("WWW-Authenticate")) == 0 {
+				t.Errorf("%s %s is missing the WWW-Authenticate header", tt.method, tt.target)
+			}
+		})
+	}
+}
+
+func TestRequiredScope(t *testing.T) {
+	m := NewMiddleware(fakeTokenStore{}, http.NewServeMux())
+	m.Require("DELETE /v1/tokens/{id}", ScopeAdmin)
+	m.Require("POST /v1/search", ScopeRead)
+
+	tests := []struct {
+		name    string
+		method  string
+		pattern string
+		minimum Scope
+		want    Scope
+	}{
+		{name: "get is read", method: http.MethodGet, pattern: "GET /v1/documents", minimum: ScopeRead, want: ScopeRead},
+		{name: "head is read", method: http.MethodHead, pattern: "GET /v1/documents", minimum: ScopeRead, want: ScopeRead},
+		{name: "post is write", method: http.MethodPost, pattern: "POST /v1/documents", minimum: ScopeRead, want: ScopeWrite},
+		{name: "route scope", method: http.MethodDelete, pattern: "DELETE /v1/tokens/{id}", minimum: ScopeRead, want: ScopeAdmin},
+		{name: "route scope can lower the method", method: http.MethodPost, pattern: "POST /v1/search", minimum: ScopeRead, want: ScopeRead},
+		{name: "minimum raises the method", method: http.MethodGet, pattern: "GET /debug/pprof/", minimum: ScopeAdmin, want: ScopeAdmin},
+		{name: "minimum below the method", method: http.MethodPut, pattern: "PUT /v1/documents", minimum: ScopeRead, want: ScopeWrite},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			if got := m.requiredScope(r, tt.pattern, tt.minimum); got != tt.want {
+				t.Errorf("requiredScope(%s, %q, %q) = %q, want %q", tt.method, tt.pattern, tt.minimum, got, tt.want)
+			}
+		})
+	}
+}
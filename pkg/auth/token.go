@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// NewToken will create a random token.  The token is only shown once, the hash is stored to check it and
+// the prefix is stored so the token can be recognized.
+func NewToken() (token, hash, prefix string, err error) {
+	b := make([]byte, tokenBytes)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	token = tokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	return token, HashToken(token), token[:displayPrefixLength], nil
+}
+
+// HashToken returns the hex encoded SHA-256 hash the token is stored as
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseScopes will parse a comma separated list of scopes
+func ParseScopes(value string) ([]string, error) {
+	scopes := make([]string, 0)
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(strings.ToLower(s))
+		if len(s) == 0 {
+			continue
+		}
+
+		if _, ok := scopeRank[Scope(s)]; !ok {
+			return nil, fmt.Errorf("%w: %q, expected one of: %s", ErrInvalidScope, s, scopeList())
+		}
+
+		scopes = append(scopes, s)
+	}
+
+	if len(scopes) == 0 {
+		return nil, ErrNoScopes
+	}
+
+	return scopes, nil
+}
+
+// Allows reports if any of the granted scopes includes the required one
+func Allows(granted []string, required Scope) bool {
+	for _, s := range granted {
+		if scopeRank[Scope(s)] >= scopeRank[required] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func scopeList() string {
+	names := make([]string, 0, len(Scopes))
+	for _, s := range Scopes {
+		names = append(names, string(s))
+	}
+
+	return strings.Join(names, ", ")
+}
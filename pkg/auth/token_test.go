@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		granted  []string
+		required Scope
+		want     bool
+	}{
+		{name: "read allows read", granted: []string{"read"}, required: ScopeRead, want: true},
+		{name: "read does not allow write", granted: []string{"read"}, required: ScopeWrite},
+		{name: "write allows read", granted: []string{"write"}, required: ScopeRead, want: true},
+		{name: "write does not allow admin", granted: []string{"write"}, required: ScopeAdmin},
+		{name: "admin allows everything", granted: []string{"admin"}, required: ScopeWrite, want: true},
+		{name: "highest granted scope counts", granted: []string{"read", "admin"}, required: ScopeAdmin, want: true},
+		{name: "unknown scope allows nothing", granted: []string{"owner"}, required: ScopeRead},
+		{name: "no scopes", granted: nil, required: ScopeRead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Allows(tt.granted, tt.required); got != tt.want {
+				t.Errorf("Allows(%v, %q) = %v, want %v", tt.granted, tt.required, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr error
+	}{
+		{value: "read", want: []string{"read"}},
+		{value: " Read, WRITE ,", want: []string{"read", "write"}},
+		{value: "admin,owner", wantErr: ErrInvalidScope},
+		{value: " , ", wantErr: ErrNoScopes},
+		{value: "", wantErr: ErrNoScopes},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseScopes(tt.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseScopes(%q) error = %v, want %v", tt.value, err, tt.wantErr)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseScopes(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	token, hash, prefix, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(token, tokenPrefix) || !strings.HasPrefix(token, prefix) || len(prefix) != displayPrefixLength {
+		t.Errorf("token %q does not start with the prefix %q", token, prefix)
+	}
+
+	if hash != HashToken(token) {
+		t.Errorf("hash = %q, want %q", hash, HashToken(token))
+	}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/google/uuid"
+)
+
+// Scope is what a token is allowed to do, each scope includes the ones below it
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+// scopeRank orders the scopes so a token with a higher scope can call the routes of a lower one
+var scopeRank = map[Scope]int{
+	ScopeRead:  1,
+	ScopeWrite: 2,
+	ScopeAdmin: 3,
+}
+
+// Scopes lists the valid scopes in the order they include each other
+var Scopes = []Scope{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// Every token starts with the prefix so it can be recognized in configuration files and logs
+const tokenPrefix = "scr_"
+
+// Number of random bytes in a token
+const tokenBytes = 32
+
+// Number of characters of the token that are stored in clear so it can be recognized in the token list
+const displayPrefixLength = 12
+
+// The query parameter a token can be passed in for requests that can not set a header, such as an
+// EventSource or the PDF shown in an iframe.  It is only accepted on GET requests.
+const accessTokenParameter = "access_token"
+
+// The routes under the prefix require a token unless the middleware is told to protect others
+const protectedPrefix = "/v1/"
+
+var (
+	ErrInvalidScope = errors.New("invalid scope")
+	ErrNoScopes     = errors.New("at least one scope is required")
+)
+
+// TokenStore is used to look up the tokens presented to the API
+type TokenStore interface {
+	GetApiTokenByHash(ctx context.Context, tokenHash string) (database.ApiToken, error)
+	TouchApiToken(ctx context.Context, id uuid.UUID) error
+}
+
+// Middleware checks the token of every request to a /v1 route against the scope the route requires
+type Middleware struct {
+	store TokenStore
+	mux   *http.ServeMux
+
+	// the path prefixes that require a token and the lowest scope the routes under them require
+	prefixes map[string]Scope
+
+	// the scope required by a route pattern when it is not the one implied by the method
+	scopes map[string]Scope
+
+	// route patterns that are reachable without a token
+	public map[string]bool
+}
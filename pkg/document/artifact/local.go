@@ -0,0 +1,92 @@
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// NewLocalStore will create an artifact store rooted at the given folder.
+func NewLocalStore(rootPath string) (*LocalStore, error) {
+	err := os.MkdirAll(rootPath, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalStore{rootPath: rootPath}, nil
+}
+
+// Put will hash the contents while writing them to a temporary file and then move the file to its content address.
+func (ls *LocalStore) Put(reader io.Reader) (Artifact, error) {
+	tempFile, err := os.CreateTemp(ls.rootPath, "artifact-*.tmp")
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	// remove the temp file if we fail before it is renamed
+	defer os.Remove(tempFile.Name())
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tempFile, hasher), reader)
+	if err != nil {
+		tempFile.Close()
+		return Artifact{}, err
+	}
+
+	err = tempFile.Close()
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	a := Artifact{
+		Hash: hex.EncodeToString(hasher.Sum(nil)),
+		Size: size,
+	}
+
+	artifactPath := ls.path(a.Hash)
+
+	// the same contents have already been stored
+	if _, err := os.Stat(artifactPath); err == nil {
+		return a, nil
+	}
+
+	err = os.MkdirAll(filepath.Dir(artifactPath), 0755)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	err = os.Rename(tempFile.Name(), artifactPath)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	return a, nil
+}
+
+// Open the artifact with the given hash.
+func (ls *LocalStore) Open(hash string) (io.ReadCloser, error) {
+	// only valid hashes can be turned into a path
+	if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha256.Size*2 {
+		return nil, ErrArtifactNotFound
+	}
+
+	file, err := os.Open(ls.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrArtifactNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// path will fan the artifacts out into sub-folders based on the first two characters of the hash
+func (ls *LocalStore) path(hash string) string {
+	return filepath.Join(ls.rootPath, hash[:2], hash)
+}
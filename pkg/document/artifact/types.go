@@ -0,0 +1,30 @@
+package artifact
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrArtifactNotFound = errors.New("could not find the artifact")
+
+type (
+	// Artifact is the content addressed output of a processor stage.
+	Artifact struct {
+		Hash string // hex encoded SHA-256 of the contents
+		Size int64  // size of the contents in bytes
+	}
+
+	// Store persists stage outputs by the hash of their contents.
+	Store interface {
+		// Put will save the contents of the reader and return the resulting artifact.
+		Put(reader io.Reader) (Artifact, error)
+
+		// Open will return a reader for the artifact with the given hash.
+		Open(hash string) (io.ReadCloser, error)
+	}
+
+	// LocalStore keeps artifacts in a folder on the local disk.
+	LocalStore struct {
+		rootPath string
+	}
+)
@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+)
+
+const cacheFileExt = ".cache"
+
+// New will create a cache in the given folder and load any entries that were stored by a previous run.
+func New(folder string, ttl time.Duration, maxBytes int64) (*Cache, error) {
+	defer logging.Span("cache.New")()
+
+	err := os.MkdirAll(folder, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cache{
+		folder:   folder,
+		ttl:      ttl,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*entry),
+	}
+
+	err = c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Key will build a cache key from the hash of the stage input, the stage and the options the stage ran with.
+func Key(inputHash, stage, options string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{inputHash, stage, options}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get will return the cached contents for the key if they exist and have not expired.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	if time.Since(e.storedAt) > c.ttl {
+		c.remove(key)
+		c.stats.Misses++
+		return nil, false
+	}
+
+	contents, err := os.ReadFile(c.path(key))
+	if err != nil {
+		slog.Warn("Failed to read the cache entry", "key", key, "error", err)
+		c.remove(key)
+		c.stats.Misses++
+		return nil, false
+	}
+
+	e.accessedAt = time.Now()
+	c.stats.Hits++
+
+	return contents, true
+}
+
+// Put will store the contents for the key and evict entries if the cache is over its size.
+func (c *Cache) Put(key string, contents []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// write to a temp file first so a partial entry is never read
+	tempFile, err := os.CreateTemp(c.folder, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(contents)
+	if err != nil {
+		tempFile.Close()
+		return err
+	}
+
+	err = tempFile.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tempFile.Name(), c.path(key))
+	if err != nil {
+		return err
+	}
+
+	// replacing an entry should not count its old size
+	if old, ok := c.entries[key]; ok {
+		c.stats.Bytes -= old.size
+		c.stats.Entries--
+	}
+
+	now := time.Now()
+	c.entries[key] = &entry{
+		size:       int64(len(contents)),
+		storedAt:   now,
+		accessedAt: now,
+	}
+	c.stats.Bytes += int64(len(contents))
+	c.stats.Entries++
+
+	c.evict()
+
+	return nil
+}
+
+// Stats returns a snapshot of the cache counters
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+// load will read the existing cache entries from the folder, the access time is not persisted so the modified time is used.
+func (c *Cache) load() error {
+	files, err := os.ReadDir(c.folder)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != cacheFileExt {
+			continue
+		}
+
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+
+		key := strings.TrimSuffix(f.Name(), cacheFileExt)
+		c.entries[key] = &entry{
+			size:       info.Size(),
+			storedAt:   info.ModTime(),
+			accessedAt: info.ModTime(),
+		}
+		c.stats.Bytes += info.Size()
+		c.stats.Entries++
+	}
+
+	c.evict()
+
+	return nil
+}
+
+// evict will remove expired entries and then the least recently used entries until the cache fits in its size
+func (c *Cache) evict() {
+	for key, e := range c.entries {
+		if time.Since(e.storedAt) > c.ttl {
+			c.remove(key)
+			c.stats.Evictions++
+		}
+	}
+
+	if c.maxBytes <= 0 || c.stats.Bytes <= c.maxBytes {
+		return
+	}
+
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].accessedAt.Before(c.entries[keys[j]].accessedAt)
+	})
+
+	for _, key := range keys {
+		if c.stats.Bytes <= c.maxBytes {
+			break
+		}
+
+		c.remove(key)
+		c.stats.Evictions++
+	}
+}
+
+// remove the entry from the index and the disk, the lock must be held
+func (c *Cache) remove(key string) {
+	e, ok := c.entries[key]
+	if !ok {
+		return
+	}
+
+	err := os.Remove(c.path(key))
+	if err != nil && !os.IsNotExist(err) {
+		slog.Warn("Failed to remove the cache entry", "key", key, "error", err)
+	}
+
+	delete(c.entries, key)
+	c.stats.Bytes -= e.size
+	c.stats.Entries--
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.folder, key+cacheFileExt)
+}
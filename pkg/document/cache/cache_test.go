@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	base := Key("hash", "stage", "options")
+
+	tests := []struct {
+		name     string
+		hash     string
+		stage    string
+		options  string
+		wantSame bool
+	}{
+		{name: "same inputs", hash: "hash", stage: "stage", options: "options", wantSame: true},
+		{name: "other hash", hash: "other", stage: "stage", options: "options"},
+		{name: "other stage", hash: "hash", stage: "other", options: "options"},
+		{name: "other options", hash: "hash", stage: "stage", options: "other"},
+		{name: "parts are separated", hash: "hashs", stage: "tage", options: "options"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Key(tt.hash, tt.stage, tt.options)
+			if (got == base) != tt.wantSame {
+				t.Errorf("Key(%q, %q, %q) == base is %v, want %v", tt.hash, tt.stage, tt.options, got == base, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestGetExpired(t *testing.T) {
+	tests := []struct {
+		name    string
+		age     time.Duration
+		wantHit bool
+	}{
+		{name: "fresh entry", age: time.Minute, wantHit: true},
+		{name: "expired entry", age: 2 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(t.TempDir(), time.Hour, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.Put("key", []byte("contents"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c.entries["key"].storedAt = time.Now().Add(-tt.age)
+
+			contents, hit := c.Get("key")
+			if hit != tt.wantHit {
+				t.Fatalf("Get hit = %v, want %v", hit, tt.wantHit)
+			}
+
+			if hit && string(contents) != "contents" {
+				t.Errorf("Get contents = %q, want %q", contents, "contents")
+			}
+
+			if !hit {
+				if _, err := os.Stat(c.path("key")); !os.IsNotExist(err) {
+					t.Errorf("the expired entry is still on disk: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int64
+		access   []string
+		want     []string
+		evicted  []string
+	}{
+		{
+			name:     "fits",
+			maxBytes: 30,
+			want:     []string{"a", "b", "c"},
+		},
+		{
+			name:     "oldest is evicted",
+			maxBytes: 20,
+			want:     []string{"b", "c"},
+			evicted:  []string{"a"},
+		},
+		{
+			name:     "recently read entry is kept",
+			maxBytes: 20,
+			access:   []string{"a"},
+			want:     []string{"a", "c"},
+			evicted:  []string{"b"},
+		},
+		{
+			name:     "no limit",
+			maxBytes: 0,
+			want:     []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(t.TempDir(), time.Hour, tt.maxBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// each entry is 10 bytes and accessed a second after the last
+			start := time.Now().Add(-time.Hour)
+			for i, key := range []string{"a", "b"} {
+				err = c.Put(key, []byte("0123456789"))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				c.entries[key].accessedAt = start.Add(time.Duration(i) * time.Second)
+			}
+
+			for _, key := range tt.access {
+				if _, hit := c.Get(key); !hit {
+					t.Fatalf("Get(%q) missed before the eviction", key)
+				}
+			}
+
+			err = c.Put("c", []byte("0123456789"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, key := range tt.want {
+				if _, ok := c.entries[key]; !ok {
+					t.Errorf("entry %q was evicted", key)
+				}
+			}
+
+			for _, key := range tt.evicted {
+				if _, ok := c.entries[key]; ok {
+					t.Errorf("entry %q was not evicted", key)
+				}
+			}
+
+			stats := c.Stats()
+			if stats.Entries != int64(len(tt.want)) || stats.Bytes != int64(10*len(tt.want)) {
+				t.Errorf("Stats = %+v, want %d entries of 10 bytes", stats, len(tt.want))
+			}
+
+			if stats.Evictions != int64(len(tt.evicted)) {
+				t.Errorf("Stats.Evictions = %d, want %d", stats.Evictions, len(tt.evicted))
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	folder := t.TempDir()
+
+	c, err := New(folder, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Put("key", []byte("contents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a new cache on the same folder picks up the stored entry
+	reloaded, err := New(folder, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, hit := reloaded.Get("key")
+	if !hit || string(contents) != "contents" {
+		t.Errorf("Get after load = %q, %v, want %q, true", contents, hit, "contents")
+	}
+}
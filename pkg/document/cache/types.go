@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// Stats are the running counters for the cache
+	Stats struct {
+		Hits      int64
+		Misses    int64
+		Evictions int64
+		Entries   int64
+		Bytes     int64
+	}
+
+	entry struct {
+		size       int64
+		storedAt   time.Time
+		accessedAt time.Time
+	}
+
+	// Cache keeps processor results on the local disk so the same document is not sent to an external API twice.
+	// Entries expire after the TTL and the least recently used entries are evicted once the cache is over its size.
+	Cache struct {
+		mu       sync.Mutex
+		folder   string
+		ttl      time.Duration
+		maxBytes int64
+
+		entries map[string]*entry
+		stats   Stats
+	}
+)
@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/internal/database"
+)
+
+// seedBundles will make the database match the bundles in the config file.  A bundle in the file is added or
+// updated to match it, keeping whether it is enabled, and a bundle that came from the file and is no longer in it
+// is deleted.  Bundles created through the API are left alone.
+func (dm *DocumentManager) seedBundles(bundles []config.StorageBundle) error {
+	sourceFolders := make([]string, 0, len(bundles))
+	for _, b := range bundles {
+		sourceFolders = append(sourceFolders, b.SourceFolder)
+
+		name := b.Name
+		if len(name) == 0 {
+			name = b.SourceFolder
+		}
+
+		// a nil list would be stored as NULL
+		notifyOn := b.NotifyOn
+		if notifyOn == nil {
+			notifyOn = []string{}
+		}
+
+		mathpixOptions, err := json.Marshal(b.Mathpix)
+		if err != nil {
+			return err
+		}
+
+		args := database.SeedBundleParams{
+			Name:                  name,
+			SourceFolder:          b.SourceFolder,
+			ArchiveFolder:         b.ArchiveFolder,
+			DestAttachmentsFolder: b.DestAttachmentsFolder,
+			DestNotesFolder:       b.DestNotesFolder,
+			NotifyOn:              notifyOn,
+			MathpixOptions:        string(mathpixOptions),
+		}
+
+		err = dm.store.SeedBundle(dm.ctx, args)
+		if err != nil {
+			slog.Error("Failed to seed the bundle", "name", name, "sourceFolder", b.SourceFolder, "error", err)
+			return err
+		}
+	}
+
+	err := dm.store.DeleteConfigBundlesNotIn(dm.ctx, sourceFolders)
+	if err != nil {
+		slog.Error("Failed to delete the bundles removed from the config file", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// enabledBundles returns the bundles in the database that documents are processed for
+func (dm *DocumentManager) enabledBundles() ([]config.StorageBundle, error) {
+	rows, err := dm.store.ListEnabledBundles(dm.ctx)
+	if err != nil {
+		slog.Error("Failed to query the enabled bundles", "error", err)
+		return nil, err
+	}
+
+	bundles := make([]config.StorageBundle, 0, len(rows))
+	for _, b := range rows {
+		var mathpixOptions config.MathpixOptions
+		err = json.Unmarshal([]byte(b.MathpixOptions), &mathpixOptions)
+		if err != nil {
+			slog.Error("Failed to read the Mathpix options of the bundle", "name", b.Name, "error", err)
+			return nil, err
+		}
+
+		bundles = append(bundles, config.StorageBundle{
+			Name:                  b.Name,
+			SourceFolder:          b.SourceFolder,
+			ArchiveFolder:         b.ArchiveFolder,
+			DestAttachmentsFolder: b.DestAttachmentsFolder,
+			DestNotesFolder:       b.DestNotesFolder,
+			NotifyOn:              b.NotifyOn,
+			Mathpix:               mathpixOptions,
+		})
+	}
+
+	return bundles, nil
+}
@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/internal/database"
+)
+
+// seedStore records the bundles seeded from the config file and the folders of the bundles kept
+type seedStore struct {
+	DocumentManagerStore
+
+	seeded []database.SeedBundleParams
+	kept   []string
+}
+
+func (s *seedStore) SeedBundle(ctx context.Context, arg database.SeedBundleParams) error {
+	s.seeded = append(s.seeded, arg)
+	return nil
+}
+
+func (s *seedStore) DeleteConfigBundlesNotIn(ctx context.Context, sourceFolders []string) error {
+	s.kept = sourceFolders
+	return nil
+}
+
+func TestSeedBundles(t *testing.T) {
+	tests := []struct {
+		name      string
+		bundles   []config.StorageBundle
+		wantNames []string
+		wantKept  []string
+	}{
+		{
+			name:      "no bundles in the file removes the ones from the file",
+			wantNames: []string{},
+			wantKept:  []string{},
+		},
+		{
+			name: "bundles are named after their folder",
+			bundles: []config.StorageBundle{
+				{Name: "notes", SourceFolder: "a"},
+				{SourceFolder: "b"},
+			},
+			wantNames: []string{"notes", "b"},
+			wantKept:  []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &seedStore{}
+			dm := &DocumentManager{ctx: context.Background(), store: store}
+
+			err := dm.seedBundles(tt.bundles)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names := make([]string, 0, len(store.seeded))
+			for _, arg := range store.seeded {
+				names = append(names, arg.Name)
+				if arg.NotifyOn == nil {
+					t.Errorf("bundle %s is seeded with a nil notify_on", arg.Name)
+				}
+			}
+
+			if !slices.Equal(names, tt.wantNames) {
+				t.Errorf("seeded bundles = %q, want %q", names, tt.wantNames)
+			}
+
+			if store.kept == nil || !slices.Equal(store.kept, tt.wantKept) {
+				t.Errorf("kept bundles = %q, want %q", store.kept, tt.wantKept)
+			}
+		})
+	}
+}
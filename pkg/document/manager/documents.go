@@ -0,0 +1,95 @@
+package manager
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/google/uuid"
+)
+
+// ReprocessDocument will run a document through the processors again starting at the stage, the first stage is
+// used when the stage is empty.  The document is processed in the background, only problems starting it are returned.
+func (dm *DocumentManager) ReprocessDocument(id uuid.UUID, stage string) error {
+	defer logging.Span("DocumentManager.ReprocessDocument")()
+
+	_, err := dm.store.GetDocumentById(dm.ctx, id)
+	if err != nil {
+		return err
+	}
+
+	p := dm.currentPipeline()
+	if len(stage) == 0 {
+		stage = p.processors[0].Name()
+	}
+
+	if _, err = p.stageIndex(stage); err != nil {
+		return err
+	}
+
+	dm.Lock()
+	inFlight := dm.findInFlight(id) != nil
+	dm.Unlock()
+
+	if inFlight {
+		return ErrDocumentInFlight
+	}
+
+	dm.wg.Add(1)
+	go func() {
+		defer dm.wg.Done()
+
+		// the document waits its turn with the ones found by the storage
+		if !dm.acquireSlot(dm.intakeCtx) {
+			slog.Warn("The server stopped before the document could be reprocessed", "id", id)
+			return
+		}
+		defer dm.releaseSlot()
+
+		err := dm.ResumeDocument(id, stage)
+		if err != nil {
+			slog.Error("Failed to reprocess the document", "id", id, "stage", stage, "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// CancelDocument will stop processing a document that is in flight.  The stage that is running is interrupted and
+// the document is marked as canceled, it can be processed again with ReprocessDocument.
+func (dm *DocumentManager) CancelDocument(id uuid.UUID) error {
+	defer logging.Span("DocumentManager.CancelDocument")()
+
+	dm.Lock()
+	defer dm.Unlock()
+
+	doc := dm.findInFlight(id)
+	if doc == nil {
+		return ErrDocumentNotInFlight
+	}
+
+	slog.Info("Canceling the document", "id", id)
+	doc.cancel(ErrDocumentCanceled)
+
+	return nil
+}
+
+// InFlight returns the IDs of the documents that are being processed
+func (dm *DocumentManager) InFlight() []uuid.UUID {
+	dm.Lock()
+	defer dm.Unlock()
+
+	ids := make([]uuid.UUID, 0)
+	for _, p := range dm.pipelines {
+		for id := range p.pending {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// OpenArtifact returns the content of an artifact stored by one of the stages
+func (dm *DocumentManager) OpenArtifact(hash string) (io.ReadCloser, error) {
+	return dm.artifacts.Open(hash)
+}
@@ -3,25 +3,35 @@ package manager
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/KyleBrandon/scriptoria/internal/config"
 	"github.com/KyleBrandon/scriptoria/internal/database"
 	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/document/artifact"
+	"github.com/KyleBrandon/scriptoria/pkg/document/cache"
 	"github.com/KyleBrandon/scriptoria/pkg/document/processor"
-	"github.com/KyleBrandon/scriptoria/pkg/document/processor/chatgpt"
-	"github.com/KyleBrandon/scriptoria/pkg/document/processor/mathpix"
-	"github.com/KyleBrandon/scriptoria/pkg/document/processor/obsidian"
 	"github.com/KyleBrandon/scriptoria/pkg/document/storage"
+	"github.com/KyleBrandon/scriptoria/pkg/events"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/KyleBrandon/scriptoria/pkg/metrics"
+	"github.com/KyleBrandon/scriptoria/pkg/ratelimit"
+	"github.com/KyleBrandon/scriptoria/pkg/tracing"
+	"github.com/KyleBrandon/scriptoria/pkg/usage"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func New(ctx context.Context, queries *database.Queries, config config.Config, mux *http.ServeMux) (*DocumentManager, error) {
-	slog.Debug(">>DocumentManager.New")
-	defer slog.Debug("<<DocumentManager.New")
+	defer logging.SpanContext(ctx, "DocumentManager.New")()
 
 	// create sub-context and cancelCauseFunc
 	mgrCtx, cancelCauseFunc := context.WithCancelCause(ctx)
@@ -33,27 +43,72 @@ func New(ctx context.Context, queries *database.Queries, config config.Config, m
 		wg:              &wg,
 		cancelCauseFunc: cancelCauseFunc,
 		store:           queries,
+		processorStore:  queries,
 		config:          config,
+		events:          events.NewBus(),
+		slots:           make(chan struct{}, max(config.Concurrency.MaxDocuments, 1)),
+		ledger:          usage.NewLedger(queries, config.Limits),
+	}
+
+	// pace the requests to the external providers
+	configureRateLimits(config.Limits)
+
+	// intake can be stopped on its own so documents in flight can finish
+	dm.intakeCtx, dm.intakeCancel = context.WithCancel(mgrCtx)
+
+	// initialize the store for the stage outputs
+	artifacts, err := artifact.NewLocalStore(config.ArtifactStorageFolder)
+	if err != nil {
+		slog.Error("Failed to initialize the artifact storage", "error", err)
+		return nil, err
+	}
+
+	dm.artifacts = artifacts
+
+	// the database is updated to the bundles in the config file, documents are processed for the enabled ones
+	err = dm.seedBundles(config.Bundles)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Bundles, err = dm.enabledBundles()
+	if err != nil {
+		return nil, err
+	}
+
+	dm.config = config
+
+	// initialize the cache of processor results
+	if !config.Cache.Disabled {
+		maxBytes := config.Cache.MaxSizeMB * 1024 * 1024
+		dm.cache, err = cache.New(config.Cache.Folder, config.Cache.TTL.Duration(), maxBytes)
+		if err != nil {
+			slog.Error("Failed to initialize the processor cache", "error", err)
+			return nil, err
+		}
+
+		metrics.SetCache(dm.cache)
 	}
 
 	// initialize the storage reader
-	err := dm.initializeStorage(queries, mux)
+	err = dm.initializeStorage(queries, mux)
 	if err != nil {
 		return nil, err
 	}
 
 	// initialize the processors
-	err = dm.initializeProcessors(queries, config)
+	p, err := dm.newPipeline(config)
 	if err != nil {
 		return nil, err
 	}
 
+	dm.pipelines = []*pipeline{p}
+
 	return dm, nil
 }
 
 func (dm *DocumentManager) initializeStorage(queries *database.Queries, mux *http.ServeMux) error {
-	slog.Debug(">>DocumentManager.initializeStorage")
-	defer slog.Debug("<<DocumentManager.initializeStorage")
+	defer logging.Span("DocumentManager.initializeStorage")()
 
 	storage, err := storage.BuildDocumentStorage(dm.config.SourceStore, queries, mux)
 	if err != nil {
@@ -73,156 +128,394 @@ func (dm *DocumentManager) initializeStorage(queries *database.Queries, mux *htt
 	return nil
 }
 
-func (dm *DocumentManager) initializeProcessors(queries *database.Queries, config config.Config) error {
-	slog.Debug(">>DocumentManager.initializeProcessors")
-	defer slog.Debug("<<DocumentManager.initializeProcessors")
+func (dm *DocumentManager) CancelAndWait() {
+	// cancel all go routines
+	dm.cancelCauseFunc(nil)
+
+	// cancel all the processors
+	dm.Lock()
+	pipelines := slices.Clone(dm.pipelines)
+	dm.Unlock()
 
-	cfg := processor.ProcessorConfig{
-		Ctx:               dm.ctx,
-		CancelCauseFunc:   dm.cancelCauseFunc,
-		Store:             queries,
-		TempStorageFolder: config.TempStorageFolder,
-		Bundles:           config.Bundles,
+	for _, p := range pipelines {
+		p.stop()
 	}
 
-	dm.processors = make([]*processor.ProcessorContext, 0)
-	dm.processors = append(dm.processors, processor.New(cfg, processor.NewTempStorageProcessor()))
-	dm.processors = append(dm.processors, processor.New(cfg, mathpix.NewMathpixProcessor()))
-	dm.processors = append(dm.processors, processor.New(cfg, chatgpt.NewChatGPTProcessor()))
-	dm.processors = append(dm.processors, processor.New(cfg, obsidian.NewObsidianProcessor()))
-	dm.processors = append(dm.processors, processor.New(cfg, processor.NewBundleProcessor()))
+	dm.srcStorage.CancelAndWait()
+
+	// wait until the document go routines are finished
+	dm.wg.Wait()
 
-	dm.inputCh = make(chan *document.TransformContext)
-	inputCh := dm.inputCh
+	// end the event streams
+	dm.events.Close()
+}
 
-	// loop through the processors and initialize them by chaining their channels
-	for _, p := range dm.processors {
-		outputCh, err := p.Initialize(inputCh)
-		if err != nil {
-			slog.Error("Failed to initialize the processors", "error", err)
-			return err
-		}
+// Events returns the bus the document lifecycle events are published on
+func (dm *DocumentManager) Events() *events.Bus {
+	return dm.events
+}
 
-		inputCh = outputCh
+// PublicRoutes returns the routes the source storage registered that authenticate the caller themselves
+func (dm *DocumentManager) PublicRoutes() []string {
+	if pr, ok := dm.srcStorage.(document.PublicRouter); ok {
+		return pr.PublicRoutes()
 	}
 
-	// output processor channel is the last input
-	dm.outputCh = inputCh
-
 	return nil
 }
 
-func (dm *DocumentManager) CancelAndWait() {
-	// cancel all go routines
-	dm.cancelCauseFunc(nil)
+// Config returns the configuration the manager is running with
+func (dm *DocumentManager) Config() config.Config {
+	dm.Lock()
+	defer dm.Unlock()
 
-	// cancel all the processors
-	for _, p := range dm.processors {
-		p.CancelAndWait()
-	}
-
-	// wait until the document go routines are finished
-	dm.wg.Wait()
+	return dm.config
 }
 
 func (dm *DocumentManager) StartMonitoring() {
-	slog.Debug(">>StartMonitoring")
-	defer slog.Debug("<<StartMonitoring")
+	defer logging.Span("StartMonitoring")()
+
+	// pick up the documents that were interrupted by the last shutdown
+	dm.resumeInterruptedDocuments()
 
 	dm.wg.Add(1)
 	go dm.documentStorageMonitor()
 }
 
+// configureRateLimits will set the rates of the providers the processors call
+func configureRateLimits(limits config.LimitsConfig) {
+	for _, provider := range []string{usage.ProviderMathpix, usage.ProviderOpenAI} {
+		l := limits.Provider(provider)
+		ratelimit.Configure(provider, l.RequestsPerMinute, l.TokensPerMinute)
+	}
+}
+
+// Paused returns the providers whose budget is used up, their stage waits until the budget has room
+func (dm *DocumentManager) Paused() []usage.BudgetError {
+	return dm.ledger.Paused()
+}
+
 func (dm *DocumentManager) documentStorageMonitor() {
-	slog.Debug(">>documentStorageMonitor")
-	defer slog.Debug("<<documentStorageMonitor")
+	defer logging.Span("documentStorageMonitor")()
 
 	defer dm.wg.Done()
 
 	// start watching the source for new files
 	docCh, err := dm.srcStorage.StartWatching()
 	if err != nil {
-		slog.Error("Failed to start watching on the source channel", "source", dm.config.SourceStore, "error", err)
+		slog.Error("Failed to start watching on the source channel", "source", dm.Config().SourceStore, "error", err)
 		return
 	}
 
-	// process each file in a go routine as they come in
-
+	// process each file in a go routine as they come in, once the limit of documents in flight is reached the
+	// storage is not read until a document finishes so the watcher is throttled
 	for {
 		select {
-		case <-dm.ctx.Done():
+		case <-dm.intakeCtx.Done():
 			slog.Debug("DocumentManager.documentStorageMonitor canceled")
 			return
 
 		case srcDoc := <-docCh:
+			if !dm.acquireSlot(dm.intakeCtx) {
+				slog.Debug("DocumentManager.documentStorageMonitor canceled while waiting for a slot")
+				return
+			}
+
 			dm.wg.Add(1)
-			go dm.processDocument(srcDoc, dm.srcStorage)
+			go dm.processDocument(srcDoc)
 		}
 	}
 }
 
-func (dm *DocumentManager) processDocument(srcDoc *document.Document, srcStorage document.Storage) {
-	slog.Debug(">>DocumentManger.processDocument")
-	defer slog.Debug("<<DocumentManger.processDocument")
+// acquireSlot will wait until there is room for another document in flight.  It returns false if the context is
+// done first.
+func (dm *DocumentManager) acquireSlot(ctx context.Context) bool {
+	select {
+	case dm.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	slog.Debug("Waiting for a document to finish before starting another", "maxDocuments", cap(dm.slots))
+
+	// the document is queued until a document in flight finishes
+	metrics.QueueDepth.Inc()
+	defer metrics.QueueDepth.Dec()
+
+	select {
+	case dm.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// releaseSlot will make room for the next document once one has finished
+func (dm *DocumentManager) releaseSlot() {
+	<-dm.slots
+}
+
+func (dm *DocumentManager) processDocument(srcDoc *document.Document) {
+	defer logging.Span("DocumentManger.processDocument")()
 
 	defer dm.wg.Done()
+	defer dm.releaseSlot()
+
+	// failures are logged and recorded on the document by the pipeline
+	dm.ProcessDocument(srcDoc)
+}
+
+// ProcessDocument will create the document in the database and run it through the processors.  It blocks until
+// the document has finished processing and returns the ID of the new document.
+func (dm *DocumentManager) ProcessDocument(srcDoc *document.Document) (uuid.UUID, error) {
+	// start the trace that follows the document through the processors
+	ctx, span := tracing.Tracer().Start(dm.ctx, "processDocument", trace.WithAttributes(
+		tracing.AttrSourceName.String(srcDoc.Name),
+	))
+	defer span.End()
 
 	// check if we've processed this document and create it's state in the database
 	dbDoc, err := dm.initializeDocument(srcDoc)
 	if err != nil {
-		return
+		return uuid.Nil, err
 	}
 
+	span.SetAttributes(tracing.AttrDocumentID.String(dbDoc.ID.String()))
+	dm.publish(events.TypeDetected, dbDoc.ID, srcDoc, nil)
+
+	// every line logged while the document is processed carries the document
+	ctx = dm.withDocumentLogger(ctx, dbDoc.ID, srcDoc)
+
+	p, doc, err := dm.trackDocument(ctx, dbDoc.ID)
+	if err != nil {
+		return dbDoc.ID, err
+	}
+	defer dm.untrackDocument(p, dbDoc.ID)
+
 	// get the io.Reader for the document from the source storae
-	inputReader, err := srcStorage.GetReader(srcDoc)
+	inputReader, err := dm.srcStorage.GetReader(doc.ctx, srcDoc)
 	if err != nil {
-		slog.Error("Failed to get the document reader", "error", err)
-		return
+		logging.FromContext(ctx).Error("Failed to get the document reader", "error", err)
+		tracing.RecordError(span, err)
+		dm.publish(events.TypeFailed, dbDoc.ID, srcDoc, err)
+		return dbDoc.ID, err
 	}
 
-	// Send the document transform context to the input channel (first processor)
-	dm.inputCh <- &document.TransformContext{
+	// Send the document transform context to the first processor
+	err = dm.runPipeline(p, doc, 0, &document.TransformContext{
+		Ctx:            doc.ctx,
 		DocumentID:     dbDoc.ID,
 		SourceDocument: srcDoc,
 		Reader:         inputReader,
+	})
+	tracing.RecordError(span, err)
+
+	return dbDoc.ID, err
+}
+
+// ResumeDocument will restart processing a document at the given stage and block until it finishes.  The input
+// for the stage is read from the artifact stored by the previous stage so the earlier stages do not need to run again.
+func (dm *DocumentManager) ResumeDocument(id uuid.UUID, stageName string) error {
+	defer logging.Span("DocumentManager.ResumeDocument")()
+
+	dbDoc, err := dm.store.GetDocumentById(dm.ctx, id)
+	if err != nil {
+		slog.Error("Failed to find the document to resume", "id", id, "error", err)
+		return err
+	}
+
+	srcDoc := &document.Document{
+		StorageDocumentID: dbDoc.SourceID,
+		StorageFolderID:   dbDoc.SourceFolderID,
+		Name:              dbDoc.SourceName,
+	}
+
+	ctx, span := tracing.Tracer().Start(dm.ctx, "resumeDocument", trace.WithAttributes(
+		tracing.AttrDocumentID.String(dbDoc.ID.String()),
+		tracing.AttrSourceName.String(srcDoc.Name),
+		attribute.String("document.stage", stageName),
+	))
+
+	defer span.End()
+
+	ctx = dm.withDocumentLogger(ctx, dbDoc.ID, srcDoc)
+
+	p, doc, err := dm.trackDocument(ctx, dbDoc.ID)
+	if err != nil {
+		return err
+	}
+	defer dm.untrackDocument(p, dbDoc.ID)
+
+	stage, err := p.stageIndex(stageName)
+	if err != nil {
+		return err
+	}
+
+	reader, err := dm.stageInputReader(doc.ctx, p, dbDoc.ID, srcDoc, stage)
+	if err != nil {
+		tracing.RecordError(span, err)
+		dm.publish(events.TypeFailed, dbDoc.ID, srcDoc, err)
+		return err
+	}
+
+	logging.FromContext(ctx).Info("Resume processing document", "resumeStage", stageName)
+
+	t := &document.TransformContext{
+		Ctx:            doc.ctx,
+		DocumentID:     dbDoc.ID,
+		SourceDocument: srcDoc,
+		Reader:         reader,
+	}
+
+	err = dm.runPipeline(p, doc, stage, t)
+	tracing.RecordError(span, err)
+
+	return err
+}
+
+// Stages returns the names of the processors in the order documents move through them
+func (dm *DocumentManager) Stages() []string {
+	p := dm.currentPipeline()
+
+	stages := make([]string, 0, len(p.processors))
+	for _, pc := range p.processors {
+		stages = append(stages, pc.Name())
+	}
+
+	return stages
+}
+
+// stageInputReader will return the input for a stage, either the source document or the output of the previous stage.
+func (dm *DocumentManager) stageInputReader(ctx context.Context, p *pipeline, id uuid.UUID, srcDoc *document.Document, stage int) (io.ReadCloser, error) {
+	if stage == 0 {
+		return dm.srcStorage.GetReader(ctx, srcDoc)
+	}
+
+	args := database.GetLatestStageArtifactParams{
+		DocumentID: id,
+		Stage:      p.processors[stage-1].Name(),
+	}
+
+	event, err := dm.store.GetLatestStageArtifact(dm.ctx, args)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to find the artifact of the previous stage", "previousStage", args.Stage, "error", err)
+		return nil, err
+	}
+
+	return dm.artifacts.Open(event.ArtifactHash.String)
+}
+
+// runPipeline will send the transform context to the given stage of the pipeline the document is tracked on
+// and wait for the document to finish processing.
+func (dm *DocumentManager) runPipeline(p *pipeline, doc *inFlightDocument, stage int, t *document.TransformContext) error {
+	logger := logging.FromContext(doc.ctx)
+
+	// the document is queued until the stage accepts it
+	metrics.QueueDepth.Inc()
+	select {
+	case p.processors[stage].Input() <- t:
+		metrics.QueueDepth.Dec()
+	case <-doc.ctx.Done():
+		metrics.QueueDepth.Dec()
+		t.Reader.Close()
+		return dm.interrupted(doc, t)
 	}
 
-	// wait on output channel
-	t := <-dm.outputCh
+	// wait for the document to leave the last processor
+	select {
+	case t = <-doc.done:
+	case <-doc.ctx.Done():
+		return dm.interrupted(doc, t)
+	}
 
 	// if we have a final reader make sure it's closed
 	if t.Reader != nil {
 		t.Reader.Close()
 	}
 
+	if t.Err != nil {
+		if errors.Is(t.Err, context.Canceled) || errors.Is(t.Err, processor.ErrShutdown) {
+			return dm.interrupted(doc, t)
+		}
+
+		dm.finish(doc, t.DocumentID)
+		logger.Error("Failed to process document", "error", t.Err)
+		dm.updateDocumentProcessingStatus(dm.ctx, t.DocumentID, fmt.Sprintf("Processing Failed: %s", t.Err))
+		return t.Err
+	}
+
+	dm.finish(doc, t.DocumentID)
+
 	// archive the file now that we're done processing it
-	srcStorage.Archive(srcDoc)
+	err := dm.srcStorage.Archive(t.Ctx, t.SourceDocument)
+	if err != nil {
+		logger.Error("Failed to archive the document", "error", err)
+	} else {
+		dm.publish(events.TypeArchived, t.DocumentID, t.SourceDocument, nil)
+	}
 
-	err = dm.updateDocumentProcessingStatus(dbDoc.ID, "Processing Complete")
+	err = dm.updateDocumentProcessingStatus(dm.ctx, t.DocumentID, "Processing Complete")
 	if err != nil {
-		return
+		return err
+	}
+
+	dm.publish(events.TypeCompleted, t.DocumentID, t.SourceDocument, nil)
+
+	metrics.DocumentsCompleted.WithLabelValues(events.BundleName(t.SourceDocument, dm.Config().Bundles)).Inc()
+	logger.Info("Finished processing document")
+
+	return nil
+}
+
+// interrupted will record a document that was canceled by the user.  A document interrupted by the manager
+// shutting down was checkpointed to resume and is left as is.
+func (dm *DocumentManager) interrupted(doc *inFlightDocument, t *document.TransformContext) error {
+	logger := logging.FromContext(doc.ctx)
+	cause := context.Cause(doc.ctx)
+	if !errors.Is(cause, ErrDocumentCanceled) {
+		logger.Info("Processing interrupted")
+		return cause
 	}
 
-	slog.Info("Finished processing document", "sourceName", t.SourceDocument.Name)
+	logger.Info("Processing canceled")
+	dm.updateDocumentProcessingStatus(dm.ctx, t.DocumentID, "Processing Canceled")
+	dm.publish(events.TypeCanceled, t.DocumentID, t.SourceDocument, nil)
+
+	return ErrDocumentCanceled
+}
+
+// withDocumentLogger returns a context whose logger adds the document, its source name and bundle to every line
+func (dm *DocumentManager) withDocumentLogger(ctx context.Context, id uuid.UUID, srcDoc *document.Document) context.Context {
+	return logging.WithDocument(ctx, id, srcDoc.Name, events.BundleName(srcDoc, dm.Config().Bundles))
+}
+
+// publish will send a lifecycle event for the document to the subscribers
+func (dm *DocumentManager) publish(eventType string, id uuid.UUID, srcDoc *document.Document, err error) {
+	e := events.NewDocumentEvent(eventType, id, srcDoc, dm.Config().Bundles)
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	dm.events.Publish(e)
 }
 
 func (dm *DocumentManager) initializeDocument(srcDoc *document.Document) (*database.Document, error) {
-	slog.Debug(">>DocumentManager.createNewDocument")
-	defer slog.Debug("<<DocumentManager.createNewDocument")
+	defer logging.Span("DocumentManager.createNewDocument")()
 
 	// check if we've processed this file before
 	dbDoc, err := dm.store.FindDocumentBySourceId(dm.ctx, srcDoc.StorageDocumentID)
 	if err == nil {
 		// assume we've processed this or it's in process
 		slog.Warn("Document exists", "id", dbDoc.ID, "sourceID", dbDoc.SourceID, "name", dbDoc.SourceName)
-		return nil, err
+		return nil, ErrDocumentExists
 	}
 
 	// mark the file as having been processed
 	arg := database.CreateDocumentParams{
-		SourceStore: dm.config.SourceStore,
-		SourceID:    srcDoc.StorageDocumentID,
-		SourceName:  srcDoc.Name,
+		SourceStore:    dm.Config().SourceStore,
+		SourceID:       srcDoc.StorageDocumentID,
+		SourceName:     srcDoc.Name,
+		SourceFolderID: srcDoc.StorageFolderID,
 	}
 	dbDoc, err = dm.store.CreateDocument(dm.ctx, arg)
 	if err != nil {
@@ -230,19 +523,20 @@ func (dm *DocumentManager) initializeDocument(srcDoc *document.Document) (*datab
 		return nil, err
 	}
 
+	metrics.DocumentsIngested.WithLabelValues(events.BundleName(srcDoc, dm.Config().Bundles)).Inc()
 	slog.Info("Start processing document", "sourceName", srcDoc.Name)
 
 	return &dbDoc, nil
 }
 
-func (dm *DocumentManager) updateDocumentProcessingStatus(id uuid.UUID, message string) error {
+func (dm *DocumentManager) updateDocumentProcessingStatus(ctx context.Context, id uuid.UUID, message string) error {
 	args := database.UpdateDocumentProcessedParams{
 		ID:               id,
 		ProcessedAt:      sql.NullTime{Time: time.Now().UTC(), Valid: true},
 		ProcessingStatus: sql.NullString{String: message, Valid: true},
 	}
 
-	_, err := dm.store.UpdateDocumentProcessed(dm.ctx, args)
+	_, err := dm.store.UpdateDocumentProcessed(ctx, args)
 	if err != nil {
 		slog.Error("Failed to update the document status in the database", "error", err)
 		return err
@@ -0,0 +1,207 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/document/processor"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/google/uuid"
+)
+
+// newPipeline will build the processors listed in the config and chain their channels together
+func (dm *DocumentManager) newPipeline(cfg config.Config) (*pipeline, error) {
+	defer logging.Span("DocumentManager.newPipeline")()
+
+	ctx, cancelCauseFunc := context.WithCancelCause(dm.ctx)
+	p := &pipeline{
+		ctx:             ctx,
+		cancelCauseFunc: cancelCauseFunc,
+		wg:              &sync.WaitGroup{},
+		config:          cfg,
+		pending:         make(map[uuid.UUID]*inFlightDocument),
+	}
+
+	pcfg := processor.ProcessorConfig{
+		Ctx:               ctx,
+		CancelCauseFunc:   cancelCauseFunc,
+		Store:             dm.processorStore,
+		TempStorageFolder: cfg.TempStorageFolder,
+		Bundles:           cfg.Bundles,
+		Artifacts:         dm.artifacts,
+		Cache:             dm.cache,
+		Events:            dm.events,
+		MaxPollDuration:   cfg.Timeouts.MathpixPoll.Duration(),
+		Ledger:            dm.ledger,
+	}
+
+	// build the processors in the order they are listed in the config and chain their channels
+	inputCh := make(chan *document.TransformContext)
+	for _, name := range cfg.Processors {
+		build, ok := processorBuilders[name]
+		if !ok {
+			slog.Error("Failed to find the processor", "processor", name)
+			p.stop()
+			return nil, fmt.Errorf("invalid processor: %s", name)
+		}
+
+		// the stages are configured by the name they are listed with
+		pcfg.Workers = cfg.Concurrency.StageWorkers(name)
+		pcfg.Timeout = cfg.Timeouts.StageTimeout(name)
+
+		pc := processor.New(pcfg, build())
+		outputCh, err := pc.Initialize(inputCh)
+		if err != nil {
+			slog.Error("Failed to initialize the processors", "processor", name, "error", err)
+			p.stop()
+			return nil, err
+		}
+
+		p.processors = append(p.processors, pc)
+		inputCh = outputCh
+	}
+
+	// output processor channel is the last input
+	p.outputCh = inputCh
+
+	p.wg.Add(1)
+	go dm.collectOutput(p)
+
+	return p, nil
+}
+
+// stop will cancel the processors of the pipeline and wait for them to finish
+func (p *pipeline) stop() {
+	p.cancelCauseFunc(nil)
+
+	for _, pc := range p.processors {
+		pc.CancelAndWait()
+	}
+
+	p.wg.Wait()
+}
+
+// stageIndex returns the position of the named stage in the pipeline
+func (p *pipeline) stageIndex(name string) (int, error) {
+	for i, pc := range p.processors {
+		if pc.Name() == name {
+			return i, nil
+		}
+	}
+
+	return -1, ErrStageNotFound
+}
+
+// currentPipeline returns the pipeline new documents are sent to
+func (dm *DocumentManager) currentPipeline() *pipeline {
+	dm.Lock()
+	defer dm.Unlock()
+
+	return dm.pipelines[len(dm.pipelines)-1]
+}
+
+// replacePipeline will send new documents to the pipeline.  The previous pipeline is stopped once the documents
+// in flight on it have finished.
+func (dm *DocumentManager) replacePipeline(p *pipeline) {
+	dm.Lock()
+	previous := dm.pipelines[len(dm.pipelines)-1]
+	previous.retired = true
+	dm.pipelines = append(dm.pipelines, p)
+	idle := dm.removeIdlePipeline(previous)
+	dm.Unlock()
+
+	if idle {
+		previous.stop()
+	}
+}
+
+// removeIdlePipeline will remove a retired pipeline that has no documents left, the manager lock must be held
+func (dm *DocumentManager) removeIdlePipeline(p *pipeline) bool {
+	if !p.retired || len(p.pending) != 0 {
+		return false
+	}
+
+	for i, other := range dm.pipelines {
+		if other == p {
+			dm.pipelines = append(dm.pipelines[:i], dm.pipelines[i+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+// collectOutput will hand each document leaving the last processor to the go routine waiting on it
+func (dm *DocumentManager) collectOutput(p *pipeline) {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+
+		case t := <-p.outputCh:
+			dm.Lock()
+			doc, ok := p.pending[t.DocumentID]
+			dm.Unlock()
+
+			if !ok {
+				slog.Warn("No one is waiting on the processed document", "documentID", t.DocumentID)
+				continue
+			}
+
+			doc.done <- t
+		}
+	}
+}
+
+// trackDocument will mark the document as in flight on the current pipeline.  The document gets its own
+// context so it can be canceled on its own.
+func (dm *DocumentManager) trackDocument(ctx context.Context, id uuid.UUID) (*pipeline, *inFlightDocument, error) {
+	dm.Lock()
+	defer dm.Unlock()
+
+	if dm.findInFlight(id) != nil {
+		return nil, nil, ErrDocumentInFlight
+	}
+
+	doc := &inFlightDocument{
+		done: make(chan *document.TransformContext, 1),
+	}
+	doc.ctx, doc.cancel = context.WithCancelCause(ctx)
+
+	p := dm.pipelines[len(dm.pipelines)-1]
+	p.pending[id] = doc
+
+	return p, doc, nil
+}
+
+// findInFlight returns the document if it is in any pipeline, the manager lock must be held
+func (dm *DocumentManager) findInFlight(id uuid.UUID) *inFlightDocument {
+	for _, p := range dm.pipelines {
+		if doc, ok := p.pending[id]; ok {
+			return doc
+		}
+	}
+
+	return nil
+}
+
+func (dm *DocumentManager) untrackDocument(p *pipeline, id uuid.UUID) {
+	dm.Lock()
+	if doc, ok := p.pending[id]; ok {
+		doc.cancel(nil)
+	}
+	delete(p.pending, id)
+	idle := dm.removeIdlePipeline(p)
+	dm.Unlock()
+
+	if idle {
+		slog.Info("Stopping the pipeline of the previous configuration")
+		p.stop()
+	}
+}
@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/KyleBrandon/scriptoria/pkg/document"
+)
+
+// ReadinessChecks returns the checks for everything the document pipeline depends on, keyed by component name.
+func (dm *DocumentManager) ReadinessChecks() map[string]document.ReadinessCheck {
+	checks := map[string]document.ReadinessCheck{
+		"temp_storage_folder":        dm.checkTempStorageFolder,
+		"bundle_destination_folders": dm.checkBundleFolders,
+	}
+
+	if rr, ok := dm.srcStorage.(document.ReadinessReporter); ok {
+		for name, check := range rr.ReadinessChecks() {
+			checks[name] = check
+		}
+	}
+
+	// the processors are rebuilt when the configuration is reloaded so their checks look up the current ones
+	for name := range dm.processorChecks() {
+		checks[name] = func(ctx context.Context) error {
+			check, ok := dm.processorChecks()[name]
+			if !ok {
+				return nil
+			}
+
+			return check(ctx)
+		}
+	}
+
+	return checks
+}
+
+// processorChecks returns the checks of the processors in the current pipeline
+func (dm *DocumentManager) processorChecks() map[string]document.ReadinessCheck {
+	checks := make(map[string]document.ReadinessCheck)
+	for _, pc := range dm.currentPipeline().processors {
+		for name, check := range pc.ReadinessChecks() {
+			checks[name] = check
+		}
+	}
+
+	return checks
+}
+
+// checkTempStorageFolder will make sure the processors can stage documents in the temp folder
+func (dm *DocumentManager) checkTempStorageFolder(ctx context.Context) error {
+	file, err := os.CreateTemp(dm.Config().TempStorageFolder, ".ready-*")
+	if err != nil {
+		return err
+	}
+
+	file.Close()
+
+	return os.Remove(file.Name())
+}
+
+// checkBundleFolders will make sure every bundle has its destination folders
+func (dm *DocumentManager) checkBundleFolders(ctx context.Context) error {
+	bundles := dm.Config().Bundles
+	if len(bundles) == 0 {
+		return errors.New("no bundles are configured")
+	}
+
+	missing := make([]string, 0)
+	for _, b := range bundles {
+		for _, folder := range []string{b.DestAttachmentsFolder, b.DestNotesFolder} {
+			info, err := os.Stat(folder)
+			if err != nil || !info.IsDir() {
+				missing = append(missing, folder)
+			}
+		}
+	}
+
+	if len(missing) != 0 {
+		return fmt.Errorf("destination folders do not exist: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
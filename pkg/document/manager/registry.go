@@ -0,0 +1,39 @@
+package manager
+
+import (
+	"sort"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/pkg/document/processor"
+	"github.com/KyleBrandon/scriptoria/pkg/document/processor/chatgpt"
+	"github.com/KyleBrandon/scriptoria/pkg/document/processor/mathpix"
+	"github.com/KyleBrandon/scriptoria/pkg/document/processor/obsidian"
+	"github.com/KyleBrandon/scriptoria/pkg/document/storage"
+	"github.com/KyleBrandon/scriptoria/pkg/notify"
+	"github.com/KyleBrandon/scriptoria/pkg/tracing"
+)
+
+// processorBuilders creates each processor that can be listed in the processors of the config file
+var processorBuilders = map[string]func() processor.Processor{
+	"temp_storage": func() processor.Processor { return processor.NewTempStorageProcessor() },
+	"mathpix":      func() processor.Processor { return mathpix.NewMathpixProcessor() },
+	"chatgpt":      func() processor.Processor { return chatgpt.NewChatGPTProcessor() },
+	"obsidian":     func() processor.Processor { return obsidian.NewObsidianProcessor() },
+	"bundle":       func() processor.Processor { return processor.NewBundleProcessor() },
+}
+
+// Registry returns the storages, processors, trace exporters and notifiers the config file can reference
+func Registry() config.Registry {
+	processors := make([]string, 0, len(processorBuilders))
+	for name := range processorBuilders {
+		processors = append(processors, name)
+	}
+	sort.Strings(processors)
+
+	return config.Registry{
+		Storages:       storage.Names(),
+		Processors:     processors,
+		TraceExporters: tracing.Exporters(),
+		Notifiers:      notify.Types(),
+	}
+}
@@ -0,0 +1,159 @@
+package manager
+
+import (
+	"log/slog"
+	"reflect"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+)
+
+// Reload will apply a new configuration without a restart.  The bundles in the configuration are added, updated or
+// deleted in the database to match the file, then the enabled bundles in the database are applied.  The storage starts watching
+// the folders of new bundles and stops watching removed ones, and a new pipeline is built for the documents that
+// start after the reload.  Documents in flight finish on the pipeline they started on.  Settings that can only
+// change with a restart keep their running value and are listed in the summary.  The running configuration is
+// kept on error.
+func (dm *DocumentManager) Reload(cfg config.Config) (ReloadSummary, error) {
+	defer logging.Span("DocumentManager.Reload")()
+
+	dm.reloadMu.Lock()
+	defer dm.reloadMu.Unlock()
+
+	err := dm.seedBundles(cfg.Bundles)
+	if err != nil {
+		return ReloadSummary{}, err
+	}
+
+	return dm.apply(cfg)
+}
+
+// ReloadBundles will apply the enabled bundles in the database after they were changed through the API
+func (dm *DocumentManager) ReloadBundles() (ReloadSummary, error) {
+	defer logging.Span("DocumentManager.ReloadBundles")()
+
+	dm.reloadMu.Lock()
+	defer dm.reloadMu.Unlock()
+
+	return dm.apply(dm.Config())
+}
+
+// apply will switch the storage and processors to the configuration with the enabled bundles from the database
+func (dm *DocumentManager) apply(cfg config.Config) (ReloadSummary, error) {
+	bundles, err := dm.enabledBundles()
+	if err != nil {
+		return ReloadSummary{}, err
+	}
+
+	cfg.Bundles = bundles
+	current := dm.Config()
+	summary := diffConfig(current, cfg)
+
+	// the storage, artifacts, cache, tracing and the limit of documents in flight were set up at startup from these settings
+	cfg.SourceStore = current.SourceStore
+	cfg.ArtifactStorageFolder = current.ArtifactStorageFolder
+	cfg.Cache = current.Cache
+	cfg.Tracing = current.Tracing
+	cfg.Profiling = current.Profiling
+	cfg.DocumentLogs = current.DocumentLogs
+	cfg.Concurrency.MaxDocuments = current.Concurrency.MaxDocuments
+
+	p, err := dm.newPipeline(cfg)
+	if err != nil {
+		slog.Error("Failed to build the pipeline for the new configuration", "error", err)
+		return summary, err
+	}
+
+	if bu, ok := dm.srcStorage.(document.BundleUpdater); ok && (len(summary.BundlesAdded) != 0 || len(summary.BundlesRemoved) != 0 || len(summary.BundlesChanged) != 0) {
+		err = bu.UpdateBundles(cfg.Bundles)
+		if err != nil {
+			slog.Error("Failed to update the watched folders", "error", err)
+			p.stop()
+			return summary, err
+		}
+	}
+
+	dm.Lock()
+	dm.config = cfg
+	dm.Unlock()
+
+	dm.ledger.SetLimits(cfg.Limits)
+	configureRateLimits(cfg.Limits)
+
+	dm.replacePipeline(p)
+
+	for _, setting := range summary.RestartRequired {
+		slog.Warn("The setting changed but requires a restart", "setting", setting)
+	}
+
+	slog.Info("Reloaded the configuration",
+		"bundlesAdded", summary.BundlesAdded,
+		"bundlesRemoved", summary.BundlesRemoved,
+		"bundlesChanged", summary.BundlesChanged)
+
+	return summary, nil
+}
+
+// diffConfig will compare the bundles by source folder and list the settings that can not change while running
+func diffConfig(current, next config.Config) ReloadSummary {
+	summary := ReloadSummary{
+		BundlesAdded:    make([]string, 0),
+		BundlesRemoved:  make([]string, 0),
+		BundlesChanged:  make([]string, 0),
+		RestartRequired: make([]string, 0),
+	}
+
+	bundles := make(map[string]config.StorageBundle)
+	for _, b := range current.Bundles {
+		bundles[b.SourceFolder] = b
+	}
+
+	for _, b := range next.Bundles {
+		previous, ok := bundles[b.SourceFolder]
+		switch {
+		case !ok:
+			summary.BundlesAdded = append(summary.BundlesAdded, b.SourceFolder)
+		case !reflect.DeepEqual(previous, b):
+			summary.BundlesChanged = append(summary.BundlesChanged, b.SourceFolder)
+		}
+
+		delete(bundles, b.SourceFolder)
+	}
+
+	for _, b := range current.Bundles {
+		if _, ok := bundles[b.SourceFolder]; ok {
+			summary.BundlesRemoved = append(summary.BundlesRemoved, b.SourceFolder)
+		}
+	}
+
+	if current.SourceStore != next.SourceStore {
+		summary.RestartRequired = append(summary.RestartRequired, "source_store")
+	}
+
+	if current.ArtifactStorageFolder != next.ArtifactStorageFolder {
+		summary.RestartRequired = append(summary.RestartRequired, "artifact_storage_folder")
+	}
+
+	if !reflect.DeepEqual(current.Cache, next.Cache) {
+		summary.RestartRequired = append(summary.RestartRequired, "cache")
+	}
+
+	if !reflect.DeepEqual(current.Tracing, next.Tracing) {
+		summary.RestartRequired = append(summary.RestartRequired, "tracing")
+	}
+
+	if current.Concurrency.MaxDocuments != next.Concurrency.MaxDocuments {
+		summary.RestartRequired = append(summary.RestartRequired, "concurrency.max_documents")
+	}
+
+	if current.DocumentLogs != next.DocumentLogs {
+		summary.RestartRequired = append(summary.RestartRequired, "document_logs")
+	}
+
+	if current.Profiling != next.Profiling {
+		summary.RestartRequired = append(summary.RestartRequired, "profiling")
+	}
+
+	return summary
+}
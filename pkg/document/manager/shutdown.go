@@ -0,0 +1,192 @@
+package manager
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/document/processor"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/google/uuid"
+)
+
+// How often to check if the documents in flight have finished while draining
+const drainPollInterval = 250 * time.Millisecond
+
+// Shutdown will stop taking new documents and wait up to the grace period for the documents in flight to finish.
+// Documents that are still in flight after the grace period are checkpointed so they resume the next time the
+// manager starts.  The manager is canceled once it returns.
+func (dm *DocumentManager) Shutdown(gracePeriod time.Duration) {
+	defer logging.Span("DocumentManager.Shutdown")()
+
+	// stop reading new documents from the storage
+	dm.intakeCancel()
+
+	slog.Info("Waiting for documents in flight to finish", "count", dm.inFlightCount(), "gracePeriod", gracePeriod)
+
+	deadline := time.After(gracePeriod)
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+drain:
+	for dm.inFlightCount() != 0 {
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			dm.checkpointInFlight()
+			break drain
+		}
+	}
+
+	// the stages see the shutdown as the cause and leave the checkpoints in place
+	dm.cancelCauseFunc(processor.ErrShutdown)
+	dm.CancelAndWait()
+}
+
+func (dm *DocumentManager) inFlightCount() int {
+	dm.Lock()
+	defer dm.Unlock()
+
+	count := 0
+	for _, p := range dm.pipelines {
+		count += len(p.pending)
+	}
+
+	return count
+}
+
+// checkpointInFlight will record the stage each unfinished document should resume from.  The checkpoints are
+// written even if the context of the manager was canceled.
+func (dm *DocumentManager) checkpointInFlight() {
+	ctx := context.WithoutCancel(dm.ctx)
+
+	type inFlight struct {
+		p   *pipeline
+		doc *inFlightDocument
+	}
+
+	dm.Lock()
+	docs := make(map[uuid.UUID]inFlight)
+	for _, p := range dm.pipelines {
+		for id, doc := range p.pending {
+			docs[id] = inFlight{p: p, doc: doc}
+		}
+	}
+	dm.Unlock()
+
+	for id, f := range docs {
+		dm.checkpoint(ctx, f.p, f.doc, id)
+	}
+}
+
+// checkpoint will record the stage the document should resume from unless it finished in the meantime
+func (dm *DocumentManager) checkpoint(ctx context.Context, p *pipeline, doc *inFlightDocument, id uuid.UUID) {
+	doc.mu.Lock()
+	defer doc.mu.Unlock()
+
+	if doc.finished {
+		return
+	}
+
+	stage, err := dm.resumeStage(ctx, p, id)
+	if err != nil {
+		slog.Error("Failed to determine the stage to resume the document from", "id", id, "error", err)
+		return
+	}
+
+	args := database.SetDocumentResumeStageParams{
+		ID:          id,
+		ResumeStage: sql.NullString{String: stage, Valid: true},
+	}
+
+	err = dm.store.SetDocumentResumeStage(ctx, args)
+	if err != nil {
+		slog.Error("Failed to checkpoint the document", "id", id, "error", err)
+		return
+	}
+
+	doc.checkpointed = true
+	dm.updateDocumentProcessingStatus(ctx, id, "Interrupted: resumable from "+stage)
+	slog.Info("Checkpointed unfinished document", "id", id, "resumeStage", stage)
+}
+
+// finish will mark the document as done with the pipeline and clear a checkpoint the shutdown wrote before it
+// finished, so it is not run again the next time the manager starts
+func (dm *DocumentManager) finish(doc *inFlightDocument, id uuid.UUID) {
+	doc.mu.Lock()
+	defer doc.mu.Unlock()
+
+	doc.finished = true
+	if !doc.checkpointed {
+		return
+	}
+
+	err := dm.store.SetDocumentResumeStage(context.WithoutCancel(dm.ctx), database.SetDocumentResumeStageParams{ID: id})
+	if err != nil {
+		slog.Error("Failed to clear the checkpoint of the finished document", "id", id, "error", err)
+	}
+}
+
+// resumeStage will find the stage to resume the document from based on its event history.  A stage that started
+// but did not succeed is run again, otherwise processing continues with the stage after the last one that succeeded.
+func (dm *DocumentManager) resumeStage(ctx context.Context, p *pipeline, id uuid.UUID) (string, error) {
+	events, err := dm.store.GetDocumentEventsByDocumentId(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	first := p.processors[0].Name()
+	if len(events) == 0 {
+		return first, nil
+	}
+
+	last := events[len(events)-1]
+	if last.Status != processor.EventStatusSucceeded {
+		return last.Stage, nil
+	}
+
+	for i, pc := range p.processors {
+		if pc.Name() == last.Stage && i+1 < len(p.processors) {
+			return p.processors[i+1].Name(), nil
+		}
+	}
+
+	// the last stage finished, run it again so the document is archived
+	return last.Stage, nil
+}
+
+// resumeInterruptedDocuments will restart the documents that were checkpointed by the last shutdown
+func (dm *DocumentManager) resumeInterruptedDocuments() {
+	docs, err := dm.store.GetResumableDocuments(dm.ctx)
+	if err != nil {
+		slog.Error("Failed to query the interrupted documents", "error", err)
+		return
+	}
+
+	for _, d := range docs {
+		args := database.SetDocumentResumeStageParams{ID: d.ID}
+		err = dm.store.SetDocumentResumeStage(dm.ctx, args)
+		if err != nil {
+			slog.Error("Failed to clear the document checkpoint", "id", d.ID, "error", err)
+			continue
+		}
+
+		dm.wg.Add(1)
+		go func() {
+			defer dm.wg.Done()
+
+			// the checkpoint was cleared, so keep waiting through a drain and only give up when the manager stops
+			if !dm.acquireSlot(dm.ctx) {
+				return
+			}
+			defer dm.releaseSlot()
+
+			err := dm.ResumeDocument(d.ID, d.ResumeStage.String)
+			if err != nil {
+				slog.Error("Failed to resume the interrupted document", "id", d.ID, "stage", d.ResumeStage.String, "error", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,167 @@
+package manager
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/document/processor"
+	"github.com/google/uuid"
+)
+
+// namedProcessor is a stage that is only looked up by name
+type namedProcessor string
+
+func (p namedProcessor) Initialize(tempStoragePath string, bundles []config.StorageBundle) error {
+	return nil
+}
+
+func (p namedProcessor) Process(ctx context.Context, doc *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
+	return reader, nil
+}
+
+func (p namedProcessor) GetName() string {
+	return string(p)
+}
+
+// checkpointStore records the resume stages written for the documents
+type checkpointStore struct {
+	DocumentManagerStore
+
+	events       []database.DocumentEvent
+	resumeStages []database.SetDocumentResumeStageParams
+}
+
+func (s *checkpointStore) GetDocumentEventsByDocumentId(ctx context.Context, documentID uuid.UUID) ([]database.DocumentEvent, error) {
+	return s.events, nil
+}
+
+func (s *checkpointStore) SetDocumentResumeStage(ctx context.Context, arg database.SetDocumentResumeStageParams) error {
+	s.resumeStages = append(s.resumeStages, arg)
+	return nil
+}
+
+func (s *checkpointStore) UpdateDocumentProcessed(ctx context.Context, arg database.UpdateDocumentProcessedParams) (database.Document, error) {
+	return database.Document{}, nil
+}
+
+// newTestPipeline returns a pipeline of the stages in order
+func newTestPipeline(stages ...string) *pipeline {
+	p := &pipeline{pending: make(map[uuid.UUID]*inFlightDocument)}
+	for _, stage := range stages {
+		p.processors = append(p.processors, processor.New(processor.ProcessorConfig{}, namedProcessor(stage)))
+	}
+
+	return p
+}
+
+func TestResumeStage(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []database.DocumentEvent
+		want   string
+	}{
+		{name: "no events", want: "temp"},
+		{
+			name:   "stage started",
+			events: []database.DocumentEvent{{Stage: "temp", Status: processor.EventStatusSucceeded}, {Stage: "mathpix", Status: processor.EventStatusStarted}},
+			want:   "mathpix",
+		},
+		{
+			name:   "stage succeeded",
+			events: []database.DocumentEvent{{Stage: "temp", Status: processor.EventStatusSucceeded}},
+			want:   "mathpix",
+		},
+		{
+			name:   "last stage succeeded",
+			events: []database.DocumentEvent{{Stage: "bundle", Status: processor.EventStatusSucceeded}},
+			want:   "bundle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dm := &DocumentManager{ctx: context.Background(), store: &checkpointStore{events: tt.events}}
+
+			got, err := dm.resumeStage(context.Background(), newTestPipeline("temp", "mathpix", "bundle"), uuid.New())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("resumeStage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckpointFinishedDocuments(t *testing.T) {
+	tests := []struct {
+		name            string
+		finishBefore    bool
+		finishAfter     bool
+		wantCheckpoint  bool
+		wantResumeStage []string
+	}{
+		{
+			name:            "document still in flight",
+			wantCheckpoint:  true,
+			wantResumeStage: []string{"mathpix"},
+		},
+		{
+			name:         "document finished before the checkpoint",
+			finishBefore: true,
+		},
+		{
+			name:            "document finished after the checkpoint",
+			finishAfter:     true,
+			wantCheckpoint:  true,
+			wantResumeStage: []string{"mathpix", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &checkpointStore{
+				events: []database.DocumentEvent{{Stage: "temp", Status: processor.EventStatusSucceeded}},
+			}
+			dm := &DocumentManager{ctx: context.Background(), store: store}
+
+			id := uuid.New()
+			doc := &inFlightDocument{}
+			p := newTestPipeline("temp", "mathpix", "bundle")
+			p.pending[id] = doc
+			dm.pipelines = []*pipeline{p}
+
+			if tt.finishBefore {
+				dm.finish(doc, id)
+			}
+
+			dm.checkpointInFlight()
+
+			if tt.finishAfter {
+				dm.finish(doc, id)
+			}
+
+			if doc.checkpointed != tt.wantCheckpoint {
+				t.Errorf("checkpointed = %v, want %v", doc.checkpointed, tt.wantCheckpoint)
+			}
+
+			got := make([]string, 0, len(store.resumeStages))
+			for _, arg := range store.resumeStages {
+				got = append(got, arg.ResumeStage.String)
+				if arg.ID != id || arg.ResumeStage.Valid != (len(arg.ResumeStage.String) != 0) {
+					t.Errorf("unexpected resume stage write %+v", arg)
+				}
+			}
+
+			if !slices.Equal(got, tt.wantResumeStage) {
+				t.Errorf("resume stages = %q, want %q", got, tt.wantResumeStage)
+			}
+		})
+	}
+}
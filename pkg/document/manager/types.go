@@ -2,21 +2,42 @@ package manager
 
 import (
 	"context"
+	"errors"
 	"sync"
 
 	"github.com/KyleBrandon/scriptoria/internal/config"
 	"github.com/KyleBrandon/scriptoria/internal/database"
 	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/document/artifact"
+	"github.com/KyleBrandon/scriptoria/pkg/document/cache"
 	"github.com/KyleBrandon/scriptoria/pkg/document/processor"
+	"github.com/KyleBrandon/scriptoria/pkg/events"
+	"github.com/KyleBrandon/scriptoria/pkg/usage"
 	"github.com/google/uuid"
 )
 
+var (
+	ErrStageNotFound  = errors.New("could not find the processing stage")
+	ErrDocumentExists = errors.New("the document has already been processed")
+
+	ErrDocumentInFlight    = errors.New("the document is already being processed")
+	ErrDocumentNotInFlight = errors.New("the document is not being processed")
+	ErrDocumentCanceled    = errors.New("processing the document was canceled")
+)
+
 type (
 	DocumentManagerStore interface {
 		CreateDocument(ctx context.Context, arg database.CreateDocumentParams) (database.Document, error)
 		GetDocumentById(ctx context.Context, id uuid.UUID) (database.Document, error)
 		FindDocumentBySourceId(ctx context.Context, sourceID string) (database.Document, error)
 		UpdateDocumentProcessed(ctx context.Context, arg database.UpdateDocumentProcessedParams) (database.Document, error)
+		GetLatestStageArtifact(ctx context.Context, arg database.GetLatestStageArtifactParams) (database.DocumentEvent, error)
+		GetDocumentEventsByDocumentId(ctx context.Context, documentID uuid.UUID) ([]database.DocumentEvent, error)
+		SetDocumentResumeStage(ctx context.Context, arg database.SetDocumentResumeStageParams) error
+		GetResumableDocuments(ctx context.Context) ([]database.Document, error)
+		SeedBundle(ctx context.Context, arg database.SeedBundleParams) error
+		DeleteConfigBundlesNotIn(ctx context.Context, sourceFolders []string) error
+		ListEnabledBundles(ctx context.Context) ([]database.Bundle, error)
 	}
 
 	DocumentManager struct {
@@ -24,12 +45,62 @@ type (
 
 		ctx             context.Context
 		cancelCauseFunc context.CancelCauseFunc
+		intakeCtx       context.Context
+		intakeCancel    context.CancelFunc
 		wg              *sync.WaitGroup
 		config          config.Config
 		store           DocumentManagerStore
 		srcStorage      document.Storage
+		processorStore  processor.ProcessorStore
+		artifacts       artifact.Store
+		cache           *cache.Cache
+		events          *events.Bus
+		ledger          *usage.Ledger
+
+		// holds a value for every document in flight, intake blocks when it is full
+		slots chan struct{}
+
+		// serializes reloads of the configuration
+		reloadMu sync.Mutex
+
+		// the last pipeline receives new documents, the others finish the documents they already have
+		pipelines []*pipeline
+	}
+
+	// pipeline is the chain of processors built from one version of the configuration.  A document stays on the
+	// pipeline it started on, so reloading the configuration does not change the processors under it.
+	pipeline struct {
+		ctx             context.Context
+		cancelCauseFunc context.CancelCauseFunc
+		wg              *sync.WaitGroup
+		config          config.Config
 		processors      []*processor.ProcessorContext
-		inputCh         chan *document.TransformContext
 		outputCh        chan *document.TransformContext
+		retired         bool
+
+		// documents in the pipeline keyed by ID, guarded by the manager lock
+		pending map[uuid.UUID]*inFlightDocument
+	}
+
+	// inFlightDocument is a document that is being processed
+	inFlightDocument struct {
+		ctx    context.Context
+		cancel context.CancelCauseFunc
+
+		// receives the document when it leaves the last processor
+		done chan *document.TransformContext
+
+		// guards the checkpoint of the shutdown against the document finishing at the same time
+		mu           sync.Mutex
+		finished     bool
+		checkpointed bool
+	}
+
+	// ReloadSummary describes what changed when the configuration was reloaded
+	ReloadSummary struct {
+		BundlesAdded    []string `json:"bundles_added"`
+		BundlesRemoved  []string `json:"bundles_removed"`
+		BundlesChanged  []string `json:"bundles_changed"`
+		RestartRequired []string `json:"restart_required"`
 	}
 )
@@ -6,17 +6,25 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"strings"
 
 	"github.com/KyleBrandon/scriptoria/internal/config"
 	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/KyleBrandon/scriptoria/pkg/metrics"
+	"github.com/KyleBrandon/scriptoria/pkg/ratelimit"
+	"github.com/KyleBrandon/scriptoria/pkg/usage"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // NewChatGPTProcessor will return processor that will send the document through ChatGPT with instructions to clean the formatting, spelling, and grammar.
 func NewChatGPTProcessor() *ChatgptDocumentProcessor {
-	cp := &ChatgptDocumentProcessor{}
+	cp := &ChatgptDocumentProcessor{
+		httpClient: &http.Client{Transport: otelhttp.NewTransport(ratelimit.Transport(usage.ProviderOpenAI, metrics.InstrumentTransport(usage.ProviderOpenAI, nil)))},
+	}
 
 	return cp
 }
@@ -25,6 +33,16 @@ func (lp *ChatgptDocumentProcessor) GetName() string {
 	return "ChatGPT Document Processor"
 }
 
+// Provider returns the name the OpenAI budget and rate limits are kept under
+func (cp *ChatgptDocumentProcessor) Provider() string {
+	return usage.ProviderOpenAI
+}
+
+// CacheOptions returns the model and temperature since they change the cleaned up output
+func (cp *ChatgptDocumentProcessor) CacheOptions(document *document.Document) string {
+	return fmt.Sprintf("model=%s;temperature=%g", chatgptModel, chatgptTemperature)
+}
+
 func (cp *ChatgptDocumentProcessor) Initialize(tempStoragePath string, bundles []config.StorageBundle) error {
 	err := cp.readConfigurationSettings()
 	if err != nil {
@@ -35,6 +53,19 @@ func (cp *ChatgptDocumentProcessor) Initialize(tempStoragePath string, bundles [
 	return nil
 }
 
+// ReadinessChecks will report if the OpenAI credentials are configured
+func (cp *ChatgptDocumentProcessor) ReadinessChecks() map[string]document.ReadinessCheck {
+	return map[string]document.ReadinessCheck{
+		"openai_credentials": func(ctx context.Context) error {
+			if len(os.Getenv("CHATGPT_API_KEY")) == 0 {
+				return errors.New("environment variable CHATGPT_API_KEY is not present")
+			}
+
+			return nil
+		},
+	}
+}
+
 func (cp *ChatgptDocumentProcessor) readConfigurationSettings() error {
 	cp.chatgptAPIKey = os.Getenv("CHATGPT_API_KEY")
 	if len(cp.chatgptAPIKey) == 0 {
@@ -44,40 +75,53 @@ func (cp *ChatgptDocumentProcessor) readConfigurationSettings() error {
 	return nil
 }
 
-func (cp *ChatgptDocumentProcessor) Process(document *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
-	slog.Debug(">>ChatgptDocumentProcessor.processDocument")
-	defer slog.Debug("<<ChatgptDocumentProcessor.processDocument")
+func (cp *ChatgptDocumentProcessor) Process(ctx context.Context, document *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
+	defer logging.SpanContext(ctx, "ChatgptDocumentProcessor.processDocument")()
 
 	// Initialize OpenAI client
-	client := openai.NewClient(cp.chatgptAPIKey)
+	clientConfig := openai.DefaultConfig(cp.chatgptAPIKey)
+	clientConfig.HTTPClient = cp.httpClient
+	client := openai.NewClientWithConfig(clientConfig)
 
 	content, err := io.ReadAll(reader)
-	if err != err {
-		slog.Error("Failed to read the input document to clean up", "error", err)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to read the input document to clean up", "error", err)
 		return nil, err
 	}
 
 	// Create a prompt for GPT to clean up the Markdown
-	systemMessage := "You are an AI that processes Markdown text. Your task is to clean up the input by fixing Markdown syntax, correcting spelling and grammar, and ensuring proper formatting. Do NOT include any extra explanations, comments, or surrounding text—only return the valid Markdown output."
+	systemMessage := "You are an AI that processes Markdown text. Your task is to clean up the input by fixing Markdown syntax, correcting spelling and grammar, and ensuring proper formatting. Keep Obsidian embeds such as ![[image.jpg]] exactly as they are. Do NOT include any extra explanations, comments, or surrounding text—only return the valid Markdown output."
 	prompt := fmt.Sprintf("Here is a Markdown file that was generated via OCR. Fix the Markdown formatting, correct any spelling and grammar errors, and ensure the syntax is valid. Do not add any explanations,comments, and do not surround the document text in a markdown code block. ONLY RETURN THE CLEANED MARKDOWN CONTENT AND NOTHING ELSE:\n\n%s", content)
 
+	// wait for the tokens the request is expected to use, the estimate is corrected once the usage is known
+	limiter := ratelimit.Get(usage.ProviderOpenAI)
+	estimate := estimateTokens(systemMessage, prompt)
+	err = limiter.WaitTokens(ctx, estimate)
+	if err != nil {
+		return nil, err
+	}
+
 	// Call the ChatGPT API
 	resp, err := client.CreateChatCompletion(
-		context.Background(),
+		ctx,
 		openai.ChatCompletionRequest{
-			Model: openai.GPT4o,
+			Model: chatgptModel,
 			Messages: []openai.ChatCompletionMessage{
 				{Role: "system", Content: systemMessage},
 				{Role: "user", Content: prompt},
 			},
-			Temperature: 0.2, // Keep responses precise
+			Temperature: chatgptTemperature,
 		},
 	)
 	if err != nil {
-		slog.Error("ChatGPT API error", "error", err)
+		logging.FromContext(ctx).Error("ChatGPT API error", "error", err)
 		return nil, err
 	}
 
+	limiter.AdjustTokens(resp.Usage.TotalTokens - estimate)
+	usage.Add(ctx, usage.ProviderOpenAI, usage.UnitPromptTokens, int64(resp.Usage.PromptTokens))
+	usage.Add(ctx, usage.ProviderOpenAI, usage.UnitCompletionTokens, int64(resp.Usage.CompletionTokens))
+
 	// Get the cleaned-up text
 	buffer := resp.Choices[0].Message.Content
 
@@ -90,3 +134,14 @@ func (cp *ChatgptDocumentProcessor) Process(document *document.Document, reader
 
 	return r, nil
 }
+
+// estimateTokens will guess the tokens of the request before it is sent.  A token is about four characters, and
+// the cleaned up document is about as long as the prompt.
+func estimateTokens(messages ...string) int {
+	chars := 0
+	for _, m := range messages {
+		chars += len(m)
+	}
+
+	return chars / charsPerToken * 2
+}
@@ -1,7 +1,22 @@
 package chatgpt
 
+import (
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	chatgptModel       = openai.GPT4o
+	chatgptTemperature = 0.2 // Keep responses precise
+
+	// used to estimate the tokens of a request before it is sent
+	charsPerToken = 4
+)
+
 type (
 	ChatgptDocumentProcessor struct {
+		httpClient    *http.Client
 		chatgptAPIKey string
 	}
 )
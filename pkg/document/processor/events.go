@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"database/sql"
+	"io"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/document/artifact"
+	"github.com/KyleBrandon/scriptoria/pkg/events"
+)
+
+// Document event statuses recorded for every stage transition
+const (
+	EventStatusStarted   = "started"
+	EventStatusSucceeded = "succeeded"
+	EventStatusFailed    = "failed"
+	EventStatusRetried   = "retried"
+)
+
+// stageEvent holds the measurements for a single stage transition before it is written to the database.
+type stageEvent struct {
+	status   string
+	err      error
+	duration time.Duration
+	attempt  int32
+	bytesIn  int64
+	artifact *artifact.Artifact
+}
+
+// countingReadCloser counts the bytes read through it.
+type countingReadCloser struct {
+	io.ReadCloser
+	count int64
+}
+
+func newCountingReadCloser(rc io.ReadCloser) *countingReadCloser {
+	return &countingReadCloser{ReadCloser: rc}
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// nextAttempt will determine the attempt number for this stage based on the previous events for the document.
+func (pc *ProcessorContext) nextAttempt(tc *document.TransformContext) int32 {
+	args := database.CountDocumentStageAttemptsParams{
+		DocumentID: tc.DocumentID,
+		Stage:      pc.processor.GetName(),
+	}
+
+	count, err := pc.store.CountDocumentStageAttempts(pc.ctx, args)
+	if err != nil {
+		pc.logger(tc).Error("Failed to count the previous stage attempts", "error", err)
+		return 1
+	}
+
+	return int32(count) + 1
+}
+
+// recordEvent will write the stage transition to the document history.
+func (pc *ProcessorContext) recordEvent(tc *document.TransformContext, e stageEvent) {
+	args := database.CreateDocumentEventParams{
+		DocumentID: tc.DocumentID,
+		Stage:      pc.processor.GetName(),
+		Status:     e.status,
+		Attempt:    e.attempt,
+	}
+
+	if e.err != nil {
+		args.ErrorMessage = sql.NullString{String: e.err.Error(), Valid: true}
+	}
+
+	// only the finishing transitions have measurements
+	if e.status != EventStatusStarted && e.status != EventStatusRetried {
+		args.DurationMs = sql.NullInt64{Int64: e.duration.Milliseconds(), Valid: true}
+		args.BytesIn = sql.NullInt64{Int64: e.bytesIn, Valid: true}
+	}
+
+	// link the stage output so it can be compared or used to restart the document
+	if e.artifact != nil {
+		args.BytesOut = sql.NullInt64{Int64: e.artifact.Size, Valid: true}
+		args.ArtifactHash = sql.NullString{String: e.artifact.Hash, Valid: true}
+	}
+
+	_, err := pc.store.CreateDocumentEvent(pc.ctx, args)
+	if err != nil {
+		pc.logger(tc).Error("Failed to record the document event", "status", e.status, "error", err)
+	}
+
+	pc.publishEvent(tc, e)
+}
+
+// publishEvent will send the stage transition to the subscribers of the document lifecycle events
+func (pc *ProcessorContext) publishEvent(tc *document.TransformContext, e stageEvent) {
+	eventType := events.TypeStageStarted
+	switch e.status {
+	case EventStatusSucceeded:
+		eventType = events.TypeStageFinished
+	case EventStatusFailed:
+		eventType = events.TypeFailed
+	}
+
+	event := events.NewDocumentEvent(eventType, tc.DocumentID, tc.SourceDocument, pc.bundles)
+	event.Stage = pc.processor.GetName()
+	if e.err != nil {
+		event.Error = e.err.Error()
+	}
+
+	pc.events.Publish(event)
+}
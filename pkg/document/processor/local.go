@@ -1,13 +1,14 @@
 package processor
 
 import (
+	"context"
 	"io"
-	"log/slog"
 	"os"
 	"path/filepath"
 
 	"github.com/KyleBrandon/scriptoria/internal/config"
 	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
 )
 
 type LocalDocumentProcessor struct {
@@ -30,9 +31,8 @@ func (lp *LocalDocumentProcessor) Initialize(tempStoragePath string, bundles []c
 	return nil
 }
 
-func (lp *LocalDocumentProcessor) Process(document *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
-	slog.Debug(">>LocalDocumentProcessor.processDocument")
-	defer slog.Debug("<<LocalDocumentProcessor.processDocument")
+func (lp *LocalDocumentProcessor) Process(ctx context.Context, document *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
+	defer logging.SpanContext(ctx, "LocalDocumentProcessor.processDocument")()
 
 	// build a local file path
 	fullFilePath := filepath.Join(lp.destinationPath, document.Name)
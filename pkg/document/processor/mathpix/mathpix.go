@@ -2,6 +2,7 @@ package mathpix
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,18 +10,32 @@ import (
 	"log/slog"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"time"
 
 	"github.com/KyleBrandon/scriptoria/internal/config"
 	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/document/processor"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/KyleBrandon/scriptoria/pkg/metrics"
+	"github.com/KyleBrandon/scriptoria/pkg/ratelimit"
+	"github.com/KyleBrandon/scriptoria/pkg/usage"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // NewMathpixProcessor will create a document processor to send to the Mathix PDF API to get a Markdown version of the document.
 // The reader that is returned will be for an in-memory version of the Markdown file.
 func NewMathpixProcessor() *MathpixDocumentProcessor {
-	mp := &MathpixDocumentProcessor{}
+	mp := &MathpixDocumentProcessor{
+		client:          &http.Client{Transport: otelhttp.NewTransport(ratelimit.Transport(usage.ProviderMathpix, metrics.InstrumentTransport(usage.ProviderMathpix, nil)))},
+		cdnClient:       &http.Client{Transport: otelhttp.NewTransport(metrics.InstrumentTransport(cdnProvider, nil))},
+		maxPollDuration: DefaultMaxPollDuration,
+	}
 
 	mp.readConfigurationSettings()
 	return mp
@@ -30,8 +45,32 @@ func (lp *MathpixDocumentProcessor) GetName() string {
 	return "Mathpix Document Processor"
 }
 
+// Provider returns the name the Mathpix budget and rate limits are kept under
+func (mp *MathpixDocumentProcessor) Provider() string {
+	return usage.ProviderMathpix
+}
+
+// CacheOptions returns the options the bundle of the document converts its PDFs with, the default conversion has
+// none.
+func (mp *MathpixDocumentProcessor) CacheOptions(document *document.Document) string {
+	options := mp.requestOptions(document)
+	if reflect.ValueOf(options).IsZero() {
+		return ""
+	}
+
+	// the options only hold strings, booleans, lists and maps so encoding them can not fail
+	data, _ := json.Marshal(options)
+	return string(data)
+}
+
+// SetMaxPollDuration sets how long Mathpix may take to convert a PDF
+func (mp *MathpixDocumentProcessor) SetMaxPollDuration(d time.Duration) {
+	mp.maxPollDuration = d
+}
+
 func (mp *MathpixDocumentProcessor) Initialize(tempStoragePath string, bundles []config.StorageBundle) error {
 	mp.tempStoragePath = tempStoragePath
+	mp.bundles = bundles
 
 	err := mp.readConfigurationSettings()
 	if err != nil {
@@ -42,29 +81,51 @@ func (mp *MathpixDocumentProcessor) Initialize(tempStoragePath string, bundles [
 	return nil
 }
 
-func (mp *MathpixDocumentProcessor) Process(document *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
-	slog.Debug(">>MathpixDocumentProcessor.processDocument")
-	defer slog.Debug("<<MathpixDocumentProcessor.processDocument")
+func (mp *MathpixDocumentProcessor) Process(ctx context.Context, document *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
+	defer logging.SpanContext(ctx, "MathpixDocumentProcessor.processDocument")()
 
 	sourceName := document.Name
+	options := mp.requestOptions(document)
 
 	// Upload PDF to Mathpix
-	pdfID, err := mp.sendDocumentToMathpix(sourceName, reader)
+	pdfID, err := mp.sendDocumentToMathpix(ctx, sourceName, options, reader)
 	if err != nil {
-		slog.Error("Error uploading PDF", "error", err)
+		logging.FromContext(ctx).Error("Error uploading PDF", "error", err)
 		return nil, err
 	}
 
+	// the conversion formats are converted after the Markdown and share the time it may take
+	deadline := time.Now().Add(mp.maxPollDuration)
+
 	// Poll for results
-	err = mp.pollForResults(pdfID)
+	err = mp.pollForResults(ctx, pdfID, deadline)
 	if err != nil {
-		slog.Error("Error getting results", "error", err)
+		logging.FromContext(ctx).Error("Error getting results", "error", err)
 		return nil, err
 	}
 
-	markdownText, err := mp.queryConversionResults(pdfID)
+	if len(options.ConversionFormats) != 0 {
+		err = mp.pollForConversions(ctx, pdfID, deadline)
+		if err != nil {
+			logging.FromContext(ctx).Error("Error converting the PDF to the requested formats", "error", err)
+			return nil, err
+		}
+
+		err = mp.downloadFormats(ctx, pdfID, sourceName, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	markdownText, err := mp.queryConversionResults(ctx, pdfID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to query conversion results", "error", err)
+		return nil, err
+	}
+
+	// the links to the Mathpix CDN expire, so the images are kept with the PDF and embedded from there
+	markdownText, err = mp.downloadImages(ctx, sourceName, markdownText)
 	if err != nil {
-		slog.Error("Failed to query conversion results", "error", err)
 		return nil, err
 	}
 
@@ -80,6 +141,19 @@ func (mp *MathpixDocumentProcessor) Process(document *document.Document, reader
 	return r, nil
 }
 
+// ReadinessChecks will report if the Mathpix credentials are configured
+func (mp *MathpixDocumentProcessor) ReadinessChecks() map[string]document.ReadinessCheck {
+	return map[string]document.ReadinessCheck{
+		"mathpix_credentials": func(ctx context.Context) error {
+			if len(os.Getenv("MATHPIX_APP_ID")) == 0 || len(os.Getenv("MATHPIX_APP_KEY")) == 0 {
+				return errors.New("environment variables MATHPIX_APP_ID and MATHPIX_APP_KEY must be set")
+			}
+
+			return nil
+		},
+	}
+}
+
 // Initialize environment variables
 func (mp *MathpixDocumentProcessor) readConfigurationSettings() error {
 	mp.mathpixAppID = os.Getenv("MATHPIX_APP_ID")
@@ -95,32 +169,75 @@ func (mp *MathpixDocumentProcessor) readConfigurationSettings() error {
 	return nil
 }
 
+// requestOptions will build the options_json of the upload from the Mathpix options of the document's bundle
+func (mp *MathpixDocumentProcessor) requestOptions(document *document.Document) RequestOptions {
+	var bundleOptions config.MathpixOptions
+	for _, b := range mp.bundles {
+		if b.SourceFolder == document.StorageFolderID {
+			bundleOptions = b.Mathpix
+			break
+		}
+	}
+
+	options := RequestOptions{
+		MathInlineDelimiters:  bundleOptions.MathInlineDelimiters,
+		MathDisplayDelimiters: bundleOptions.MathDisplayDelimiters,
+		RmSpaces:              bundleOptions.RmSpaces,
+		EnableTablesFallback:  bundleOptions.EnableTablesFallback,
+		PageRanges:            bundleOptions.PageRanges,
+		AlphabetsAllowed:      bundleOptions.AlphabetsAllowed,
+	}
+
+	if len(bundleOptions.ConversionFormats) != 0 {
+		options.ConversionFormats = make(map[string]bool, len(bundleOptions.ConversionFormats))
+		for _, format := range bundleOptions.ConversionFormats {
+			options.ConversionFormats[format] = true
+		}
+	}
+
+	return options
+}
+
 // UploadPDF uploads a PDF file to Mathpix and returns the Job ID
-func (mp *MathpixDocumentProcessor) sendDocumentToMathpix(name string, reader io.Reader) (string, error) {
-	slog.Debug(">>sendDocumentToMathpix")
-	defer slog.Debug("<<sendDocumentToMathpix")
+func (mp *MathpixDocumentProcessor) sendDocumentToMathpix(ctx context.Context, name string, options RequestOptions, reader io.Reader) (string, error) {
+	defer logging.SpanContext(ctx, "sendDocumentToMathpix")()
 
 	// Create multipart form data
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 	part, err := writer.CreateFormFile("file", name)
 	if err != nil {
-		slog.Error("Failed to create form file", "error", err)
+		logging.FromContext(ctx).Error("Failed to create form file", "error", err)
 		return "", err
 	}
 
 	// copy the document input to the request body
 	_, err = io.Copy(part, reader)
 	if err != nil {
-		slog.Error("Failed to copy file to form part", "error", err)
+		logging.FromContext(ctx).Error("Failed to copy file to form part", "error", err)
 		return "", err
 	}
+
+	// the default conversion is requested without options
+	if !reflect.ValueOf(options).IsZero() {
+		optionsJSON, err := json.Marshal(options)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to marshal the mathpix options", "error", err)
+			return "", err
+		}
+
+		err = writer.WriteField("options_json", string(optionsJSON))
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to write the mathpix options to the form", "error", err)
+			return "", err
+		}
+	}
 	writer.Close()
 
 	// Create HTTP request
-	req, err := mp.newRequest("POST", MathpixPdfApiURL, body)
+	req, err := mp.newRequest(ctx, "POST", MathpixPdfApiURL, body)
 	if err != nil {
-		slog.Error("Failed to create POST request for mathpix API", "error", err)
+		logging.FromContext(ctx).Error("Failed to create POST request for mathpix API", "error", err)
 		return "", err
 	}
 
@@ -130,7 +247,7 @@ func (mp *MathpixDocumentProcessor) sendDocumentToMathpix(name string, reader io
 	// send the request
 	respBody, err := mp.doRequest(req)
 	if err != nil {
-		slog.Error("Failed to send mathpix request", "error", err)
+		logging.FromContext(ctx).Error("Failed to send mathpix request", "error", err)
 		return "", err
 	}
 
@@ -138,7 +255,7 @@ func (mp *MathpixDocumentProcessor) sendDocumentToMathpix(name string, reader io
 	var uploadResp UploadResponse
 	err = json.Unmarshal(respBody, &uploadResp)
 	if err != nil {
-		slog.Error("Failed to unmarshal mathpix response", "error", err)
+		logging.FromContext(ctx).Error("Failed to unmarshal mathpix response", "error", err)
 		return "", err
 	}
 
@@ -149,72 +266,269 @@ func (mp *MathpixDocumentProcessor) sendDocumentToMathpix(name string, reader io
 	return uploadResp.PdfID, nil
 }
 
-// PollForResults polls Mathpix API for PDF processing status
-func (mp *MathpixDocumentProcessor) pollForResults(pdfID string) error {
-	slog.Debug(">>PollForResults", "pdfID", pdfID)
-	defer slog.Debug("<<PollForResults")
+// PollForResults polls Mathpix API for PDF processing status until the conversion finishes, fails or the
+// deadline passes
+func (mp *MathpixDocumentProcessor) pollForResults(ctx context.Context, pdfID string, deadline time.Time) error {
+	defer logging.SpanContext(ctx, "PollForResults")()
 
 	pollURL := fmt.Sprintf("%s/%s", MathpixPdfApiURL, pdfID)
 
-	// TODO: This would run forever
-	for {
-		req, err := mp.newRequest("GET", pollURL, nil)
+	return mp.poll(ctx, pdfID, deadline, func() (string, bool, error) {
+		req, err := mp.newRequest(ctx, "GET", pollURL, nil)
 		if err != nil {
-			slog.Error("Failed to create GET request for mathpix document status", "error", err)
-			return err
+			logging.FromContext(ctx).Error("Failed to create GET request for mathpix document status", "error", err)
+			return "", false, err
 		}
 
 		bodyContents, err := mp.doRequest(req)
 		if err != nil {
-			slog.Error("Failed to send GET request for mathpix documetn status", "error", err)
-			return err
+			logging.FromContext(ctx).Error("Failed to send GET request for mathpix documetn status", "error", err)
+			return "", false, err
 		}
 
 		// Parse JSON
 		var pollResp PollResponse
 		err = json.Unmarshal(bodyContents, &pollResp)
 		if err != nil {
-			slog.Error("Failed to unmarshal mathpix document status", "body", string(bodyContents), "error", err)
-			return err
+			logging.FromContext(ctx).Error("Failed to unmarshal mathpix document status", "body", string(bodyContents), "error", err)
+			return "", false, err
 		}
 
-		slog.Debug("Mathpix", "pollStatus", pollResp.Status)
+		logging.FromContext(ctx).Debug("Mathpix", "pollStatus", pollResp.Status)
 
 		// If processing is done, return the markdown text
 		switch pollResp.Status {
 		case "completed":
-			return nil
+			// Mathpix bills by the page
+			usage.Add(ctx, usage.ProviderMathpix, usage.UnitPages, int64(pollResp.NumPages))
+			return pollResp.Status, true, nil
 		case "error":
-			return fmt.Errorf("mathpix PDF processing failed")
+			return pollResp.Status, false, fmt.Errorf("mathpix PDF processing failed")
+		}
+
+		return pollResp.Status, false, nil
+	})
+}
+
+// pollForConversions polls the Mathpix converter until every conversion format of the PDF has finished
+func (mp *MathpixDocumentProcessor) pollForConversions(ctx context.Context, pdfID string, deadline time.Time) error {
+	defer logging.SpanContext(ctx, "PollForConversions")()
+
+	pollURL := fmt.Sprintf("%s/%s", MathpixConverterApiURL, pdfID)
+
+	return mp.poll(ctx, pdfID, deadline, func() (string, bool, error) {
+		req, err := mp.newRequest(ctx, "GET", pollURL, nil)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to create GET request for mathpix conversion status", "error", err)
+			return "", false, err
+		}
+
+		bodyContents, err := mp.doRequest(req)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to send GET request for mathpix conversion status", "error", err)
+			return "", false, err
+		}
+
+		var convResp ConversionResponse
+		err = json.Unmarshal(bodyContents, &convResp)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to unmarshal mathpix conversion status", "body", string(bodyContents), "error", err)
+			return "", false, err
+		}
+
+		logging.FromContext(ctx).Debug("Mathpix", "conversionStatus", convResp.ConversionStatus)
+
+		done := len(convResp.ConversionStatus) != 0
+		for format, status := range convResp.ConversionStatus {
+			switch status.Status {
+			case "completed":
+			case "error":
+				return status.Status, false, fmt.Errorf("mathpix %s conversion failed: %s", format, status.ErrorInfo.Message)
+			default:
+				done = false
+			}
+		}
+
+		return convResp.Status, done, nil
+	})
+}
+
+// poll will check the status of the PDF every poll interval until check reports it is done.  The PDF fails once
+// the deadline passes.
+func (mp *MathpixDocumentProcessor) poll(ctx context.Context, pdfID string, deadline time.Time, check func() (string, bool, error)) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	status := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-timer.C:
+		}
+
+		if time.Now().After(deadline) {
+			return &PollTimeoutError{PdfID: pdfID, Status: status, Duration: mp.maxPollDuration}
+		}
+
+		var done bool
+		var err error
+		status, done, err = check()
+		if err != nil || done {
+			return err
 		}
 
 		// Wait before polling again
-		time.Sleep(MathpixPollInterval * time.Second)
+		timer.Reset(MathpixPollInterval * time.Second)
+	}
+}
+
+// downloadFormats will write the conversion formats of the PDF to the folder of the document for the bundle
+// processor, they are cached with the Markdown
+func (mp *MathpixDocumentProcessor) downloadFormats(ctx context.Context, pdfID, sourceName string, options RequestOptions) error {
+	defer logging.SpanContext(ctx, "MathpixDocumentProcessor.downloadFormats")()
+
+	folder := processor.DocumentFolder(mp.tempStoragePath, sourceName)
+	for format := range options.ConversionFormats {
+		formatURL := fmt.Sprintf("%s/%s.%s", MathpixPdfApiURL, pdfID, formatExtensions[format])
+
+		req, err := mp.newRequest(ctx, "GET", formatURL, nil)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to create GET request for the mathpix conversion format", "format", format, "error", err)
+			return err
+		}
+
+		bodyContents, err := mp.doRequest(req)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to download the mathpix conversion format", "format", format, "error", err)
+			return err
+		}
+
+		name := processor.ConversionName(format)
+		filePath := filepath.Join(folder, name)
+		err = os.MkdirAll(filepath.Dir(filePath), 0o755)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to create the folder of the conversion formats", "path", filePath, "error", err)
+			return err
+		}
+
+		err = processor.CopyFileFromReader(filePath, io.NopCloser(bytes.NewReader(bodyContents)))
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to save the mathpix conversion format", "format", format, "path", filePath, "error", err)
+			return err
+		}
+
+		processor.RecordFile(ctx, name)
+	}
+
+	return nil
+}
+
+// downloadImages will write the images the Markdown links to on the Mathpix CDN to the folder of the document for
+// the bundle processor, and replace the links with Obsidian embeds of the images.  The images are numbered in the
+// order they first appear under a placeholder name the bundle processor replaces with the name of the source
+// document, so the Markdown and its cached result do not depend on the name of the PDF.
+func (mp *MathpixDocumentProcessor) downloadImages(ctx context.Context, sourceName, markdown string) (string, error) {
+	defer logging.SpanContext(ctx, "MathpixDocumentProcessor.downloadImages")()
+
+	// the images of an earlier conversion of the document are replaced
+	folder := processor.ImagesFolder(mp.tempStoragePath, sourceName)
+	err := os.RemoveAll(folder)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to remove the previous images", "folder", folder, "error", err)
+		return "", err
 	}
+
+	matches := imageLinkPattern.FindAllStringSubmatch(markdown, -1)
+	if len(matches) == 0 {
+		return markdown, nil
+	}
+
+	err = os.MkdirAll(folder, 0o755)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to create the images folder", "folder", folder, "error", err)
+		return "", err
+	}
+
+	// the same image may be linked more than once
+	names := make(map[string]string)
+	for _, m := range matches {
+		imageURL := m[1]
+		if _, ok := names[imageURL]; ok {
+			continue
+		}
+
+		u, err := url.Parse(imageURL)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to parse the image link", "url", imageURL, "error", err)
+			return "", err
+		}
+
+		ext := path.Ext(u.Path)
+		if len(ext) == 0 {
+			ext = ".jpg"
+		}
+
+		name := processor.ImagePlaceholder(len(names)+1, ext)
+		err = mp.downloadImage(ctx, imageURL, filepath.Join(folder, name))
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to download the image", "url", imageURL, "error", err)
+			return "", err
+		}
+
+		processor.RecordFile(ctx, processor.ImageName(name))
+		names[imageURL] = name
+	}
+
+	logging.FromContext(ctx).Info("Downloaded the images of the note", "images", len(names))
+
+	return imageLinkPattern.ReplaceAllStringFunc(markdown, func(link string) string {
+		imageURL := imageLinkPattern.FindStringSubmatch(link)[1]
+		return fmt.Sprintf("![[%s]]", names[imageURL])
+	}), nil
+}
+
+// downloadImage will save the image from the Mathpix CDN, the CDN does not take the API credentials
+func (mp *MathpixDocumentProcessor) downloadImage(ctx context.Context, imageURL, filePath string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", imageURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := mp.cdnClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return &RequestError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return processor.CopyFileFromReader(filePath, resp.Body)
 }
 
-func (mp *MathpixDocumentProcessor) queryConversionResults(pdfID string) (string, error) {
-	slog.Debug(">>MathpixDocumentProcessor.queryConversionResults")
-	defer slog.Debug("<<MathpixDocumentProcessor.queryConversionResults")
+func (mp *MathpixDocumentProcessor) queryConversionResults(ctx context.Context, pdfID string) (string, error) {
+	defer logging.SpanContext(ctx, "MathpixDocumentProcessor.queryConversionResults")()
 	resultsURL := fmt.Sprintf("%s/%s.md", MathpixPdfApiURL, pdfID)
 
-	req, err := mp.newRequest("GET", resultsURL, nil)
+	req, err := mp.newRequest(ctx, "GET", resultsURL, nil)
 	if err != nil {
-		slog.Error("Failed to crate GET request for mathpix document status", "error", err)
+		logging.FromContext(ctx).Error("Failed to crate GET request for mathpix document status", "error", err)
 		return "", err
 	}
 
 	bodyContents, err := mp.doRequest(req)
 	if err != nil {
-		slog.Error("Failed to send GET request for mathpix documetn status", "error", err)
+		logging.FromContext(ctx).Error("Failed to send GET request for mathpix documetn status", "error", err)
 		return "", err
 	}
 
 	return string(bodyContents), nil
 }
 
-func (mp *MathpixDocumentProcessor) newRequest(method string, url string, body io.Reader) (*http.Request, error) {
-	req, err := http.NewRequest(method, url, body)
+func (mp *MathpixDocumentProcessor) newRequest(ctx context.Context, method string, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -227,15 +541,14 @@ func (mp *MathpixDocumentProcessor) newRequest(method string, url string, body i
 
 func (mp *MathpixDocumentProcessor) doRequest(req *http.Request) ([]byte, error) {
 	// Send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := mp.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode > 299 {
-		return nil, fmt.Errorf("request failed with status_code=%d and status=%s", resp.StatusCode, resp.Status)
+		return nil, &RequestError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
 	// Parse response
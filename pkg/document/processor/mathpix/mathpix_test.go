@@ -0,0 +1,256 @@
+package mathpix
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/document/processor"
+)
+
+func TestRequestOptions(t *testing.T) {
+	rmSpaces := false
+	mp := &MathpixDocumentProcessor{
+		bundles: []config.StorageBundle{
+			{SourceFolder: "plain"},
+			{
+				SourceFolder: "options",
+				Mathpix: config.MathpixOptions{
+					MathInlineDelimiters:  []string{"$", "$"},
+					MathDisplayDelimiters: []string{"$$", "$$"},
+					RmSpaces:              &rmSpaces,
+					PageRanges:            "2-4",
+					AlphabetsAllowed:      map[string]bool{"hi": false},
+				},
+			},
+			{
+				SourceFolder: "formats",
+				Mathpix:      config.MathpixOptions{ConversionFormats: []string{"docx", "tex.zip"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		folder string
+		want   RequestOptions
+	}{
+		{name: "unknown bundle", folder: "other"},
+		{name: "bundle without options", folder: "plain"},
+		{
+			name:   "bundle with options",
+			folder: "options",
+			want: RequestOptions{
+				MathInlineDelimiters:  []string{"$", "$"},
+				MathDisplayDelimiters: []string{"$$", "$$"},
+				RmSpaces:              &rmSpaces,
+				PageRanges:            "2-4",
+				AlphabetsAllowed:      map[string]bool{"hi": false},
+			},
+		},
+		{
+			name:   "conversion formats are requested by name",
+			folder: "formats",
+			want:   RequestOptions{ConversionFormats: map[string]bool{"docx": true, "tex.zip": true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mp.requestOptions(&document.Document{StorageFolderID: tt.folder})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("requestOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheOptions(t *testing.T) {
+	mp := &MathpixDocumentProcessor{
+		bundles: []config.StorageBundle{
+			{SourceFolder: "plain"},
+			{SourceFolder: "pages", Mathpix: config.MathpixOptions{PageRanges: "1"}},
+			{SourceFolder: "other pages", Mathpix: config.MathpixOptions{PageRanges: "2"}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		folder string
+		want   string
+	}{
+		{name: "default conversion", folder: "plain", want: ""},
+		{name: "options", folder: "pages", want: `{"page_ranges":"1"}`},
+		{name: "other options", folder: "other pages", want: `{"page_ranges":"2"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mp.CacheOptions(&document.Document{StorageFolderID: tt.folder})
+			if got != tt.want {
+				t.Errorf("CacheOptions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatExtensions(t *testing.T) {
+	tests := []struct {
+		format   string
+		wantPath string
+	}{
+		{format: "docx", wantPath: "notes/lecture.docx"},
+		{format: "html", wantPath: "notes/lecture.html"},
+		{format: "tex.zip", wantPath: "notes/lecture.tex.zip"},
+	}
+
+	if len(tests) != len(config.MathpixFormats) {
+		t.Fatalf("the tests cover %d formats, the configuration allows %v", len(tests), config.MathpixFormats)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			// every format the configuration allows has to be downloadable
+			if _, ok := formatExtensions[tt.format]; !ok {
+				t.Errorf("format %q has no extension to download it with", tt.format)
+			}
+
+			got := processor.FormatPath("notes", "lecture.pdf", tt.format)
+			if got != tt.wantPath {
+				t.Errorf("FormatPath(%q) = %q, want %q", tt.format, got, tt.wantPath)
+			}
+		})
+	}
+}
+
+// roundTripFunc serves the requests of the CDN client without the network
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// cdnClient answers every request with the path of the image, or the status when it is not OK
+func cdnClient(status int) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Status:     http.StatusText(status),
+			Body:       io.NopCloser(strings.NewReader(req.URL.Path)),
+			Request:    req,
+		}, nil
+	})}
+}
+
+func TestDownloadImages(t *testing.T) {
+	tests := []struct {
+		name       string
+		markdown   string
+		status     int
+		want       string
+		wantImages map[string]string
+		wantErr    bool
+	}{
+		{
+			name:     "no images",
+			markdown: "# Lecture\n\n$x^2$",
+			want:     "# Lecture\n\n$x^2$",
+		},
+		{
+			name:       "image is embedded",
+			markdown:   "Figure:\n![](https://cdn.mathpix.com/cropped/2024_01_01_abc.jpg?height=100&width=200&top_left_y=10)\nDone",
+			want:       "Figure:\n![[mathpix-image-1.jpg]]\nDone",
+			wantImages: map[string]string{"mathpix-image-1.jpg": "/cropped/2024_01_01_abc.jpg"},
+		},
+		{
+			name:     "images are numbered in the order they appear",
+			markdown: "![a](https://cdn.mathpix.com/cropped/b.png) and ![b](https://cdn.mathpix.com/cropped/a.jpg)",
+			want:     "![[mathpix-image-1.png]] and ![[mathpix-image-2.jpg]]",
+			wantImages: map[string]string{
+				"mathpix-image-1.png": "/cropped/b.png",
+				"mathpix-image-2.jpg": "/cropped/a.jpg",
+			},
+		},
+		{
+			name:       "image linked twice is downloaded once",
+			markdown:   "![](https://cdn.mathpix.com/cropped/a.jpg)\n![again](https://cdn.mathpix.com/cropped/a.jpg)",
+			want:       "![[mathpix-image-1.jpg]]\n![[mathpix-image-1.jpg]]",
+			wantImages: map[string]string{"mathpix-image-1.jpg": "/cropped/a.jpg"},
+		},
+		{
+			name:       "image without an extension",
+			markdown:   "![](https://cdn.mathpix.com/snip/images/abc)",
+			want:       "![[mathpix-image-1.jpg]]",
+			wantImages: map[string]string{"mathpix-image-1.jpg": "/snip/images/abc"},
+		},
+		{
+			name:     "images outside the CDN are kept",
+			markdown: "![](https://example.com/a.png) [link](https://cdn.mathpix.com/cropped/a.jpg)",
+			want:     "![](https://example.com/a.png) [link](https://cdn.mathpix.com/cropped/a.jpg)",
+		},
+		{
+			name:     "failed download",
+			markdown: "![](https://cdn.mathpix.com/cropped/a.jpg)",
+			status:   http.StatusNotFound,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := tt.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			mp := &MathpixDocumentProcessor{
+				cdnClient:       cdnClient(status),
+				tempStoragePath: t.TempDir(),
+			}
+
+			got, err := mp.downloadImages(context.Background(), "lecture.pdf", tt.markdown)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("downloadImages error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("downloadImages() = %q, want %q", got, tt.want)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			folder := processor.ImagesFolder(mp.tempStoragePath, "lecture.pdf")
+			entries, _ := os.ReadDir(folder)
+			names := make([]string, 0, len(entries))
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+
+			if len(names) != len(tt.wantImages) {
+				t.Errorf("images = %v, want %d images", names, len(tt.wantImages))
+			}
+
+			for name, contents := range tt.wantImages {
+				if !slices.Contains(names, name) {
+					t.Errorf("image %s was not written, images = %v", name, names)
+					continue
+				}
+
+				data, err := os.ReadFile(filepath.Join(folder, name))
+				if err != nil || string(data) != contents {
+					t.Errorf("image %s = %q, %v, want %q", name, data, err, contents)
+				}
+			}
+		})
+	}
+}
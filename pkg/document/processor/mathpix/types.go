@@ -1,13 +1,40 @@
 package mathpix
 
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+)
+
 // Mathpix API endpoint
 const (
-	MathpixPdfApiURL = "https://api.mathpix.com/v3/pdf"
+	MathpixPdfApiURL       = "https://api.mathpix.com/v3/pdf"
+	MathpixConverterApiURL = "https://api.mathpix.com/v3/converter"
 )
 
+// the images of the Markdown are cropped from the PDF and hosted on the Mathpix CDN, the requests to it are
+// recorded under their own provider since they do not count towards the API limits
+const cdnProvider = "mathpix_cdn"
+
+// imageLinkPattern matches the Markdown images that link to the Mathpix CDN
+var imageLinkPattern = regexp.MustCompile(`!\[[^\]]*\]\((https://cdn\.mathpix\.com/[^)\s]+)\)`)
+
+// formatExtensions are the extensions the conversion formats are downloaded with from the PDF API
+var formatExtensions = map[string]string{
+	"docx":    "docx",
+	"html":    "html",
+	"tex.zip": "tex",
+}
+
 // Polling interval (seconds)
 const MathpixPollInterval = 5
 
+// DefaultMaxPollDuration is how long the conversion of a PDF may take when it is not configured
+const DefaultMaxPollDuration = 10 * time.Minute
+
 type (
 	MathpixErrorInfo struct {
 		ID      string `json:"id,omitempty"`
@@ -24,13 +51,66 @@ type (
 	// PollResponse represents the response when polling for PDF processing results
 	PollResponse struct {
 		Status      string `json:"status"`
+		NumPages    int    `json:"num_pages,omitempty"`
 		PdfMarkdown string `json:"pdf_md,omitempty"`
 	}
 
+	// RequestOptions are the options_json of the upload, built from the Mathpix options of the bundle
+	RequestOptions struct {
+		MathInlineDelimiters  []string        `json:"math_inline_delimiters,omitempty"`
+		MathDisplayDelimiters []string        `json:"math_display_delimiters,omitempty"`
+		RmSpaces              *bool           `json:"rm_spaces,omitempty"`
+		EnableTablesFallback  *bool           `json:"enable_tables_fallback,omitempty"`
+		PageRanges            string          `json:"page_ranges,omitempty"`
+		AlphabetsAllowed      map[string]bool `json:"alphabets_allowed,omitempty"`
+		ConversionFormats     map[string]bool `json:"conversion_formats,omitempty"`
+	}
+
+	// ConversionResponse represents the status of the conversion formats of a PDF
+	ConversionResponse struct {
+		Status           string                      `json:"status"`
+		ConversionStatus map[string]ConversionStatus `json:"conversion_status"`
+	}
+
+	// ConversionStatus is the status of a single conversion format
+	ConversionStatus struct {
+		Status    string           `json:"status"`
+		ErrorInfo MathpixErrorInfo `json:"error_info,omitempty"`
+	}
+
+	// PollTimeoutError is returned when Mathpix does not finish converting the PDF within the maximum poll duration
+	PollTimeoutError struct {
+		PdfID    string
+		Status   string
+		Duration time.Duration
+	}
+
+	// RequestError is returned when the Mathpix API responds with a failed status
+	RequestError struct {
+		StatusCode int
+		Status     string
+	}
+
 	MathpixDocumentProcessor struct {
-		//
+		client          *http.Client
+		cdnClient       *http.Client
 		mathpixAppID    string
 		mathpixAppKey   string
 		tempStoragePath string
+		maxPollDuration time.Duration
+		bundles         []config.StorageBundle
 	}
 )
+
+func (e *PollTimeoutError) Error() string {
+	return fmt.Sprintf("mathpix did not finish converting the PDF within %s, pdf_id=%s and status=%s", e.Duration, e.PdfID, e.Status)
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("request failed with status_code=%d and status=%s", e.StatusCode, e.Status)
+}
+
+// HTTPStatusCode of the failed request
+func (e *RequestError) HTTPStatusCode() int {
+	return e.StatusCode
+}
@@ -1,13 +1,14 @@
 package obsidian
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log/slog"
 	"strings"
 
 	"github.com/KyleBrandon/scriptoria/internal/config"
 	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
 )
 
 // NewObsidianProcessor will return a processor that will add a link to the Markdown file to the original PDF attachment.
@@ -22,21 +23,19 @@ func (lp *ObsidianDocumentPostProcessor) GetName() string {
 }
 
 func (op *ObsidianDocumentPostProcessor) Initialize(tempStoragePath string, bundles []config.StorageBundle) error {
-	slog.Debug(">>ObsidianDocumentPostProcessor.Initialize")
-	defer slog.Debug("<<ObsidianDocumentPostProcessor.Initialize")
+	defer logging.Span("ObsidianDocumentPostProcessor.Initialize")()
 	op.tempStoragePath = tempStoragePath
 
 	return nil
 }
 
-func (op *ObsidianDocumentPostProcessor) Process(document *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
-	slog.Debug(">>Obsidian.Process")
-	defer slog.Debug("<<Obsidian.Process")
+func (op *ObsidianDocumentPostProcessor) Process(ctx context.Context, document *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
+	defer logging.SpanContext(ctx, "Obsidian.Process")()
 
 	sourceName := document.Name
 
 	// save the markdown note to the notes folder in Obsidian
-	markdown, err := op.saveMarkdownNote(sourceName, reader)
+	markdown, err := op.saveMarkdownNote(ctx, sourceName, reader)
 	if err != nil {
 		return nil, err
 	}
@@ -44,11 +43,11 @@ func (op *ObsidianDocumentPostProcessor) Process(document *document.Document, re
 	return io.NopCloser(strings.NewReader(markdown)), nil
 }
 
-func (op *ObsidianDocumentPostProcessor) saveMarkdownNote(sourceName string, reader io.ReadCloser) (string, error) {
+func (op *ObsidianDocumentPostProcessor) saveMarkdownNote(ctx context.Context, sourceName string, reader io.ReadCloser) (string, error) {
 	// TODO: we should copy the notes markdown file then append the link to it instead of reading it all into memory
 	markdownDocument, err := io.ReadAll(reader)
 	if err != nil {
-		slog.Error("Failed to read the output transform", "error", err)
+		logging.FromContext(ctx).Error("Failed to read the output transform", "error", err)
 		return "", err
 	}
 
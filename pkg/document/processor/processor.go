@@ -1,18 +1,33 @@
 package processor
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/KyleBrandon/scriptoria/internal/config"
 	"github.com/KyleBrandon/scriptoria/internal/database"
 	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/document/artifact"
+	"github.com/KyleBrandon/scriptoria/pkg/document/cache"
+	"github.com/KyleBrandon/scriptoria/pkg/events"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/KyleBrandon/scriptoria/pkg/metrics"
+	"github.com/KyleBrandon/scriptoria/pkg/tracing"
+	"github.com/KyleBrandon/scriptoria/pkg/usage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ProcessorConfig struct {
@@ -23,8 +38,19 @@ type ProcessorConfig struct {
 	AttachmentsFolder string
 	NotesFolder       string
 	Bundles           []config.StorageBundle
+	Artifacts         artifact.Store
+	Cache             *cache.Cache
+	Events            *events.Bus
+	Workers           int
+	Timeout           time.Duration
+	MaxPollDuration   time.Duration
+	Ledger            *usage.Ledger
 }
 
+// ErrShutdown is the cause the manager cancels the processors with when the server shuts down.  The documents in
+// flight were checkpointed to resume, so the stages they were in do not record a failure.
+var ErrShutdown = errors.New("the server is shutting down")
+
 // Processor is an interface to define the processing of a document.  Implementations
 //
 //	will create the Intialize method which defines the input/output channels for documents to
@@ -34,12 +60,81 @@ type Processor interface {
 	Initialize(tempStoragePath string, bundles []config.StorageBundle) error
 
 	// Process the document passed in the reader and return another reader with the new transformed document.
-	Process(document *document.Document, reader io.ReadCloser) (io.ReadCloser, error)
+	Process(ctx context.Context, document *document.Document, reader io.ReadCloser) (io.ReadCloser, error)
 
 	// Name of the Processor
 	GetName() string
 }
 
+// MeteredProcessor is implemented by processors that call an external provider with a budget.  The processor adds
+// what it used to the meter of the context with usage.Add.
+type MeteredProcessor interface {
+	// Provider returns the name of the provider the budget is kept for
+	Provider() string
+}
+
+// PollingProcessor is implemented by processors that wait on a job of an external provider.  The job fails once
+// it takes longer than the maximum poll duration.
+type PollingProcessor interface {
+	// SetMaxPollDuration sets how long the processor waits on the job
+	SetMaxPollDuration(d time.Duration)
+}
+
+// StageTimeoutError is returned when the processor of a stage takes longer than the timeout of the stage
+type StageTimeoutError struct {
+	Stage   string
+	Timeout time.Duration
+}
+
+func (e *StageTimeoutError) Error() string {
+	return fmt.Sprintf("stage %s did not finish within %s", e.Stage, e.Timeout)
+}
+
+// Unwrap lets the timeout be found with errors.Is(err, context.DeadlineExceeded)
+func (e *StageTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// CacheableProcessor is implemented by processors whose output only depends on their input and their options.  The
+// results of these processors are cached by the hash of their input.
+type CacheableProcessor interface {
+	// CacheOptions returns the options that change the output of the processor for the document
+	CacheOptions(document *document.Document) string
+}
+
+// stageFilesKey is the context key of the files a processor wrote for the bundle processor
+type stageFilesKey struct{}
+
+// stageFiles are the files a processor wrote by their path in the DocumentFolder
+type stageFiles struct {
+	mu    sync.Mutex
+	names []string
+}
+
+// RecordFile will cache a file the processor wrote to the DocumentFolder besides its output with the result of the
+// processor.  The name is the path of the file in the folder.  A cached result writes the file back to the folder
+// of the document it is used for, so the bundle processor finds it the same as when the processor ran.
+func RecordFile(ctx context.Context, name string) {
+	if files, ok := ctx.Value(stageFilesKey{}).(*stageFiles); ok {
+		files.mu.Lock()
+		defer files.mu.Unlock()
+
+		files.names = append(files.names, name)
+	}
+}
+
+// cachedResult is the output of a processor as it is cached, with the files it wrote kept in the artifact store
+type cachedResult struct {
+	Output []byte       `json:"output"`
+	Files  []cachedFile `json:"files,omitempty"`
+}
+
+// cachedFile is a file a processor wrote by its path in the DocumentFolder and the hash of its artifact
+type cachedFile struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+}
+
 type ProcessorContext struct {
 	ctx             context.Context
 	cancelCauseFunc context.CancelCauseFunc
@@ -47,6 +142,16 @@ type ProcessorContext struct {
 
 	tempStoragePath string
 	bundles         []config.StorageBundle
+	artifacts       artifact.Store
+	cache           *cache.Cache
+	events          *events.Bus
+	ledger          *usage.Ledger
+
+	// the number of documents the stage processes at the same time
+	workers int
+
+	// how long the processor may take with a document
+	timeout time.Duration
 
 	wg        *sync.WaitGroup
 	processor Processor
@@ -56,49 +161,82 @@ type ProcessorContext struct {
 
 type ProcessorStore interface {
 	UpdateDocumentProcessed(ctx context.Context, arg database.UpdateDocumentProcessedParams) (database.Document, error)
+	CreateDocumentEvent(ctx context.Context, arg database.CreateDocumentEventParams) (database.DocumentEvent, error)
+	CountDocumentStageAttempts(ctx context.Context, arg database.CountDocumentStageAttemptsParams) (int64, error)
+	CreateDocumentUsage(ctx context.Context, arg database.CreateDocumentUsageParams) error
 }
 
 func New(cfg ProcessorConfig, processor Processor) *ProcessorContext {
-	slog.Debug(">>ProcessorContext.New")
-	defer slog.Debug("<<ProcessorContext.New")
+	defer logging.Span("ProcessorContext.New")()
 	pc := &ProcessorContext{
 		ctx:             cfg.Ctx,
 		cancelCauseFunc: cfg.CancelCauseFunc,
 		store:           cfg.Store,
 		tempStoragePath: cfg.TempStorageFolder,
 		bundles:         cfg.Bundles,
+		artifacts:       cfg.Artifacts,
+		cache:           cfg.Cache,
+		events:          cfg.Events,
+		ledger:          cfg.Ledger,
+		workers:         max(cfg.Workers, 1),
+		timeout:         cfg.Timeout,
 		processor:       processor,
 		wg:              &sync.WaitGroup{},
 		outputCh:        make(chan *document.TransformContext),
 	}
 
+	if pp, ok := processor.(PollingProcessor); ok && cfg.MaxPollDuration > 0 {
+		pp.SetMaxPollDuration(cfg.MaxPollDuration)
+	}
+
 	return pc
 }
 
 func (pc *ProcessorContext) Initialize(inputCh chan *document.TransformContext) (chan *document.TransformContext, error) {
-	slog.Debug(">>ProcessorContext.Initialize")
-	defer slog.Debug("<<ProcessorContext.Initialize")
+	defer logging.Span("ProcessorContext.Initialize")()
 
 	err := pc.processor.Initialize(pc.tempStoragePath, pc.bundles)
 	if err != nil {
 		return nil, err
 	}
 
-	pc.wg.Add(1)
+	// each worker takes the next document once it is done with the last, when they are all busy the previous
+	// stage blocks until one is free
 	pc.inputCh = inputCh
-	go pc.process()
+	pc.wg.Add(pc.workers)
+	for range pc.workers {
+		go pc.process()
+	}
 
 	return pc.outputCh, nil
 }
 
+// ReadinessChecks returns the checks of the processor if it has any
+func (pc *ProcessorContext) ReadinessChecks() map[string]document.ReadinessCheck {
+	if rr, ok := pc.processor.(document.ReadinessReporter); ok {
+		return rr.ReadinessChecks()
+	}
+
+	return nil
+}
+
+// Name of the stage this context runs
+func (pc *ProcessorContext) Name() string {
+	return pc.processor.GetName()
+}
+
+// Input channel for the stage, sending a transform context here will start processing at this stage
+func (pc *ProcessorContext) Input() chan *document.TransformContext {
+	return pc.inputCh
+}
+
 func (pc *ProcessorContext) CancelAndWait() {
 	pc.cancelCauseFunc(nil)
 	pc.wg.Wait()
 }
 
 func (pc *ProcessorContext) process() {
-	slog.Debug(">>ProcessorContext.process")
-	defer slog.Debug("<<ProcessorContext.process")
+	defer logging.Span("ProcessorContext.process")()
 
 	defer pc.wg.Done()
 
@@ -109,30 +247,343 @@ func (pc *ProcessorContext) process() {
 			return
 
 		case t := <-pc.inputCh:
-			pc.wg.Add(1)
-			go pc.processWrapper(t)
+			pc.processWrapper(t)
 		}
 	}
 }
 
+// forward will send the document to the next stage unless the processor is canceled
+func (pc *ProcessorContext) forward(t *document.TransformContext) {
+	select {
+	case pc.outputCh <- t:
+	case <-pc.ctx.Done():
+		pc.logger(t).Debug("ProcessorContext.forward canceled")
+	}
+}
+
 func (pc *ProcessorContext) processWrapper(t *document.TransformContext) {
-	defer pc.wg.Done()
+	// a document canceled while it waited for the stage is not processed
+	if t.Err == nil && t.Ctx.Err() != nil {
+		t.Reader.Close()
+		t.Reader = nil
+		t.Err = t.Ctx.Err()
+	}
+
+	// a document that failed in an earlier stage is passed through to the end of the pipeline
+	if t.Err != nil {
+		pc.forward(t)
+		return
+	}
+
 	defer t.Reader.Close()
 
+	stage := pc.processor.GetName()
+	metrics.StageInFlight.WithLabelValues(stage).Inc()
+	defer metrics.StageInFlight.WithLabelValues(stage).Dec()
+
+	// the stage span is a child of the document span
+	ctx, span := tracing.Tracer().Start(t.Ctx, stage, trace.WithAttributes(
+		tracing.AttrDocumentID.String(t.DocumentID.String()),
+		tracing.AttrSourceName.String(t.SourceDocument.Name),
+	))
+
+	// the processor logs with the stage as well as the document
+	ctx = logging.WithStage(ctx, stage)
+
+	// a stage that has run before for this document is being retried
+	attempt := pc.nextAttempt(t)
+	startStatus := EventStatusStarted
+	if attempt > 1 {
+		startStatus = EventStatusRetried
+	}
+
+	pc.recordEvent(t, stageEvent{status: startStatus, attempt: attempt})
 	pc.updateDocumentProcessingStatus(t, "start processing")
 
-	reader, err := pc.processor.Process(t.SourceDocument, t.Reader)
+	// count the bytes the processor consumes from its input
+	input := newCountingReadCloser(t.Reader)
+	start := time.Now()
+
+	var a artifact.Artifact
+	var output io.ReadCloser
+	reader, err := pc.runProcessor(ctx, t, input)
+	if err == nil {
+		// persist the stage output and hand the stored copy to the next stage
+		a, output, err = pc.storeArtifact(ctx, reader)
+	}
+
+	// the checkpoint the manager wrote for the shutdown is kept rather than recording the failure
+	if err != nil && errors.Is(context.Cause(ctx), ErrShutdown) {
+		logging.FromContext(ctx).Info("Stopped by the shutdown", "error", err)
+		tracing.EndSpan(span, nil)
+
+		t.Reader = nil
+		t.Err = context.Cause(ctx)
+		pc.forward(t)
+		return
+	}
+
 	if err != nil {
-		pc.cancelCauseFunc(err)
+		pc.finishStage(t, stageEvent{
+			status:   EventStatusFailed,
+			err:      err,
+			duration: time.Since(start),
+			attempt:  attempt,
+			bytesIn:  input.count,
+		})
+		tracing.EndSpan(span, err)
 		pc.updateDocumentProcessingStatus(t, err.Error())
+
+		// let the manager know the document failed
+		t.Reader = nil
+		t.Err = fmt.Errorf("%s: %w", stage, err)
+		pc.forward(t)
 		return
 	}
 
+	tracing.EndSpan(span, nil)
+	pc.finishStage(t, stageEvent{
+		status:   EventStatusSucceeded,
+		duration: time.Since(start),
+		attempt:  attempt,
+		bytesIn:  input.count,
+		artifact: &a,
+	})
 	pc.updateDocumentProcessingStatus(t, "finished processing")
 
 	// continue to the next processor
-	t.Reader = reader
-	pc.outputCh <- t
+	t.Reader = output
+	pc.forward(t)
+}
+
+// finishStage will record the result of the stage in the document history and the metrics
+func (pc *ProcessorContext) finishStage(t *document.TransformContext, e stageEvent) {
+	stage := pc.processor.GetName()
+
+	metrics.StageDuration.WithLabelValues(stage, e.status).Observe(e.duration.Seconds())
+	if e.err != nil {
+		metrics.StageFailures.WithLabelValues(stage, metrics.ErrorClass(e.err)).Inc()
+	}
+
+	pc.recordEvent(t, e)
+}
+
+// runProcessor will process the document or use the cached result if the processor ran before on the same input.
+func (pc *ProcessorContext) runProcessor(ctx context.Context, t *document.TransformContext, input io.ReadCloser) (io.ReadCloser, error) {
+	cp, ok := pc.processor.(CacheableProcessor)
+	if !ok || pc.cache == nil {
+		return pc.meteredProcess(ctx, t, input)
+	}
+
+	// the key is the hash of the input rather than the source document, so the output of an earlier stage that
+	// changes with the name or the options of the document is not answered with a result cached for another
+	inputContents, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(inputContents)
+	key := cache.Key(hex.EncodeToString(sum[:]), pc.processor.GetName(), cp.CacheOptions(t.SourceDocument))
+	if contents, hit := pc.cache.Get(key); hit {
+		output, err := pc.restoreResult(t.SourceDocument, contents)
+		if err == nil {
+			logging.FromContext(ctx).Info("Using the cached result")
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cache.hit", true))
+			metrics.CacheRequests.WithLabelValues(pc.processor.GetName(), "hit").Inc()
+			return io.NopCloser(bytes.NewReader(output)), nil
+		}
+
+		// a result cached by an earlier version or whose files are gone is processed again
+		logging.FromContext(ctx).Warn("Failed to restore the cached result", "error", err)
+	}
+
+	metrics.CacheRequests.WithLabelValues(pc.processor.GetName(), "miss").Inc()
+
+	files := &stageFiles{}
+	reader, err := pc.meteredProcess(context.WithValue(ctx, stageFilesKey{}, files), t, io.NopCloser(bytes.NewReader(inputContents)))
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	contents, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// a failure to cache should not fail the document
+	err = pc.cacheResult(key, t.SourceDocument, contents, files.names)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to cache the result", "error", err)
+	}
+
+	return io.NopCloser(bytes.NewReader(contents)), nil
+}
+
+// cacheResult will cache the output of the processor with the files it wrote for the document
+func (pc *ProcessorContext) cacheResult(key string, doc *document.Document, output []byte, names []string) error {
+	if len(names) != 0 && pc.artifacts == nil {
+		return errors.New("the files of the result can not be cached without an artifact store")
+	}
+
+	result := cachedResult{Output: output, Files: make([]cachedFile, 0, len(names))}
+
+	folder := DocumentFolder(pc.tempStoragePath, doc.Name)
+	for _, name := range names {
+		file, err := os.Open(filepath.Join(folder, name))
+		if err != nil {
+			return err
+		}
+
+		a, err := pc.artifacts.Put(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+
+		result.Files = append(result.Files, cachedFile{Name: name, Hash: a.Hash})
+	}
+
+	contents, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	return pc.cache.Put(key, contents)
+}
+
+// restoreResult will write the files of the cached result to the folder of the document and return its output
+func (pc *ProcessorContext) restoreResult(doc *document.Document, contents []byte) ([]byte, error) {
+	var result cachedResult
+	err := json.Unmarshal(contents, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Files) != 0 && pc.artifacts == nil {
+		return nil, errors.New("the files of the result can not be restored without an artifact store")
+	}
+
+	folder := DocumentFolder(pc.tempStoragePath, doc.Name)
+	for _, f := range result.Files {
+		err = pc.restoreFile(f.Hash, filepath.Join(folder, f.Name))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result.Output, nil
+}
+
+// restoreFile will write the artifact to the path
+func (pc *ProcessorContext) restoreFile(hash, filePath string) error {
+	reader, err := pc.artifacts.Open(hash)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	err = os.MkdirAll(filepath.Dir(filePath), 0o755)
+	if err != nil {
+		return err
+	}
+
+	return CopyFileFromReader(filePath, reader)
+}
+
+// meteredProcess will process the document once the budget of the provider has room, and add what the processor
+// used to the ledger.  Usage is recorded for failed documents as well since the provider may still bill for them.
+func (pc *ProcessorContext) meteredProcess(ctx context.Context, t *document.TransformContext, input io.ReadCloser) (io.ReadCloser, error) {
+	mp, ok := pc.processor.(MeteredProcessor)
+	if !ok || pc.ledger == nil {
+		return pc.processWithTimeout(ctx, t, input)
+	}
+
+	// holding the worker pauses the stage, and the stages before it once their documents back up
+	err := pc.ledger.Wait(ctx, mp.Provider(), func(budgetErr *usage.BudgetError) {
+		logging.FromContext(ctx).Warn("Waiting for the budget to have room", "error", budgetErr)
+		pc.updateDocumentProcessingStatus(t, fmt.Sprintf("paused, %s", budgetErr))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, meter := usage.WithMeter(ctx)
+	reader, err := pc.processWithTimeout(ctx, t, input)
+	pc.recordUsage(ctx, t, meter)
+
+	return reader, err
+}
+
+// processWithTimeout will cancel the processor once it takes longer than the timeout of the stage.  The time the
+// document waited on the budget is not counted.
+func (pc *ProcessorContext) processWithTimeout(ctx context.Context, t *document.TransformContext, input io.ReadCloser) (io.ReadCloser, error) {
+	if pc.timeout <= 0 {
+		return pc.processor.Process(ctx, t.SourceDocument, input)
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, pc.timeout, &StageTimeoutError{Stage: pc.processor.GetName(), Timeout: pc.timeout})
+	defer cancel()
+
+	reader, err := pc.processor.Process(ctx, t.SourceDocument, input)
+
+	// report the timeout rather than the error of the request it interrupted
+	var timeoutErr *StageTimeoutError
+	if err != nil && errors.As(context.Cause(ctx), &timeoutErr) {
+		logging.FromContext(ctx).Error("The stage timed out", "timeout", pc.timeout, "error", err)
+		return nil, timeoutErr
+	}
+
+	return reader, err
+}
+
+// recordUsage will add what the stage used to the ledger and store it with the document for the usage report
+func (pc *ProcessorContext) recordUsage(ctx context.Context, t *document.TransformContext, meter *usage.Meter) {
+	amounts := meter.Amounts()
+	if len(amounts) == 0 {
+		return
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Info("Used the provider", "usage", amounts)
+
+	err := pc.ledger.Record(pc.ctx, meter)
+	if err != nil {
+		logger.Error("Failed to record the usage in the ledger", "usage", amounts, "error", err)
+	}
+
+	for _, a := range amounts {
+		err = pc.store.CreateDocumentUsage(pc.ctx, database.CreateDocumentUsageParams{
+			DocumentID: t.DocumentID,
+			Stage:      pc.processor.GetName(),
+			Provider:   a.Provider,
+			Unit:       a.Unit,
+			Amount:     a.Amount,
+		})
+		if err != nil {
+			logger.Error("Failed to store the usage of the document", "provider", a.Provider, "unit", a.Unit, "amount", a.Amount, "error", err)
+		}
+	}
+}
+
+// storeArtifact will save the processor output to the artifact store and return a reader for the stored copy.
+func (pc *ProcessorContext) storeArtifact(ctx context.Context, reader io.ReadCloser) (artifact.Artifact, io.ReadCloser, error) {
+	defer reader.Close()
+
+	a, err := pc.artifacts.Put(reader)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to store the stage artifact", "error", err)
+		return artifact.Artifact{}, nil, err
+	}
+
+	output, err := pc.artifacts.Open(a.Hash)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to open the stage artifact", "hash", a.Hash, "error", err)
+		return artifact.Artifact{}, nil, err
+	}
+
+	return a, output, nil
 }
 
 func (pc *ProcessorContext) updateDocumentProcessingStatus(tc *document.TransformContext, message string) {
@@ -147,10 +598,15 @@ func (pc *ProcessorContext) updateDocumentProcessingStatus(tc *document.Transfor
 
 	_, err := pc.store.UpdateDocumentProcessed(pc.ctx, args)
 	if err != nil {
-		slog.Error("Failed to update the document status in the database", "error", err)
+		pc.logger(tc).Error("Failed to update the document status in the database", "error", err)
 	}
 }
 
+// logger returns the logger of the document with the stage of this processor
+func (pc *ProcessorContext) logger(tc *document.TransformContext) *slog.Logger {
+	return logging.FromContext(tc.Ctx).With(logging.KeyStage, pc.processor.GetName())
+}
+
 func CopyFileFromReader(fullFilePath string, reader io.ReadCloser) error {
 	// create the local file to save the document to
 	file, err := os.Create(fullFilePath)
@@ -0,0 +1,391 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/document/artifact"
+	"github.com/KyleBrandon/scriptoria/pkg/document/cache"
+)
+
+// fakeProcessor returns its output after the delay, or the error of the context when it is done first
+type fakeProcessor struct {
+	name   string
+	delay  time.Duration
+	err    error
+	output string
+}
+
+func (p *fakeProcessor) Initialize(tempStoragePath string, bundles []config.StorageBundle) error {
+	return nil
+}
+
+func (p *fakeProcessor) Process(ctx context.Context, doc *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(p.delay):
+	}
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return io.NopCloser(strings.NewReader(p.output)), nil
+}
+
+func (p *fakeProcessor) GetName() string {
+	return p.name
+}
+
+// cachedProcessor counts the documents it processed and outputs the name of the document and its input
+type cachedProcessor struct {
+	name    string
+	options string
+	calls   int
+}
+
+func (p *cachedProcessor) Initialize(tempStoragePath string, bundles []config.StorageBundle) error {
+	return nil
+}
+
+func (p *cachedProcessor) Process(ctx context.Context, doc *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
+	p.calls++
+
+	input, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(strings.NewReader(doc.Name + ":" + string(input))), nil
+}
+
+func (p *cachedProcessor) GetName() string {
+	return p.name
+}
+
+func (p *cachedProcessor) CacheOptions(doc *document.Document) string {
+	return p.options
+}
+
+// newCache returns an empty cache in a temp folder
+func newCache(t *testing.T) *cache.Cache {
+	t.Helper()
+
+	c, err := cache.New(t.TempDir(), time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+// run will pass the input through the stage as the document with the name and return the output
+func run(t *testing.T, pc *ProcessorContext, name, input string) string {
+	t.Helper()
+
+	tc := &document.TransformContext{SourceDocument: &document.Document{Name: name}}
+	reader, err := pc.runProcessor(context.Background(), tc, io.NopCloser(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(output)
+}
+
+func TestRunProcessorCache(t *testing.T) {
+	type step struct {
+		name    string
+		input   string
+		options string
+	}
+
+	tests := []struct {
+		name      string
+		steps     []step
+		wantCalls int
+	}{
+		{
+			name:      "same input and options",
+			steps:     []step{{name: "a", input: "markdown"}, {name: "a", input: "markdown"}},
+			wantCalls: 1,
+		},
+		{
+			name:      "same input under another name",
+			steps:     []step{{name: "a", input: "markdown"}, {name: "b", input: "markdown"}},
+			wantCalls: 1,
+		},
+		{
+			name:      "input changed by the options of an earlier stage",
+			steps:     []step{{name: "a", input: "markdown"}, {name: "a", input: "markdown with page ranges"}},
+			wantCalls: 2,
+		},
+		{
+			name:      "options of the stage changed",
+			steps:     []step{{name: "a", input: "markdown", options: "model=a"}, {name: "a", input: "markdown", options: "model=b"}},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &cachedProcessor{name: "chatgpt"}
+			pc := &ProcessorContext{processor: p, cache: newCache(t)}
+
+			for _, s := range tt.steps {
+				p.options = s.options
+				run(t, pc, s.name, s.input)
+			}
+
+			if p.calls != tt.wantCalls {
+				t.Errorf("the processor ran %d times, want %d", p.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// fileProcessor writes a converted file to the folder of the document like the conversion formats of the Mathpix stage
+type fileProcessor struct {
+	tempStoragePath string
+	calls           int
+}
+
+func (p *fileProcessor) Initialize(tempStoragePath string, bundles []config.StorageBundle) error {
+	return nil
+}
+
+func (p *fileProcessor) Process(ctx context.Context, doc *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
+	p.calls++
+
+	input, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	name := ConversionName("docx")
+	filePath := filepath.Join(DocumentFolder(p.tempStoragePath, doc.Name), name)
+	err = os.MkdirAll(filepath.Dir(filePath), 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.WriteFile(filePath, []byte("docx:"+string(input)), 0o644)
+	if err != nil {
+		return nil, err
+	}
+	RecordFile(ctx, name)
+
+	return io.NopCloser(strings.NewReader("markdown:" + string(input))), nil
+}
+
+func (p *fileProcessor) GetName() string {
+	return "mathpix"
+}
+
+func (p *fileProcessor) CacheOptions(doc *document.Document) string {
+	return ""
+}
+
+func TestCacheRestoresFiles(t *testing.T) {
+	artifacts, err := artifact.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tempStoragePath := t.TempDir()
+	p := &fileProcessor{tempStoragePath: tempStoragePath}
+	pc := &ProcessorContext{processor: p, cache: newCache(t), artifacts: artifacts, tempStoragePath: tempStoragePath}
+
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{name: "first upload", source: "lecture.pdf"},
+		{name: "same name", source: "lecture.pdf"},
+		{name: "new name", source: "renamed.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the files of an earlier run are gone, such as after the temp folder was cleaned up
+			err := os.RemoveAll(DocumentFolder(tempStoragePath, tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			output := run(t, pc, tt.source, "pdf")
+			if output != "markdown:pdf" {
+				t.Errorf("output = %q, want %q", output, "markdown:pdf")
+			}
+
+			data, err := os.ReadFile(filepath.Join(DocumentFolder(tempStoragePath, tt.source), ConversionName("docx")))
+			if err != nil || string(data) != "docx:pdf" {
+				t.Errorf("converted file = %q, %v, want %q", data, err, "docx:pdf")
+			}
+
+			if p.calls != 1 {
+				t.Errorf("the processor ran %d times, want 1", p.calls)
+			}
+		})
+	}
+}
+
+// imageProcessor writes an image and embeds it under its placeholder name like the Mathpix stage
+type imageProcessor struct {
+	tempStoragePath string
+	calls           int
+}
+
+func (p *imageProcessor) Initialize(tempStoragePath string, bundles []config.StorageBundle) error {
+	return nil
+}
+
+func (p *imageProcessor) Process(ctx context.Context, doc *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
+	p.calls++
+
+	input, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	name := ImagePlaceholder(1, ".jpg")
+	folder := ImagesFolder(p.tempStoragePath, doc.Name)
+	err = os.MkdirAll(folder, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.WriteFile(filepath.Join(folder, name), []byte("image"), 0o644)
+	if err != nil {
+		return nil, err
+	}
+	RecordFile(ctx, ImageName(name))
+
+	return io.NopCloser(strings.NewReader(fmt.Sprintf("![[%s]]\n%s", name, input))), nil
+}
+
+func (p *imageProcessor) GetName() string {
+	return "mathpix"
+}
+
+func (p *imageProcessor) CacheOptions(doc *document.Document) string {
+	return ""
+}
+
+func TestCacheSamePDFUnderNewNames(t *testing.T) {
+	artifacts, err := artifact.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tempStoragePath := t.TempDir()
+	mathpix := &imageProcessor{tempStoragePath: tempStoragePath}
+	chatgpt := &cachedProcessor{name: "chatgpt"}
+	c := newCache(t)
+	mathpixStage := &ProcessorContext{processor: mathpix, cache: c, artifacts: artifacts, tempStoragePath: tempStoragePath}
+	chatgptStage := &ProcessorContext{processor: chatgpt, cache: c, artifacts: artifacts, tempStoragePath: tempStoragePath}
+
+	tests := []struct {
+		name      string
+		source    string
+		wantEmbed string
+	}{
+		{name: "first name", source: "lecture.pdf", wantEmbed: "![[lecture-1.jpg]]"},
+		{name: "same name", source: "lecture.pdf", wantEmbed: "![[lecture-1.jpg]]"},
+		{name: "new name", source: "renamed.pdf", wantEmbed: "![[renamed-1.jpg]]"},
+	}
+
+	// the same PDF is processed under each name in turn, the stages only run for the first upload
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			markdown := run(t, mathpixStage, tt.source, "pdf")
+			note, images := NameImages(run(t, chatgptStage, tt.source, markdown), tt.source)
+
+			if !strings.Contains(note, tt.wantEmbed) {
+				t.Errorf("the note %q does not embed %s", note, tt.wantEmbed)
+			}
+
+			placeholder := ImagePlaceholder(1, ".jpg")
+			if len(images) != 1 || "![["+images[placeholder]+"]]" != tt.wantEmbed {
+				t.Errorf("images = %v, want %s for %s", images, tt.wantEmbed, placeholder)
+			}
+
+			// the bundle processor copies the image from the folder of the document
+			data, err := os.ReadFile(filepath.Join(ImagesFolder(tempStoragePath, tt.source), placeholder))
+			if err != nil || string(data) != "image" {
+				t.Errorf("image = %q, %v, want %q", data, err, "image")
+			}
+
+			if mathpix.calls != 1 {
+				t.Errorf("the mathpix stage ran %d times, want 1", mathpix.calls)
+			}
+
+			if chatgpt.calls != 1 {
+				t.Errorf("the chatgpt stage ran %d times, want 1", chatgpt.calls)
+			}
+		})
+	}
+}
+
+func TestProcessWithTimeout(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name        string
+		processor   *fakeProcessor
+		timeout     time.Duration
+		wantErr     error
+		wantTimeout bool
+	}{
+		{name: "no timeout", processor: &fakeProcessor{name: "mathpix", delay: 20 * time.Millisecond}},
+		{name: "finished in time", processor: &fakeProcessor{name: "mathpix"}, timeout: time.Minute},
+		{name: "failed in time", processor: &fakeProcessor{name: "mathpix", err: errFailed}, timeout: time.Minute, wantErr: errFailed},
+		{name: "timed out", processor: &fakeProcessor{name: "chatgpt", delay: time.Minute}, timeout: 10 * time.Millisecond, wantErr: context.DeadlineExceeded, wantTimeout: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := &ProcessorContext{processor: tt.processor, timeout: tt.timeout}
+			tc := &document.TransformContext{SourceDocument: &document.Document{}}
+
+			reader, err := pc.processWithTimeout(context.Background(), tc, io.NopCloser(strings.NewReader("")))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("processWithTimeout error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && reader == nil {
+				t.Error("processWithTimeout returned no output")
+			}
+
+			var timeoutErr *StageTimeoutError
+			if errors.As(err, &timeoutErr) != tt.wantTimeout {
+				t.Fatalf("processWithTimeout error = %v, want a StageTimeoutError %v", err, tt.wantTimeout)
+			}
+
+			if tt.wantTimeout {
+				if timeoutErr.Stage != tt.processor.name || timeoutErr.Timeout != tt.timeout {
+					t.Errorf("StageTimeoutError = %+v, want stage %q and timeout %s", timeoutErr, tt.processor.name, tt.timeout)
+				}
+
+				if !strings.Contains(err.Error(), tt.processor.name) {
+					t.Errorf("the error %q does not name the stage %q", err, tt.processor.name)
+				}
+			}
+		})
+	}
+}
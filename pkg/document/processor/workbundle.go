@@ -1,20 +1,32 @@
 package processor
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/KyleBrandon/scriptoria/internal/config"
 	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
 )
 
 var ErrBundleNotFound = errors.New("could not find the bundle")
 
+// imagesFolderName is the folder in the DocumentFolder the images of the note are written to
+const imagesFolderName = "images"
+
+// imagePlaceholder is the name the images of a note are embedded under until the bundle processor names them
+// after the source document, so the note does not change with the name the PDF was uploaded under
+const imagePlaceholder = "mathpix-image"
+
+// imageEmbedPattern matches the embeds of the images under their placeholder name and captures the number and extension
+var imageEmbedPattern = regexp.MustCompile(`!\[\[` + imagePlaceholder + `-(\d+)(\.[A-Za-z0-9]+)\]\]`)
+
 type BundleProcessor struct {
 	tempStoragePath string
 	bundles         []config.StorageBundle
@@ -37,9 +49,8 @@ func (bp *BundleProcessor) Initialize(tempStoragePath string, bundles []config.S
 	return nil
 }
 
-func (bp *BundleProcessor) Process(document *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
-	slog.Debug(">>LocalDocumentProcessor.processDocument")
-	defer slog.Debug("<<LocalDocumentProcessor.processDocument")
+func (bp *BundleProcessor) Process(ctx context.Context, document *document.Document, reader io.ReadCloser) (io.ReadCloser, error) {
+	defer logging.SpanContext(ctx, "LocalDocumentProcessor.processDocument")()
 
 	// write the output to the notes.
 	filePath, err := bp.createNotesFilePath(document)
@@ -47,14 +58,35 @@ func (bp *BundleProcessor) Process(document *document.Document, reader io.ReadCl
 		return nil, err
 	}
 
-	err = CopyFileFromReader(filePath, reader)
+	markdown, err := io.ReadAll(reader)
 	if err != nil {
-		slog.Error("Failed to copy the processed document", "sourceName", document.Name, "error", err)
+		logging.FromContext(ctx).Error("Failed to read the processed document", "error", err)
+		return nil, err
+	}
+
+	// the images are embedded under their placeholder names until now
+	note, images := NameImages(string(markdown), document.Name)
+
+	err = CopyFileFromReader(filePath, io.NopCloser(strings.NewReader(note)))
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to copy the processed document", "error", err)
 		return nil, err
 	}
 
 	// write the original pdf to the attachments folderr in Obsidian
-	err = bp.copyAttachment(document)
+	err = bp.copyAttachment(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+
+	// write the images the note embeds next to the PDF
+	err = bp.copyImages(ctx, document, images)
+	if err != nil {
+		return nil, err
+	}
+
+	// write the other formats Mathpix converted the PDF to next to the note
+	err = bp.copyFormats(ctx, document)
 	if err != nil {
 		return nil, err
 	}
@@ -75,14 +107,77 @@ func (bp *BundleProcessor) createNotesFilePath(document *document.Document) (str
 		return "", err
 	}
 
+	return NotePath(bundle, sourceName), nil
+}
+
+// NotePath returns where the bundle processor writes the Markdown of the source document
+func NotePath(bundle config.StorageBundle, sourceName string) string {
 	name := strings.TrimSuffix(sourceName, filepath.Ext(sourceName))
 	name = fmt.Sprintf("%s.md", name)
-	filePath := filepath.Join(bundle.DestNotesFolder, name)
 
-	return filePath, nil
+	return filepath.Join(bundle.DestNotesFolder, name)
+}
+
+// FormatPath returns where a conversion format of the source document is written in the folder, such as the
+// docx of "notes.pdf" in "notes.docx"
+func FormatPath(folder, sourceName, format string) string {
+	name := strings.TrimSuffix(sourceName, filepath.Ext(sourceName))
+
+	return filepath.Join(folder, fmt.Sprintf("%s.%s", name, format))
+}
+
+// DocumentFolder returns the folder in the temp storage the stages write the files of the source document to
+// besides their output, such as the conversion formats
+func DocumentFolder(tempStoragePath, sourceName string) string {
+	name := strings.TrimSuffix(sourceName, filepath.Ext(sourceName))
+
+	return filepath.Join(tempStoragePath, fmt.Sprintf("%s.files", name))
+}
+
+// ConversionName returns the path in the DocumentFolder a conversion format of the document is written to
+func ConversionName(format string) string {
+	return filepath.Join("formats", fmt.Sprintf("converted.%s", format))
+}
+
+// ImagesFolder returns the folder in the DocumentFolder the images the note of the source document embeds are
+// written to
+func ImagesFolder(tempStoragePath, sourceName string) string {
+	return filepath.Join(DocumentFolder(tempStoragePath, sourceName), imagesFolderName)
+}
+
+// ImageName returns the path in the DocumentFolder an image of the note is written to
+func ImageName(name string) string {
+	return filepath.Join(imagesFolderName, name)
+}
+
+// ImagePlaceholder returns the name an image of the note is written and embedded under, numbered from one
+func ImagePlaceholder(number int, ext string) string {
+	return fmt.Sprintf("%s-%d%s", imagePlaceholder, number, ext)
 }
 
-func (bp *BundleProcessor) copyAttachment(document *document.Document) error {
+// NameImages will replace the placeholder names of the images the note embeds with names after the source
+// document, such as lecture-1.jpg for lecture.pdf.  It returns the note and the new names by placeholder name.
+func NameImages(note, sourceName string) (string, map[string]string) {
+	baseName := strings.TrimSuffix(sourceName, filepath.Ext(sourceName))
+
+	images := make(map[string]string)
+	note = imageEmbedPattern.ReplaceAllStringFunc(note, func(embed string) string {
+		m := imageEmbedPattern.FindStringSubmatch(embed)
+		name := fmt.Sprintf("%s-%s%s", baseName, m[1], m[2])
+		images[fmt.Sprintf("%s-%s%s", imagePlaceholder, m[1], m[2])] = name
+
+		return fmt.Sprintf("![[%s]]", name)
+	})
+
+	return note, images
+}
+
+// AttachmentPath returns where the bundle processor copies the original source document
+func AttachmentPath(bundle config.StorageBundle, sourceName string) string {
+	return filepath.Join(bundle.DestAttachmentsFolder, sourceName)
+}
+
+func (bp *BundleProcessor) copyAttachment(ctx context.Context, document *document.Document) error {
 	sourceName := document.Name
 	bundle, err := bp.getBundle(document)
 	if err != nil {
@@ -90,18 +185,64 @@ func (bp *BundleProcessor) copyAttachment(document *document.Document) error {
 	}
 
 	srcPath := filepath.Join(bp.tempStoragePath, sourceName)
-	destPath := filepath.Join(bundle.DestAttachmentsFolder, sourceName)
+	destPath := AttachmentPath(bundle, sourceName)
 
 	// Copy the original document to the attachements folder
 	err = copyFile(srcPath, destPath)
 	if err != nil {
-		slog.Error("Failed to copy the notes file", "error", err)
+		logging.FromContext(ctx).Error("Failed to copy the notes file", "error", err)
 		return err
 	}
 
 	return nil
 }
 
+// copyImages will copy the images the note embeds from the temp folder to the attachments folder under their names
+func (bp *BundleProcessor) copyImages(ctx context.Context, document *document.Document, images map[string]string) error {
+	bundle, err := bp.getBundle(document)
+	if err != nil {
+		return err
+	}
+
+	folder := ImagesFolder(bp.tempStoragePath, document.Name)
+	for placeholder, name := range images {
+		err = copyFile(filepath.Join(folder, placeholder), AttachmentPath(bundle, name))
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to copy the image", "image", name, "error", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyFormats will copy the conversion formats the bundle requested from the temp folder to the notes folder
+func (bp *BundleProcessor) copyFormats(ctx context.Context, document *document.Document) error {
+	bundle, err := bp.getBundle(document)
+	if err != nil {
+		return err
+	}
+
+	for _, format := range bundle.Mathpix.ConversionFormats {
+		srcPath := filepath.Join(DocumentFolder(bp.tempStoragePath, document.Name), ConversionName(format))
+		destPath := FormatPath(bundle.DestNotesFolder, document.Name, format)
+
+		// the format is only converted when the mathpix stage is in the pipeline
+		if _, err := os.Stat(srcPath); errors.Is(err, os.ErrNotExist) {
+			logging.FromContext(ctx).Warn("The conversion format was not found", "format", format, "path", srcPath)
+			continue
+		}
+
+		err = copyFile(srcPath, destPath)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to copy the conversion format", "format", format, "error", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (bp *BundleProcessor) getBundle(document *document.Document) (config.StorageBundle, error) {
 	for _, b := range bp.bundles {
 		if b.SourceFolder == document.StorageFolderID {
@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNameImages(t *testing.T) {
+	tests := []struct {
+		name       string
+		note       string
+		source     string
+		want       string
+		wantImages map[string]string
+	}{
+		{
+			name:       "no images",
+			note:       "# Lecture\n\n![[lecture.pdf]]",
+			source:     "lecture.pdf",
+			want:       "# Lecture\n\n![[lecture.pdf]]",
+			wantImages: map[string]string{},
+		},
+		{
+			name:       "images are named after the source document",
+			note:       "![[mathpix-image-1.jpg]] and ![[mathpix-image-2.png]]",
+			source:     "lecture.pdf",
+			want:       "![[lecture-1.jpg]] and ![[lecture-2.png]]",
+			wantImages: map[string]string{"mathpix-image-1.jpg": "lecture-1.jpg", "mathpix-image-2.png": "lecture-2.png"},
+		},
+		{
+			name:       "image embedded twice",
+			note:       "![[mathpix-image-1.jpg]]\n![[mathpix-image-1.jpg]]",
+			source:     "week 2.notes.pdf",
+			want:       "![[week 2.notes-1.jpg]]\n![[week 2.notes-1.jpg]]",
+			wantImages: map[string]string{"mathpix-image-1.jpg": "week 2.notes-1.jpg"},
+		},
+		{
+			name:       "other embeds are kept",
+			note:       "![[image-1.jpg]] ![[mathpix-image-x.jpg]]",
+			source:     "lecture.pdf",
+			want:       "![[image-1.jpg]] ![[mathpix-image-x.jpg]]",
+			wantImages: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, images := NameImages(tt.note, tt.source)
+			if got != tt.want {
+				t.Errorf("NameImages() = %q, want %q", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(images, tt.wantImages) {
+				t.Errorf("NameImages() images = %v, want %v", images, tt.wantImages)
+			}
+		})
+	}
+}
@@ -1,29 +1,45 @@
 package storage
 
 import (
-	"errors"
-	"log/slog"
+	"fmt"
 	"net/http"
+	"sort"
 
 	"github.com/KyleBrandon/scriptoria/internal/database"
 	"github.com/KyleBrandon/scriptoria/pkg/document"
 	"github.com/KyleBrandon/scriptoria/pkg/document/storage/gdrive"
 	"github.com/KyleBrandon/scriptoria/pkg/document/storage/local"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
 )
 
+// storageBuilders creates each storage that can be used as the source_store in the config file
+var storageBuilders = map[string]func(queries *database.Queries, mux *http.ServeMux) document.Storage{
+	"Google Drive": func(queries *database.Queries, mux *http.ServeMux) document.Storage {
+		return gdrive.New(queries, mux)
+	},
+	"Local": func(queries *database.Queries, mux *http.ServeMux) document.Storage {
+		return local.New(queries)
+	},
+}
+
 func BuildDocumentStorage(storeName string, queries *database.Queries, mux *http.ServeMux) (document.Storage, error) {
-	slog.Debug(">>buildDocumentStorage")
-	defer slog.Debug("<<buildDocumentStorage")
-
-	var storage document.Storage
-	switch storeName {
-	case "Google Drive":
-		storage = gdrive.New(queries, mux)
-	case "Local":
-		storage = local.New(queries)
-	default:
-		return nil, errors.New("invalid storage type")
+	defer logging.Span("buildDocumentStorage")()
+
+	build, ok := storageBuilders[storeName]
+	if !ok {
+		return nil, fmt.Errorf("invalid storage type: %s", storeName)
+	}
+
+	return build(queries, mux), nil
+}
+
+// Names of the storages that can be built
+func Names() []string {
+	names := make([]string, 0, len(storageBuilders))
+	for name := range storageBuilders {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	return storage, nil
+	return names
 }
@@ -2,6 +2,9 @@ package gdrive
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -16,7 +19,10 @@ import (
 	"github.com/KyleBrandon/scriptoria/internal/config"
 	"github.com/KyleBrandon/scriptoria/internal/database"
 	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/KyleBrandon/scriptoria/pkg/metrics"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/drive/v3"
@@ -25,8 +31,7 @@ import (
 
 // Create a new Google Drive storage context
 func New(store GoogleDriveStore, mux *http.ServeMux) *GDriveStorageContext {
-	slog.Debug(">>GDriveStorageContext.New")
-	defer slog.Debug("<<GDriveStorageContext.New")
+	defer logging.Span("GDriveStorageContext.New")()
 
 	drive := &GDriveStorageContext{}
 
@@ -39,8 +44,7 @@ func New(store GoogleDriveStore, mux *http.ServeMux) *GDriveStorageContext {
 
 // Initialize the Google Drive storage watcher
 func (gd *GDriveStorageContext) Initialize(ctx context.Context, bundles []config.StorageBundle) error {
-	slog.Debug(">>GoogleDrive Initialize")
-	defer slog.Debug("<<GoogleDrive Initialize")
+	defer logging.SpanContext(ctx, "GoogleDrive Initialize")()
 
 	gd.bundles = bundles
 	gd.documents = make(chan *document.Document, 10)
@@ -91,16 +95,24 @@ func (gd *GDriveStorageContext) StartWatching() (chan *document.Document, error)
 	return gd.documents, nil
 }
 
-// QueryFiles from the watch folder and send them on the channel
-// TODO: send files all at once instead of one at a time
+// QueryFiles from the watch folders and send them on the channel
 func (gd *GDriveStorageContext) QueryFiles() {
-	slog.Debug(">>GoogleDrive.checkForNewOrModifiedFiles")
-	defer slog.Debug("<<GoogleDrive.checkForNewOrModifiedFiles")
-
 	defer gd.wg.Done()
 
+	gd.queryFolders(gd.watchedFolders())
+}
+
+// queryFolders will send the files in the folders on the channel
+// TODO: send files all at once instead of one at a time
+func (gd *GDriveStorageContext) queryFolders(folders []string) {
+	defer logging.Span("GoogleDrive.checkForNewOrModifiedFiles")()
+
+	if len(folders) == 0 {
+		return
+	}
+
 	// build the query string to find the new fines in Google Drive
-	query := gd.buildFileSearchQuery()
+	query := buildFileSearchQuery(folders)
 
 	fileList, err := gd.driveService.Files.List().Q(query).Fields("files(id, name, parents, createdTime, modifiedTime)").Do()
 	if err != nil {
@@ -135,7 +147,11 @@ func (gd *GDriveStorageContext) QueryFiles() {
 			ModifiedTime:      modifiedTime,
 		}
 
-		gd.documents <- &document
+		select {
+		case gd.documents <- &document:
+		case <-gd.ctx.Done():
+			return
+		}
 	}
 }
 
@@ -146,11 +162,11 @@ func (gd *GDriveStorageContext) Write(srcDoc *document.Document, reader io.ReadC
 }
 
 // Get a io.Reader for the document
-func (gd *GDriveStorageContext) GetReader(document *document.Document) (io.ReadCloser, error) {
+func (gd *GDriveStorageContext) GetReader(ctx context.Context, document *document.Document) (io.ReadCloser, error) {
 	// Get the file data
-	resp, err := gd.driveService.Files.Get(document.StorageDocumentID).Download()
+	resp, err := gd.driveService.Files.Get(document.StorageDocumentID).Context(ctx).Download()
 	if err != nil {
-		slog.Error("Unable to get the file reader", "error", err)
+		logging.FromContext(ctx).Error("Unable to get the file reader", "error", err)
 		return nil, err
 
 	}
@@ -158,19 +174,21 @@ func (gd *GDriveStorageContext) GetReader(document *document.Document) (io.ReadC
 	return resp.Body, nil
 }
 
-func (gd *GDriveStorageContext) Archive(document *document.Document) error {
+func (gd *GDriveStorageContext) Archive(ctx context.Context, document *document.Document) error {
 	// move the document to the archive folder
-	file, err := gd.driveService.Files.Get(document.StorageDocumentID).Fields("parents").Do()
+	file, err := gd.driveService.Files.Get(document.StorageDocumentID).Fields("parents").Context(ctx).Do()
 	if err != nil {
 		return err
 	}
 
 	archiveFolderID := ""
+	gd.channelWatchMu.RLock()
 	for _, b := range gd.bundles {
 		if b.SourceFolder == document.StorageFolderID {
 			archiveFolderID = b.ArchiveFolder
 		}
 	}
+	gd.channelWatchMu.RUnlock()
 
 	if len(archiveFolderID) == 0 {
 		return fmt.Errorf("failed to find an archive folder for document: %s in folder: %s", document.Name, document.StorageFolderID)
@@ -181,6 +199,7 @@ func (gd *GDriveStorageContext) Archive(document *document.Document) error {
 		AddParents(archiveFolderID).
 		RemoveParents(previousParents).
 		Fields("id, parents").
+		Context(ctx).
 		Do()
 	if err != nil {
 		return err
@@ -189,6 +208,48 @@ func (gd *GDriveStorageContext) Archive(document *document.Document) error {
 	return nil
 }
 
+// ReadinessChecks will report if Google Drive can be reached with the service account and if the watch channels are current
+func (gd *GDriveStorageContext) ReadinessChecks() map[string]document.ReadinessCheck {
+	return map[string]document.ReadinessCheck{
+		"google_drive":                gd.checkDriveService,
+		"google_drive_watch_channels": gd.checkWatchChannels,
+	}
+}
+
+func (gd *GDriveStorageContext) checkDriveService(ctx context.Context) error {
+	if gd.driveService == nil {
+		return errors.New("the Google Drive service is not initialized")
+	}
+
+	// any authenticated call will do, the user is the smallest response
+	_, err := gd.driveService.About.Get().Fields("user").Context(ctx).Do()
+
+	return err
+}
+
+func (gd *GDriveStorageContext) checkWatchChannels(ctx context.Context) error {
+	gd.channelWatchMu.RLock()
+	defer gd.channelWatchMu.RUnlock()
+
+	if len(gd.channelWatchMap) == 0 {
+		return errors.New("no watch channels have been created")
+	}
+
+	now := time.Now().UnixMilli()
+	expired := make([]string, 0)
+	for _, wc := range gd.channelWatchMap {
+		if wc.ExpiresAt <= now {
+			expired = append(expired, wc.ResourceID)
+		}
+	}
+
+	if len(expired) != 0 {
+		return fmt.Errorf("watch channels expired for folders: %s", strings.Join(expired, ", "))
+	}
+
+	return nil
+}
+
 // Initialize environment variables
 func (gd *GDriveStorageContext) readConfigurationSettings() error {
 	gd.credentialsFile = os.Getenv("GOOGLE_SERVICE_KEY_FILE")
@@ -220,8 +281,9 @@ func (gd *GDriveStorageContext) getDriveService() error {
 		return err
 	}
 
-	// Create an HTTP client using TokenSource
-	client := oauth2.NewClient(context.Background(), creds.TokenSource)
+	// Create an HTTP client using TokenSource, the base client records the Drive API requests
+	baseClient := &http.Client{Transport: otelhttp.NewTransport(metrics.InstrumentTransport("google_drive", nil))}
+	client := oauth2.NewClient(context.WithValue(context.Background(), oauth2.HTTPClient, baseClient), creds.TokenSource)
 
 	// Create Google Drive service
 	service, err := drive.NewService(context.Background(), option.WithHTTPClient(client))
@@ -237,8 +299,7 @@ func (gd *GDriveStorageContext) getDriveService() error {
 
 // Subscribe to folder changes
 func (gd *GDriveStorageContext) registerWebhook() error {
-	slog.Debug(">>registerWebhook")
-	defer slog.Debug("<<registerWebhook")
+	defer logging.Span("registerWebhook")()
 
 	// Register the webhook call back
 	u, err := url.Parse(gd.webhookURL)
@@ -247,28 +308,47 @@ func (gd *GDriveStorageContext) registerWebhook() error {
 		return err
 	}
 
-	gd.mux.HandleFunc(fmt.Sprintf("POST %s", u.Path), gd.webhookHandler)
+	gd.webhookPattern = fmt.Sprintf("POST %s", u.Path)
+	gd.mux.HandleFunc(gd.webhookPattern, gd.webhookHandler)
 
 	return nil
 }
 
+// PublicRoutes returns the webhook route, Google Drive can not send an API token so the webhook checks the
+// token of the watch channel instead
+func (gd *GDriveStorageContext) PublicRoutes() []string {
+	if len(gd.webhookPattern) == 0 {
+		return nil
+	}
+
+	return []string{gd.webhookPattern}
+}
+
 // Webhook handler for receiving Google Drive notifications
 func (gd *GDriveStorageContext) webhookHandler(w http.ResponseWriter, r *http.Request) {
-	slog.Debug(">>GoogleDrive.webhookHandler")
-	defer slog.Debug("<<GoogleDrive.webhookHandler")
+	defer logging.SpanContext(r.Context(), "GoogleDrive.webhookHandler")()
 
 	// Extract headers sent by Google Drive
 	resourceState := r.Header.Get("X-Goog-Resource-State")
 	channelID := r.Header.Get("X-Goog-Channel-ID")
 	resourceID := r.Header.Get("X-Goog-Resource-ID")
+	channelToken := r.Header.Get("X-Goog-Channel-Token")
 
 	// did we receive a notification for an old channel?
-	if !gd.watchChannelExists(channelID) {
+	wc, ok := gd.findWatchChannel(channelID)
+	if !ok {
 		slog.Error("watch channel does not exist", "channelID", channelID)
 		gd.stopChannelWatch(channelID, resourceID)
 		return
 	}
 
+	// the token was set when the channel was created, a notification without it did not come from Google Drive
+	if len(wc.Token) == 0 || subtle.ConstantTimeCompare([]byte(wc.Token), []byte(channelToken)) != 1 {
+		slog.Warn("Webhook received an invalid channel token", "channelID", channelID, "remoteAddr", r.RemoteAddr)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
 	// If we receive a 'sync' notification, ignore it for now.
 	// We could use this for initialzing the state of the vault?
 	if resourceState != "add" {
@@ -283,14 +363,17 @@ func (gd *GDriveStorageContext) webhookHandler(w http.ResponseWriter, r *http.Re
 	w.WriteHeader(http.StatusOK)
 }
 
-func (gd *GDriveStorageContext) watchChannelExists(channelID string) bool {
+func (gd *GDriveStorageContext) findWatchChannel(channelID string) (database.GoogleDriveWatch, bool) {
+	gd.channelWatchMu.RLock()
+	defer gd.channelWatchMu.RUnlock()
+
 	for _, v := range gd.channelWatchMap {
 		if v.ChannelID == channelID {
-			return true
+			return v, true
 		}
 	}
 
-	return false
+	return database.GoogleDriveWatch{}, false
 }
 
 func (gd *GDriveStorageContext) scheduleChannelRenewal() {
@@ -302,9 +385,69 @@ func (gd *GDriveStorageContext) scheduleChannelRenewal() {
 	})
 }
 
+// UpdateBundles will stop the watch channels of the folders that are no longer in a bundle and create channels
+// for the new folders.  The new folders are queried so the files already in them are processed.
+func (gd *GDriveStorageContext) UpdateBundles(bundles []config.StorageBundle) error {
+	defer logging.Span("GDrive.UpdateBundles")()
+
+	folders := make(map[string]bool)
+	for _, b := range bundles {
+		folders[b.SourceFolder] = true
+	}
+
+	gd.channelWatchMu.Lock()
+	removed := make([]database.GoogleDriveWatch, 0)
+	for folder, wc := range gd.channelWatchMap {
+		if !folders[folder] {
+			removed = append(removed, wc)
+		}
+	}
+
+	added := make([]string, 0)
+	for _, b := range bundles {
+		if _, ok := gd.channelWatchMap[b.SourceFolder]; !ok {
+			added = append(added, b.SourceFolder)
+		}
+	}
+	gd.bundles = bundles
+	gd.channelWatchMu.Unlock()
+
+	for _, wc := range removed {
+		slog.Info("Stop watching folder", "resourceID", wc.ResourceID, "channelID", wc.ChannelID)
+		gd.stopChannelWatch(wc.ChannelID, wc.ChannelResourceID)
+		metrics.WatchChannelExpiry.DeleteLabelValues(wc.ResourceID)
+
+		err := gd.store.DeleteGoogleDriveWatchByFolderID(gd.ctx, wc.ResourceID)
+		if err != nil {
+			slog.Error("Failed to delete the watch channel of the folder", "resourceID", wc.ResourceID, "error", err)
+		}
+	}
+
+	err := gd.createWatchChannels()
+	if err != nil {
+		return err
+	}
+
+	gd.wg.Add(1)
+	go func() {
+		defer gd.wg.Done()
+		gd.queryFolders(added)
+	}()
+
+	return nil
+}
+
+// RenewWatchChannels will create a watch channel for each bundle folder that does not have one and
+// replace the channels that expired or point at a different webhook URL.
+func (gd *GDriveStorageContext) RenewWatchChannels() error {
+	return gd.createWatchChannels()
+}
+
 func (gd *GDriveStorageContext) createWatchChannels() error {
-	slog.Debug(">>GDrive.createWatchChannels")
-	defer slog.Debug("<<GDrive.createWatchChannels")
+	defer logging.Span("GDrive.createWatchChannels")()
+
+	gd.channelWatchMu.Lock()
+	defer gd.channelWatchMu.Unlock()
 
 	// create a list of folder (resource) ids to query and a map of folders to watch channel information
 	resourceIds := make([]string, 0)
@@ -314,12 +457,19 @@ func (gd *GDriveStorageContext) createWatchChannels() error {
 		// build resource list of folders to query from the database
 		resourceIds = append(resourceIds, b.SourceFolder)
 
+		token, err := newChannelToken()
+		if err != nil {
+			slog.Error("Failed to create the watch channel token", "error", err)
+			return err
+		}
+
 		// build a map of expected watch entries with initial values
 		gd.channelWatchMap[b.SourceFolder] = database.GoogleDriveWatch{
 			ResourceID: b.SourceFolder,
 			ChannelID:  uuid.New().String(),
 			ExpiresAt:  time.Now().Add(24 * time.Hour).UnixMilli(),
 			WebhookUrl: gd.webhookURL,
+			Token:      token,
 		}
 	}
 
@@ -351,15 +501,23 @@ func (gd *GDriveStorageContext) createWatchChannel(wc database.GoogleDriveWatch)
 	if wc.ID != uuid.Nil {
 		// consider it expired if it's been alive over 23 hours
 		expired := time.Now().UnixMilli() > wc.ExpiresAt-60000
-		if wc.WebhookUrl == gd.webhookURL && !expired {
+		if wc.WebhookUrl == gd.webhookURL && len(wc.Token) != 0 && !expired {
 			// we don't need to create a new channel as it current exists for the correct web hook and it's not expired
 			slog.Debug("current channel is valid", "resourceID", wc.ResourceID, "channelID", wc.ChannelID)
+			metrics.WatchChannelExpiry.WithLabelValues(wc.ResourceID).Set(float64(wc.ExpiresAt) / 1000)
 			return nil
 		} else {
-			// the channel either expired or has a stale webhook URL
+			// the channel either expired, has a stale webhook URL or was created before channels had a token
+			token, err := newChannelToken()
+			if err != nil {
+				slog.Error("Failed to create the watch channel token", "resourceID", wc.ResourceID, "error", err)
+				return err
+			}
+
 			wc.ChannelID = uuid.New().String()
 			wc.ExpiresAt = time.Now().Add(24 * time.Hour).UnixMilli()
 			wc.WebhookUrl = gd.webhookURL
+			wc.Token = token
 		}
 	}
 
@@ -369,14 +527,16 @@ func (gd *GDriveStorageContext) createWatchChannel(wc database.GoogleDriveWatch)
 		Type:       "web_hook",
 		Address:    wc.WebhookUrl,
 		Expiration: wc.ExpiresAt,
+		Token:      wc.Token,
 	}
 
-	// Watch for changes in the folder
-	_, err := gd.driveService.Files.Watch(wc.ResourceID, req).Do()
+	// Watch for changes in the folder, the channel is stopped by the resource ID Google Drive gives it
+	ch, err := gd.driveService.Files.Watch(wc.ResourceID, req).Do()
 	if err != nil {
 		slog.Error("Failed to watch folder", "resourceID", wc.ResourceID, "error", err)
 		return nil
 	}
+	wc.ChannelResourceID = ch.ResourceId
 
 	dbc, err := gd.createOrUpdateChannel(wc)
 	if err != nil {
@@ -386,6 +546,7 @@ func (gd *GDriveStorageContext) createWatchChannel(wc database.GoogleDriveWatch)
 
 	// save the newly created/updated watch channel in our map
 	gd.channelWatchMap[wc.ResourceID] = dbc
+	metrics.WatchChannelExpiry.WithLabelValues(dbc.ResourceID).Set(float64(dbc.ExpiresAt) / 1000)
 
 	return nil
 }
@@ -394,37 +555,64 @@ func (gd *GDriveStorageContext) createOrUpdateChannel(wc database.GoogleDriveWat
 	// if we don't have a database id then we need to create the channel
 	if wc.ID == uuid.Nil {
 		args := database.CreateGoogleDriveWatchParams{
-			ChannelID:  wc.ChannelID,
-			ResourceID: wc.ResourceID,
-			ExpiresAt:  wc.ExpiresAt,
-			WebhookUrl: wc.WebhookUrl,
+			ChannelID:         wc.ChannelID,
+			ResourceID:        wc.ResourceID,
+			ExpiresAt:         wc.ExpiresAt,
+			WebhookUrl:        wc.WebhookUrl,
+			Token:             wc.Token,
+			ChannelResourceID: wc.ChannelResourceID,
 		}
 
 		return gd.store.CreateGoogleDriveWatch(gd.ctx, args)
 	} else {
 		// we're updating an existing channel
 		args := database.UpdateGoogleDriveWatchParams{
-			ID:         wc.ID,
-			ChannelID:  wc.ChannelID,
-			ResourceID: wc.ResourceID,
-			ExpiresAt:  wc.ExpiresAt,
-			WebhookUrl: wc.WebhookUrl,
+			ID:                wc.ID,
+			ChannelID:         wc.ChannelID,
+			ResourceID:        wc.ResourceID,
+			ExpiresAt:         wc.ExpiresAt,
+			WebhookUrl:        wc.WebhookUrl,
+			Token:             wc.Token,
+			ChannelResourceID: wc.ChannelResourceID,
 		}
 
 		return gd.store.UpdateGoogleDriveWatch(gd.ctx, args)
 	}
 }
 
-func (gd *GDriveStorageContext) buildFileSearchQuery() string {
+// newChannelToken returns the random token Google Drive sends back with every notification of a channel
+func newChannelToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// watchedFolders returns the folders that have a watch channel
+func (gd *GDriveStorageContext) watchedFolders() []string {
+	gd.channelWatchMu.RLock()
+	defer gd.channelWatchMu.RUnlock()
+
+	folders := make([]string, 0, len(gd.channelWatchMap))
+	for folder := range gd.channelWatchMap {
+		folders = append(folders, folder)
+	}
+
+	return folders
+}
+
+// buildFileSearchQuery returns the query for the PDF files in any of the folders
+func buildFileSearchQuery(folders []string) string {
 	query := "mimeType='application/pdf' and ("
 
-	index := 0
-	for k := range gd.channelWatchMap {
-		if index != 0 {
+	for i, folder := range folders {
+		if i != 0 {
 			query = query + " or "
 		}
-		query = fmt.Sprintf("%s'%s' in parents", query, k)
-		index++
+		query = fmt.Sprintf("%s'%s' in parents", query, folder)
 	}
 
 	query = query + ")"
@@ -432,12 +620,22 @@ func (gd *GDriveStorageContext) buildFileSearchQuery() string {
 	return query
 }
 
+// stopChannelWatch will stop the notifications of the channel.  The resource ID is the one Google Drive returned
+// when the channel was created, not the ID of the folder.
 func (gd *GDriveStorageContext) stopChannelWatch(channelID, resourceID string) {
+	if len(resourceID) == 0 {
+		slog.Warn("The resource ID of the watch channel is not known, it stops when it expires", "channelID", channelID)
+		return
+	}
+
 	ch := &drive.Channel{
 		Id:         channelID,
 		ResourceId: resourceID,
 	}
 
 	// Stop watching the channel
-	gd.driveService.Channels.Stop(ch).Do()
+	err := gd.driveService.Channels.Stop(ch).Do()
+	if err != nil {
+		slog.Error("Failed to stop the watch channel", "channelID", channelID, "resourceID", resourceID, "error", err)
+	}
 }
@@ -20,8 +20,10 @@ type GDriveStorageContext struct {
 
 	// environment settings
 	webhookURL      string
+	webhookPattern  string
 	credentialsFile string
 	bundles         []config.StorageBundle
+	channelWatchMu  sync.RWMutex
 	channelWatchMap map[string]database.GoogleDriveWatch
 
 	driveService *drive.Service
@@ -32,4 +34,5 @@ type GoogleDriveStore interface {
 	CreateGoogleDriveWatch(ctx context.Context, arg database.CreateGoogleDriveWatchParams) (database.GoogleDriveWatch, error)
 	GetWatchEntriesByFolderIDs(ctx context.Context, resourceIds []string) ([]database.GoogleDriveWatch, error)
 	UpdateGoogleDriveWatch(ctx context.Context, arg database.UpdateGoogleDriveWatchParams) (database.GoogleDriveWatch, error)
+	DeleteGoogleDriveWatchByFolderID(ctx context.Context, resourceID string) error
 }
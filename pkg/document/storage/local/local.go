@@ -36,10 +36,8 @@ func (gd *LocalStorageContext) CancelAndWait() {
 }
 
 func (ld *LocalStorageContext) readConfigurationSettings() error {
+	// the path is only needed to write documents, reading uses the full path of the document
 	ld.localFilePath = os.Getenv("LOCAL_STORAGE_PATH")
-	if len(ld.localFilePath) == 0 {
-		return errors.New("environment variable LOCAL_STORAGE_PATH is not present")
-	}
 
 	return nil
 }
@@ -48,7 +46,7 @@ func (ld *LocalStorageContext) StartWatching() (chan *document.Document, error)
 	return nil, errors.ErrUnsupported
 }
 
-func (ld *LocalStorageContext) GetReader(document *document.Document) (io.ReadCloser, error) {
+func (ld *LocalStorageContext) GetReader(ctx context.Context, document *document.Document) (io.ReadCloser, error) {
 	file, err := os.Open(document.StorageDocumentID)
 	if err != nil {
 		return nil, err
@@ -60,6 +58,10 @@ func (ld *LocalStorageContext) GetReader(document *document.Document) (io.ReadCl
 func (ld *LocalStorageContext) Write(srcDoc *document.Document, reader io.ReadCloser) (*document.Document, error) {
 	defer reader.Close()
 
+	if len(ld.localFilePath) == 0 {
+		return &document.Document{}, errors.New("environment variable LOCAL_STORAGE_PATH is not present")
+	}
+
 	filePath := filepath.Join(ld.localFilePath, srcDoc.Name)
 
 	// Create output file
@@ -89,6 +91,6 @@ func (ld *LocalStorageContext) Write(srcDoc *document.Document, reader io.ReadCl
 	return &destDoc, nil
 }
 
-func (ld *LocalStorageContext) Archive(srcDoc *document.Document) error {
+func (ld *LocalStorageContext) Archive(ctx context.Context, srcDoc *document.Document) error {
 	return errors.ErrUnsupported
 }
@@ -24,9 +24,33 @@ type (
 	//      Input PDF
 	//      Output Markdown
 	TransformContext struct {
-		DocumentID     uuid.UUID     // Database document ID
-		SourceDocument *Document     // Source document
-		Reader         io.ReadCloser // Reader for the current Document representation.
+		Ctx            context.Context // Context for the document, carries the document trace span
+		DocumentID     uuid.UUID       // Database document ID
+		SourceDocument *Document       // Source document
+		Reader         io.ReadCloser   // Reader for the current Document representation.
+		Err            error           // Error from the stage that failed, later stages pass the document through
+	}
+
+	// ReadinessCheck reports whether a dependency of the document pipeline is ready.
+	ReadinessCheck func(ctx context.Context) error
+
+	// ReadinessReporter is implemented by storages and processors that depend on external services or settings.
+	ReadinessReporter interface {
+		// ReadinessChecks returns the checks keyed by the name of the component they check
+		ReadinessChecks() map[string]ReadinessCheck
+	}
+
+	// BundleUpdater is implemented by storages that can change the folders they watch while running.
+	BundleUpdater interface {
+		// UpdateBundles starts watching the source folders of new bundles and stops watching removed ones
+		UpdateBundles(bundles []config.StorageBundle) error
+	}
+
+	// PublicRouter is implemented by storages that register routes which authenticate the caller themselves,
+	// such as a webhook that checks the token of its watch channel.
+	PublicRouter interface {
+		// PublicRoutes returns the route patterns that do not require an API token
+		PublicRoutes() []string
 	}
 
 	// Storage represents where a Document will be read from and to.
@@ -41,12 +65,12 @@ type (
 		StartWatching() (chan *Document, error)
 
 		// Given a document, create a reader for its contents.
-		GetReader(document *Document) (io.ReadCloser, error)
+		GetReader(ctx context.Context, document *Document) (io.ReadCloser, error)
 
 		// Write a document to the DocumentStorage.
 		Write(sourceDocument *Document, reader io.ReadCloser) (*Document, error)
 
 		// Archive the document.  This is called after the document is successfully processed to ensure we don't process it again.
-		Archive(sourceDocument *Document) error
+		Archive(ctx context.Context, sourceDocument *Document) error
 	}
 )
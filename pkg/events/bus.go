@@ -0,0 +1,231 @@
+package events
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/pkg/document"
+	"github.com/google/uuid"
+)
+
+func NewBus() *Bus {
+	return &Bus{
+		history:     make([]Event, 0, historySize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// NewDocumentEvent will create an event for the document, the bundle is named after the folder the document was found in
+func NewDocumentEvent(eventType string, documentID uuid.UUID, srcDoc *document.Document, bundles []config.StorageBundle) Event {
+	return Event{
+		Type:         eventType,
+		DocumentID:   documentID,
+		SourceName:   srcDoc.Name,
+		SourceFolder: srcDoc.StorageFolderID,
+		Bundle:       BundleName(srcDoc, bundles),
+	}
+}
+
+// BundleName returns the name of the bundle the document was found in, or its source folder when the bundle
+// has no name
+func BundleName(srcDoc *document.Document, bundles []config.StorageBundle) string {
+	for _, b := range bundles {
+		if b.SourceFolder == srcDoc.StorageFolderID && len(b.Name) != 0 {
+			return b.Name
+		}
+	}
+
+	return srcDoc.StorageFolderID
+}
+
+// Publish will number the event and deliver it to the subscribers.  A subscriber that is too far behind is
+// closed rather than holding up the pipeline, it can subscribe again from the last event it received.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.lastID++
+	e.ID = b.lastID
+	e.Time = time.Now().UTC()
+
+	if len(b.history) == historySize {
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, e)
+
+	for s := range b.subscribers {
+		if !s.filter.Match(e) {
+			continue
+		}
+
+		if s.unbounded {
+			s.queue = append(s.queue, e)
+			select {
+			case s.wake <- struct{}{}:
+			default:
+			}
+			continue
+		}
+
+		select {
+		case s.ch <- e:
+		default:
+			slog.Warn("Closing the event subscriber that fell behind", "lastEventID", e.ID)
+			b.remove(s)
+		}
+	}
+}
+
+// Subscribe will return a subscription to the events that match the filter and the events after lastEventID that
+// were already published.  A lastEventID of zero only receives new events.  The IDs start over when the server
+// restarts so an ID newer than the last event replays all the events kept.
+func (b *Bus) Subscribe(filter Filter, lastEventID uint64) (*Subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := &Subscription{
+		bus:    b,
+		filter: filter,
+		ch:     make(chan Event, subscriberBufferSize),
+	}
+
+	if b.closed {
+		close(s.ch)
+		return s, nil
+	}
+
+	b.subscribers[s] = struct{}{}
+
+	backlog := make([]Event, 0)
+	if lastEventID == 0 {
+		return s, backlog
+	}
+
+	if lastEventID > b.lastID {
+		lastEventID = 0
+	}
+
+	for _, e := range b.history {
+		if e.ID > lastEventID && filter.Match(e) {
+			backlog = append(backlog, e)
+		}
+	}
+
+	return s, backlog
+}
+
+// SubscribeUnbounded will return a subscription to the new events that match the filter that is never closed for
+// falling behind.  The events wait in a queue until they are received, so it is for subscribers that must see
+// every event such as the notifications.
+func (b *Bus) SubscribeUnbounded(filter Filter) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := &Subscription{
+		bus:       b,
+		filter:    filter,
+		ch:        make(chan Event),
+		unbounded: true,
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	if b.closed {
+		close(s.ch)
+		return s
+	}
+
+	b.subscribers[s] = struct{}{}
+	go s.forward()
+
+	return s
+}
+
+// Close will close every subscription and ignore the events published afterwards
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subscribers {
+		b.remove(s)
+	}
+}
+
+// remove will close the subscription, the bus lock must be held
+func (b *Bus) remove(s *Subscription) {
+	if _, ok := b.subscribers[s]; !ok {
+		return
+	}
+
+	delete(b.subscribers, s)
+
+	// the events of an unbounded subscription are sent by its forward goroutine, which closes the channel
+	if s.unbounded {
+		close(s.done)
+		return
+	}
+
+	close(s.ch)
+}
+
+// forward will send the queued events of an unbounded subscription in order until it is closed
+func (s *Subscription) forward() {
+	defer close(s.ch)
+
+	for {
+		s.bus.mu.Lock()
+		queue := s.queue
+		s.queue = nil
+		s.bus.mu.Unlock()
+
+		for _, e := range queue {
+			select {
+			case s.ch <- e:
+			case <-s.done:
+				return
+			}
+		}
+
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Events returns the channel the events are delivered on, it is closed when the subscription ends
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Close will stop delivering events to the subscription
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.remove(s)
+}
+
+// Match reports whether the event passes the filter
+func (f Filter) Match(e Event) bool {
+	if f.DocumentID != uuid.Nil && f.DocumentID != e.DocumentID {
+		return false
+	}
+
+	if len(f.Bundle) != 0 && f.Bundle != e.Bundle && f.Bundle != e.SourceFolder {
+		return false
+	}
+
+	return true
+}
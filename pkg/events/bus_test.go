@@ -0,0 +1,81 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribeFallingBehind(t *testing.T) {
+	tests := []struct {
+		name       string
+		unbounded  bool
+		wantEvents int
+	}{
+		{name: "subscriber is closed", wantEvents: subscriberBufferSize},
+		{name: "unbounded subscriber receives every event", unbounded: true, wantEvents: 2 * subscriberBufferSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBus()
+
+			var sub *Subscription
+			if tt.unbounded {
+				sub = b.SubscribeUnbounded(Filter{})
+			} else {
+				sub, _ = b.Subscribe(Filter{}, 0)
+			}
+			defer sub.Close()
+
+			// nothing is received while the events are published
+			for range 2 * subscriberBufferSize {
+				b.Publish(Event{Type: TypeDetected})
+			}
+
+			got := 0
+			for got < tt.wantEvents {
+				select {
+				case e, ok := <-sub.Events():
+					if !ok {
+						t.Fatalf("the subscription was closed after %d events, want %d", got, tt.wantEvents)
+					}
+
+					got++
+					if e.ID != uint64(got) {
+						t.Fatalf("event %d has the ID %d", got, e.ID)
+					}
+
+				case <-time.After(time.Second):
+					t.Fatalf("received %d events, want %d", got, tt.wantEvents)
+				}
+			}
+
+			if !tt.unbounded {
+				if _, ok := <-sub.Events(); ok {
+					t.Error("the subscriber that fell behind was not closed")
+				}
+			}
+		})
+	}
+}
+
+func TestSubscribeUnboundedClose(t *testing.T) {
+	b := NewBus()
+	sub := b.SubscribeUnbounded(Filter{})
+	b.Publish(Event{Type: TypeDetected})
+
+	b.Close()
+
+	// the queued events may be dropped, but the channel has to be closed
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("the subscription was not closed with the bus")
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Document lifecycle event types
+const (
+	TypeDetected      = "detected"
+	TypeStageStarted  = "stage_started"
+	TypeStageFinished = "stage_finished"
+	TypeFailed        = "failed"
+	TypeArchived      = "archived"
+	TypeCompleted     = "completed"
+	TypeCanceled      = "canceled"
+)
+
+// The number of events kept to resume a subscription from
+const historySize = 1000
+
+// The number of events a subscriber can fall behind before it is closed
+const subscriberBufferSize = 256
+
+type (
+	// Event is a step in the life of a document.  The ID increases with every event published since the
+	// server started.
+	Event struct {
+		ID           uint64    `json:"id"`
+		Time         time.Time `json:"time"`
+		Type         string    `json:"type"`
+		DocumentID   uuid.UUID `json:"document_id"`
+		SourceName   string    `json:"source_name"`
+		Bundle       string    `json:"bundle"`
+		SourceFolder string    `json:"source_folder"`
+		Stage        string    `json:"stage,omitempty"`
+		Error        string    `json:"error,omitempty"`
+	}
+
+	// Filter selects the events a subscriber receives, empty fields match every event
+	Filter struct {
+		// the name or source folder of the bundle
+		Bundle     string
+		DocumentID uuid.UUID
+	}
+
+	// Bus delivers the events published by the document manager and the processors to the subscribers
+	Bus struct {
+		mu          sync.Mutex
+		lastID      uint64
+		history     []Event
+		subscribers map[*Subscription]struct{}
+		closed      bool
+	}
+
+	// Subscription receives the events that match its filter until it is closed.  An unbounded subscription
+	// queues the events it has not received yet instead of being closed when it falls behind.
+	Subscription struct {
+		bus    *Bus
+		filter Filter
+		ch     chan Event
+
+		unbounded bool
+		queue     []Event
+		wake      chan struct{}
+		done      chan struct{}
+	}
+)
@@ -0,0 +1,163 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NewCaptureHandler will wrap the handler so the records of a document at or above the level are also recorded
+func NewCaptureHandler(next slog.Handler, recorder *Recorder, level slog.Leveler) *CaptureHandler {
+	return &CaptureHandler{
+		next:     next,
+		recorder: recorder,
+		level:    level,
+		attrs:    make(map[string]any),
+	}
+}
+
+func (h *CaptureHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level) || level >= h.level.Level()
+}
+
+func (h *CaptureHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	if h.next.Enabled(ctx, r.Level) {
+		err = h.next.Handle(ctx, r)
+	}
+
+	if r.Level < h.level.Level() {
+		return err
+	}
+
+	documentID := h.documentID
+	attrs := maps.Clone(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		if id, ok := documentIDAttr(h.prefix, a); ok {
+			documentID = id
+			return true
+		}
+
+		addAttr(attrs, h.prefix, a)
+		return true
+	})
+
+	if documentID == uuid.Nil {
+		return err
+	}
+
+	h.recorder.Record(LogRecord{
+		DocumentID: documentID,
+		Time:       r.Time,
+		Level:      r.Level,
+		Message:    r.Message,
+		Attrs:      attrs,
+	})
+
+	return err
+}
+
+func (h *CaptureHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := h.clone()
+	c.next = h.next.WithAttrs(attrs)
+	for _, a := range attrs {
+		if id, ok := documentIDAttr(h.prefix, a); ok {
+			c.documentID = id
+			continue
+		}
+
+		addAttr(c.attrs, h.prefix, a)
+	}
+
+	return c
+}
+
+func (h *CaptureHandler) WithGroup(name string) slog.Handler {
+	if len(name) == 0 {
+		return h
+	}
+
+	c := h.clone()
+	c.next = h.next.WithGroup(name)
+	c.prefix = h.prefix + name + "."
+
+	return c
+}
+
+func (h *CaptureHandler) clone() *CaptureHandler {
+	c := *h
+	c.attrs = maps.Clone(h.attrs)
+
+	return &c
+}
+
+// documentIDAttr returns the document of a document_id attribute outside of any group
+func documentIDAttr(prefix string, a slog.Attr) (uuid.UUID, bool) {
+	if len(prefix) != 0 || a.Key != KeyDocumentID {
+		return uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(a.Value.Resolve().String())
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+// addAttr will add the attribute to the map, the attributes of a group are added with the group name as a prefix
+func addAttr(attrs map[string]any, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		// an inline group has no key, its attributes are added at the same level
+		if len(a.Key) != 0 {
+			prefix = prefix + a.Key + "."
+		}
+
+		for _, ga := range v.Group() {
+			addAttr(attrs, prefix, ga)
+		}
+		return
+	}
+
+	if len(a.Key) == 0 {
+		return
+	}
+
+	attrs[prefix+a.Key] = attrValue(v)
+}
+
+// attrValue returns a value that can be written as JSON
+func attrValue(v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		return v.Float64()
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	}
+
+	switch value := v.Any().(type) {
+	case nil:
+		return nil
+	case error:
+		return value.Error()
+	case fmt.Stringer:
+		return value.String()
+	default:
+		return fmt.Sprintf("%+v", value)
+	}
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NewHandler will create the handler that writes the log in the given format
+func NewHandler(w io.Writer, format string, opts *slog.HandlerOptions) (slog.Handler, error) {
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return slog.NewTextHandler(w, opts), nil
+	case FormatJSON:
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("%w: %q, expected %s or %s", ErrInvalidFormat, format, FormatText, FormatJSON)
+	}
+}
+
+// WithLogger returns a context that carries the logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by the context, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+// WithDocument returns a context whose logger adds the document to every line
+func WithDocument(ctx context.Context, id uuid.UUID, sourceName, bundle string) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(
+		KeyDocumentID, id.String(),
+		KeySourceName, sourceName,
+		KeyBundle, bundle,
+	))
+}
+
+// WithStage returns a context whose logger adds the processor stage to every line
+func WithStage(ctx context.Context, stage string) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(KeyStage, stage))
+}
+
+// Span will time the caller, the returned function logs the duration at the debug level when called.  It is
+// meant to be deferred at the top of a function:
+//
+//	defer logging.Span("DocumentManager.Reload")()
+func Span(name string) func() {
+	return SpanContext(context.Background(), name)
+}
+
+// SpanContext will time the caller like Span, the duration is logged with the logger of the context
+func SpanContext(ctx context.Context, name string) func() {
+	logger := FromContext(ctx)
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return func() {}
+	}
+
+	start := time.Now()
+
+	return func() {
+		logger.DebugContext(ctx, "span", KeySpan, name, KeyDuration, float64(time.Since(start).Microseconds())/1000)
+	}
+}
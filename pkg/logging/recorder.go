@@ -0,0 +1,136 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/google/uuid"
+)
+
+// NewRecorder will create a recorder that keeps the newest lines of each document
+func NewRecorder(store DocumentLogStore, maxPerDocument int) *Recorder {
+	return &Recorder{
+		wg:             &sync.WaitGroup{},
+		store:          store,
+		maxPerDocument: maxPerDocument,
+		records:        make(chan LogRecord, recordBufferSize),
+	}
+}
+
+// Start will save the recorded lines until the context is canceled
+func (rc *Recorder) Start(ctx context.Context) {
+	rc.ctx, rc.cancelFunc = context.WithCancel(ctx)
+
+	rc.wg.Add(1)
+	go rc.save()
+}
+
+// CancelAndWait will save the lines that are still buffered and stop the recorder
+func (rc *Recorder) CancelAndWait() {
+	rc.cancelFunc()
+	rc.wg.Wait()
+}
+
+// Record will buffer the line to be saved, it never blocks the caller
+func (rc *Recorder) Record(r LogRecord) {
+	select {
+	case rc.records <- r:
+	default:
+		rc.mu.Lock()
+		rc.dropped++
+		rc.mu.Unlock()
+	}
+}
+
+func (rc *Recorder) save() {
+	defer rc.wg.Done()
+
+	ticker := time.NewTicker(trimInterval)
+	defer ticker.Stop()
+
+	logged := make(map[uuid.UUID]bool)
+	for {
+		select {
+		case <-rc.ctx.Done():
+			// save what was logged while shutting down
+			for {
+				select {
+				case r := <-rc.records:
+					rc.write(r)
+					logged[r.DocumentID] = true
+				default:
+					rc.trim(logged)
+					rc.report()
+					return
+				}
+			}
+
+		case r := <-rc.records:
+			rc.write(r)
+			logged[r.DocumentID] = true
+
+		case <-ticker.C:
+			rc.trim(logged)
+			logged = make(map[uuid.UUID]bool)
+			rc.report()
+		}
+	}
+}
+
+// write is called after the context is canceled as well, so it does not use it
+func (rc *Recorder) write(r LogRecord) {
+	attrs, err := json.Marshal(r.Attrs)
+	if err != nil {
+		attrs = []byte("{}")
+	}
+
+	err = rc.store.CreateDocumentLog(context.Background(), database.CreateDocumentLogParams{
+		DocumentID: r.DocumentID,
+		LoggedAt:   r.Time.UTC(),
+		Level:      r.Level.String(),
+		Message:    r.Message,
+		Attrs:      string(attrs),
+	})
+	if err != nil {
+		rc.mu.Lock()
+		rc.failed++
+		rc.lastErr = err
+		rc.mu.Unlock()
+	}
+}
+
+// trim will delete the oldest lines of the documents that are over the limit
+func (rc *Recorder) trim(logged map[uuid.UUID]bool) {
+	for id := range logged {
+		err := rc.store.TrimDocumentLogs(context.Background(), database.TrimDocumentLogsParams{
+			DocumentID: id,
+			Keep:       int32(rc.maxPerDocument),
+		})
+		if err != nil {
+			rc.mu.Lock()
+			rc.lastErr = err
+			rc.mu.Unlock()
+		}
+	}
+}
+
+// report will log the lines that were lost.  The line is not logged for a document, or it would be recorded
+// and could fail again.
+func (rc *Recorder) report() {
+	rc.mu.Lock()
+	dropped, failed, lastErr := rc.dropped, rc.failed, rc.lastErr
+	rc.dropped, rc.failed, rc.lastErr = 0, 0, nil
+	rc.mu.Unlock()
+
+	if dropped != 0 {
+		slog.Warn("Dropped document log lines, the buffer was full", "count", dropped)
+	}
+
+	if lastErr != nil {
+		slog.Warn("Failed to save the document log", "failedLines", failed, "error", lastErr)
+	}
+}
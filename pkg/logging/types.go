@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/google/uuid"
+)
+
+// The formats the log can be written in
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// The attributes of the lines logged while processing a document, so the lines of documents processed at the
+// same time can be told apart
+const (
+	KeyDocumentID = "document_id"
+	KeySourceName = "source_name"
+	KeyBundle     = "bundle"
+	KeyStage      = "stage"
+)
+
+// The attributes of the line logged when a span ends
+const (
+	KeySpan     = "span"
+	KeyDuration = "duration_ms"
+)
+
+var ErrInvalidFormat = errors.New("invalid log format")
+
+// contextKey is the key the logger is stored under in a context
+type contextKey struct{}
+
+// The number of document log lines waiting to be saved, lines logged while the buffer is full are dropped
+const recordBufferSize = 1024
+
+// How often the documents that were logged for are trimmed to their limit and dropped lines are reported
+const trimInterval = 5 * time.Second
+
+// CaptureHandler passes every record to the next handler and hands the records logged for a document to
+// the recorder, so what happened to a document can be read back without searching the server log
+type CaptureHandler struct {
+	next     slog.Handler
+	recorder *Recorder
+	level    slog.Leveler
+
+	// the attributes added with WithAttrs, keyed with the groups they were added in
+	prefix     string
+	attrs      map[string]any
+	documentID uuid.UUID
+}
+
+// LogRecord is a log line of a document
+type LogRecord struct {
+	DocumentID uuid.UUID
+	Time       time.Time
+	Level      slog.Level
+	Message    string
+	Attrs      map[string]any
+}
+
+// DocumentLogStore is used to save the log lines of the documents
+type DocumentLogStore interface {
+	CreateDocumentLog(ctx context.Context, arg database.CreateDocumentLogParams) error
+	TrimDocumentLogs(ctx context.Context, arg database.TrimDocumentLogsParams) error
+}
+
+// Recorder saves the log lines of the documents in the background and keeps the newest lines of each document
+type Recorder struct {
+	ctx            context.Context
+	cancelFunc     context.CancelFunc
+	wg             *sync.WaitGroup
+	store          DocumentLogStore
+	maxPerDocument int
+	records        chan LogRecord
+
+	// lines that could not be buffered or saved since the last report
+	mu      sync.Mutex
+	dropped int
+	failed  int
+	lastErr error
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/KyleBrandon/scriptoria/pkg/document/cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cacheStatsCollector exports the counters of the processor result cache that is currently in use.
+type cacheStatsCollector struct {
+	cache     atomic.Pointer[cache.Cache]
+	evictions *prometheus.Desc
+	entries   *prometheus.Desc
+	bytes     *prometheus.Desc
+}
+
+var cacheCollector = &cacheStatsCollector{
+	evictions: prometheus.NewDesc(namespace+"_cache_evictions_total", "Number of entries evicted from the processor result cache.", nil, nil),
+	entries:   prometheus.NewDesc(namespace+"_cache_entries", "Number of entries in the processor result cache.", nil, nil),
+	bytes:     prometheus.NewDesc(namespace+"_cache_size_bytes", "Size of the processor result cache.", nil, nil),
+}
+
+// SetCache will export the stats for the given cache
+func SetCache(c *cache.Cache) {
+	cacheCollector.cache.Store(c)
+}
+
+func (cc *cacheStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cc.evictions
+	ch <- cc.entries
+	ch <- cc.bytes
+}
+
+func (cc *cacheStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	c := cc.cache.Load()
+	if c == nil {
+		return
+	}
+
+	stats := c.Stats()
+	ch <- prometheus.MustNewConstMetric(cc.evictions, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(cc.entries, prometheus.GaugeValue, float64(stats.Entries))
+	ch <- prometheus.MustNewConstMetric(cc.bytes, prometheus.GaugeValue, float64(stats.Bytes))
+}
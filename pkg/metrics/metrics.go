@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "scriptoria"
+
+// StatusError is implemented by errors that carry the HTTP status code of a failed request.
+type StatusError interface {
+	error
+	HTTPStatusCode() int
+}
+
+var (
+	registry = prometheus.NewRegistry()
+
+	// DocumentsIngested counts the documents that entered the pipeline per bundle name.
+	DocumentsIngested = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "documents_ingested_total",
+		Help:      "Number of documents that entered the pipeline.",
+	}, []string{"bundle"})
+
+	// DocumentsCompleted counts the documents that finished the pipeline per bundle name.
+	DocumentsCompleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "documents_completed_total",
+		Help:      "Number of documents that finished the pipeline.",
+	}, []string{"bundle"})
+
+	// StageDuration observes how long each processor stage ran.
+	StageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stage_duration_seconds",
+		Help:      "Time spent processing a document in a stage.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600},
+	}, []string{"stage", "status"})
+
+	// StageFailures counts the failed stages by the class of the error.
+	StageFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stage_failures_total",
+		Help:      "Number of failed stages by error class.",
+	}, []string{"stage", "error_class"})
+
+	// StageInFlight is the number of documents currently in each stage.
+	StageInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stage_in_flight_documents",
+		Help:      "Number of documents currently being processed by a stage.",
+	}, []string{"stage"})
+
+	// QueueDepth is the number of documents waiting to enter the pipeline, either for room among the documents in
+	// flight or for the first stage to accept them.
+	QueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pipeline_queue_depth",
+		Help:      "Number of documents waiting to enter the pipeline.",
+	})
+
+	// ExternalRequestDuration observes the latency of requests to the external APIs.
+	ExternalRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "external_request_duration_seconds",
+		Help:      "Latency of requests to external APIs by provider and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider", "method", "status_code"})
+
+	// RateLimitWait observes how long requests waited for the rate limiter of an external provider.
+	RateLimitWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_wait_seconds",
+		Help:      "Time spent waiting for the rate limiter of an external provider.",
+		Buckets:   []float64{0, 0.1, 0.5, 1, 5, 15, 30, 60, 120},
+	}, []string{"provider", "limit"})
+
+	// BudgetExhausted is 1 while the daily or monthly budget of a provider is used up and its stage is paused.
+	BudgetExhausted = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "budget_exhausted",
+		Help:      "Whether the budget of an external provider is used up and its stage is paused.",
+	}, []string{"provider"})
+
+	// WatchChannelExpiry is the time the Google Drive watch channel for a folder expires.
+	WatchChannelExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "drive_watch_channel_expiry_timestamp_seconds",
+		Help:      "Unix time the Google Drive watch channel for a folder expires.",
+	}, []string{"resource_id"})
+
+	// CacheRequests counts the lookups in the processor result cache.
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Number of processor result cache lookups by stage and result.",
+	}, []string{"stage", "result"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		DocumentsIngested,
+		DocumentsCompleted,
+		StageDuration,
+		StageFailures,
+		StageInFlight,
+		QueueDepth,
+		ExternalRequestDuration,
+		RateLimitWait,
+		BudgetExhausted,
+		WatchChannelExpiry,
+		CacheRequests,
+		cacheCollector,
+	)
+}
+
+// RegisterRoutes will add the metrics endpoint to the mux
+func RegisterRoutes(mux *http.ServeMux) {
+	mux.Handle("GET /metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+}
+
+// ErrorClass will group an error into a small set of classes so it can be used as a label.
+func ErrorClass(err error) string {
+	var statusErr StatusError
+	var netErr net.Error
+	var pathErr *fs.PathError
+
+	switch {
+	case err == nil:
+		return "none"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &statusErr):
+		return "http_" + strconv.Itoa(statusErr.HTTPStatusCode())
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	case errors.As(err, &pathErr):
+		return "filesystem"
+	default:
+		return "other"
+	}
+}
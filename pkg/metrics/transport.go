@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// instrumentedTransport records the latency and status code of every request made to an external provider.
+type instrumentedTransport struct {
+	provider string
+	next     http.RoundTripper
+}
+
+// InstrumentTransport will wrap the round tripper so requests are recorded for the provider. A nil round tripper
+// uses http.DefaultTransport.
+func InstrumentTransport(provider string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &instrumentedTransport{
+		provider: provider,
+		next:     next,
+	}
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+
+	resp, err := t.next.RoundTrip(req)
+
+	statusCode := "error"
+	if err == nil {
+		statusCode = strconv.Itoa(resp.StatusCode)
+	}
+
+	ExternalRequestDuration.WithLabelValues(t.provider, req.Method, statusCode).Observe(time.Since(start).Seconds())
+
+	return resp, err
+}
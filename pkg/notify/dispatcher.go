@@ -0,0 +1,256 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/document/processor"
+	"github.com/KyleBrandon/scriptoria/pkg/events"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+)
+
+// NewDispatcher will create a dispatcher that reads the bundles and targets from the running configuration
+// each time an event arrives, so reloading the configuration changes where notifications are sent.
+func NewDispatcher(store DeliveryStore, source EventSource, settings func() config.Config) *Dispatcher {
+	return &Dispatcher{
+		wg:       &sync.WaitGroup{},
+		store:    store,
+		source:   source,
+		settings: settings,
+		client:   &http.Client{Timeout: deliveryTimeout},
+	}
+}
+
+// Start will retry the deliveries left pending by the last shutdown and send notifications for new events
+func (d *Dispatcher) Start(ctx context.Context) {
+	defer logging.SpanContext(ctx, "Dispatcher.Start")()
+
+	d.ctx, d.cancelFunc = context.WithCancel(ctx)
+
+	// the subscription is never dropped for falling behind, so a burst of events does not stop the notifications
+	sub := d.source.SubscribeUnbounded(events.Filter{})
+
+	d.wg.Add(1)
+	go d.dispatch(sub)
+
+	d.resumePending()
+}
+
+// CancelAndWait will stop the dispatcher, deliveries still being retried stay pending until the next start
+func (d *Dispatcher) CancelAndWait() {
+	d.cancelFunc()
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) dispatch(sub *events.Subscription) {
+	defer d.wg.Done()
+	defer sub.Close()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+
+			// the deliveries are recorded outside the receive loop so a slow database does not hold up the events
+			d.wg.Add(1)
+			go d.notify(e)
+		}
+	}
+}
+
+// notify will start a delivery to every target if the bundle of the document notifies on the event
+func (d *Dispatcher) notify(e events.Event) {
+	defer d.wg.Done()
+
+	cfg := d.settings()
+
+	i := slices.IndexFunc(cfg.Bundles, func(b config.StorageBundle) bool { return b.SourceFolder == e.SourceFolder })
+	if i < 0 || !slices.Contains(cfg.Bundles[i].NotifyOn, e.Type) {
+		return
+	}
+
+	payload := newPayload(e, cfg.Bundles[i])
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Failed to encode the notification", "documentID", e.DocumentID, "error", err)
+		return
+	}
+
+	for _, t := range cfg.Notifications.Targets {
+		delivery, err := d.store.CreateNotificationDelivery(d.ctx, database.CreateNotificationDeliveryParams{
+			DocumentID: e.DocumentID,
+			EventType:  e.Type,
+			TargetName: t.Name,
+			TargetType: t.Type,
+			Payload:    string(body),
+			Status:     DeliveryStatusPending,
+		})
+		if err != nil {
+			slog.Error("Failed to record the notification delivery", "documentID", e.DocumentID, "target", t.Name, "error", err)
+			continue
+		}
+
+		d.wg.Add(1)
+		go d.deliver(delivery, payload)
+	}
+}
+
+// resumePending will retry the deliveries that had not succeeded or run out of attempts
+func (d *Dispatcher) resumePending() {
+	pending, err := d.store.GetPendingNotificationDeliveries(d.ctx)
+	if err != nil {
+		slog.Error("Failed to query the pending notification deliveries", "error", err)
+		return
+	}
+
+	for _, delivery := range pending {
+		var payload Payload
+		err := json.Unmarshal([]byte(delivery.Payload), &payload)
+		if err != nil {
+			d.finish(delivery, DeliveryStatusFailed, err)
+			continue
+		}
+
+		d.wg.Add(1)
+		go d.deliver(delivery, payload)
+	}
+}
+
+// deliver will send the notification and retry with an exponential backoff until it succeeds or runs out of attempts
+func (d *Dispatcher) deliver(delivery database.NotificationDelivery, payload Payload) {
+	defer d.wg.Done()
+
+	for {
+		// the target is read on every attempt so a target fixed by a reload is used on the next retry
+		cfg := d.settings()
+		i := slices.IndexFunc(cfg.Notifications.Targets, func(t config.NotificationTarget) bool { return t.Name == delivery.TargetName })
+		if i < 0 {
+			d.finish(delivery, DeliveryStatusFailed, errors.New("the target is no longer configured"))
+			return
+		}
+
+		notifier, err := newNotifier(cfg.Notifications.Targets[i], d.client)
+		if err != nil {
+			d.finish(delivery, DeliveryStatusFailed, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(d.ctx, deliveryTimeout)
+		err = notifier.Send(ctx, delivery.ID, payload)
+		cancel()
+
+		// a delivery interrupted by the shutdown is retried on the next start
+		if d.ctx.Err() != nil {
+			return
+		}
+
+		delivery.Attempts++
+		if err == nil {
+			d.finish(delivery, DeliveryStatusDelivered, nil)
+			slog.Info("Delivered the notification", "documentID", delivery.DocumentID, "event", delivery.EventType, "target", delivery.TargetName)
+			return
+		}
+
+		slog.Warn("Failed to deliver the notification", "documentID", delivery.DocumentID, "target", delivery.TargetName, "attempt", delivery.Attempts, "error", err)
+		if int(delivery.Attempts) >= cfg.Notifications.MaxAttempts {
+			d.finish(delivery, DeliveryStatusFailed, err)
+			return
+		}
+
+		delivery = d.update(delivery, DeliveryStatusPending, err)
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(backoff(cfg.Notifications.InitialBackoff.Duration(), delivery.Attempts)):
+		}
+	}
+}
+
+func (d *Dispatcher) finish(delivery database.NotificationDelivery, status string, err error) {
+	if err != nil {
+		slog.Error("Giving up on the notification", "documentID", delivery.DocumentID, "target", delivery.TargetName, "attempts", delivery.Attempts, "error", err)
+	}
+
+	d.update(delivery, status, err)
+}
+
+// update will record the result of the last attempt in the delivery log
+func (d *Dispatcher) update(delivery database.NotificationDelivery, status string, err error) database.NotificationDelivery {
+	args := database.UpdateNotificationDeliveryParams{
+		ID:        delivery.ID,
+		Status:    status,
+		Attempts:  delivery.Attempts,
+		LastError: delivery.LastError,
+	}
+
+	if err != nil {
+		args.LastError = sql.NullString{String: err.Error(), Valid: true}
+	}
+
+	if status == DeliveryStatusDelivered {
+		args.DeliveredAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+
+	// the log is written even while shutting down so the attempt is not lost
+	updated, uerr := d.store.UpdateNotificationDelivery(context.Background(), args)
+	if uerr != nil {
+		slog.Error("Failed to update the notification delivery", "id", delivery.ID, "error", uerr)
+		return delivery
+	}
+
+	return updated
+}
+
+// backoff doubles the wait after every failed attempt
+func backoff(initial time.Duration, attempts int32) time.Duration {
+	wait := initial
+	for i := int32(1); i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+
+	return min(wait, maxBackoff)
+}
+
+func newPayload(e events.Event, bundle config.StorageBundle) Payload {
+	p := Payload{
+		Event:      e.Type,
+		Time:       e.Time,
+		DocumentID: e.DocumentID,
+		SourceName: e.SourceName,
+		Bundle:     e.Bundle,
+		Stage:      e.Stage,
+		Error:      e.Error,
+	}
+
+	switch e.Type {
+	case events.TypeFailed:
+		p.Status = "failed"
+	case events.TypeCanceled:
+		p.Status = "canceled"
+	case events.TypeDetected:
+		p.Status = "processing"
+	default:
+		p.Status = "succeeded"
+		p.Outputs = &Outputs{
+			Note:       processor.NotePath(bundle, e.SourceName),
+			Attachment: processor.AttachmentPath(bundle, e.SourceName),
+		}
+	}
+
+	return p
+}
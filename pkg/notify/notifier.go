@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/pkg/events"
+)
+
+// notifierBuilders creates the notifier for each target type the config file can use
+var notifierBuilders = map[string]func(t config.NotificationTarget, client *http.Client) Notifier{
+	"webhook": func(t config.NotificationTarget, client *http.Client) Notifier { return newWebhook(t, client) },
+	"ntfy":    func(t config.NotificationTarget, client *http.Client) Notifier { return newNtfy(t, client) },
+	"smtp":    func(t config.NotificationTarget, _ *http.Client) Notifier { return newSMTP(t) },
+}
+
+// Types returns the target types the config file can reference
+func Types() []string {
+	types := make([]string, 0, len(notifierBuilders))
+	for name := range notifierBuilders {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+
+	return types
+}
+
+func newNotifier(t config.NotificationTarget, client *http.Client) (Notifier, error) {
+	build, ok := notifierBuilders[t.Type]
+	if !ok {
+		return nil, fmt.Errorf("invalid notifier: %s", t.Type)
+	}
+
+	return build(t, client), nil
+}
+
+// Title is a one line summary of the event
+func (p Payload) Title() string {
+	switch p.Event {
+	case events.TypeDetected:
+		return fmt.Sprintf("Processing %s", p.SourceName)
+	case events.TypeFailed:
+		return fmt.Sprintf("Failed to process %s", p.SourceName)
+	case events.TypeArchived:
+		return fmt.Sprintf("Archived %s", p.SourceName)
+	case events.TypeCompleted:
+		return fmt.Sprintf("Processed %s", p.SourceName)
+	case events.TypeCanceled:
+		return fmt.Sprintf("Canceled %s", p.SourceName)
+	}
+
+	return fmt.Sprintf("%s %s", p.Event, p.SourceName)
+}
+
+// Message is the plain text body of the notification
+func (p Payload) Message() string {
+	message := fmt.Sprintf("%s\n\nBundle: %s\nDocument: %s\nStatus: %s\n", p.Title(), p.Bundle, p.DocumentID, p.Status)
+	if len(p.Stage) != 0 {
+		message += fmt.Sprintf("Stage: %s\n", p.Stage)
+	}
+
+	if len(p.Error) != 0 {
+		message += fmt.Sprintf("Error: %s\n", p.Error)
+	}
+
+	if p.Outputs != nil {
+		message += fmt.Sprintf("Note: %s\nAttachment: %s\n", p.Outputs.Note, p.Outputs.Attachment)
+	}
+
+	return message
+}
@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/pkg/events"
+	"github.com/google/uuid"
+)
+
+// ntfy will publish the message to an ntfy style topic URL such as https://ntfy.sh/my-notes
+type ntfy struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func newNtfy(t config.NotificationTarget, client *http.Client) *ntfy {
+	return &ntfy{
+		url:    t.URL,
+		token:  t.Token,
+		client: client,
+	}
+}
+
+func (n *ntfy) Send(ctx context.Context, deliveryID uuid.UUID, payload Payload) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, strings.NewReader(payload.Message()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Title", payload.Title())
+	req.Header.Set("Tags", "scriptoria,"+payload.Event)
+	if payload.Event == events.TypeFailed {
+		req.Header.Set("Priority", "high")
+	}
+
+	if len(n.token) != 0 {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	return send(n.client, req)
+}
@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/google/uuid"
+)
+
+// smtpNotifier will email the message to the addresses of the target
+type smtpNotifier struct {
+	config config.SMTPConfig
+}
+
+func newSMTP(t config.NotificationTarget) *smtpNotifier {
+	return &smtpNotifier{config: t.SMTP}
+}
+
+func (s *smtpNotifier) Send(ctx context.Context, deliveryID uuid.UUID, payload Payload) error {
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+
+	var auth smtp.Auth
+	if len(s.config.Username) != 0 {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+
+	headers := []string{
+		"From: " + s.config.From,
+		"To: " + strings.Join(s.config.To, ", "),
+		"Subject: " + payload.Title(),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		fmt.Sprintf("Message-ID: <%s@scriptoria>", deliveryID),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+	}
+	msg := strings.Join(headers, "\r\n") + "\r\n\r\n" + strings.ReplaceAll(payload.Message(), "\n", "\r\n")
+
+	// net/smtp does not take a context, run it so a canceled delivery does not wait on a slow server
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, s.config.From, s.config.To, []byte(msg))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/events"
+	"github.com/google/uuid"
+)
+
+// Delivery statuses recorded in the delivery log
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusFailed    = "failed"
+)
+
+// How long a single delivery attempt can take
+const deliveryTimeout = 30 * time.Second
+
+// The longest wait between two delivery attempts
+const maxBackoff = 30 * time.Minute
+
+type (
+	// Notifier sends a notification to one target
+	Notifier interface {
+		Send(ctx context.Context, deliveryID uuid.UUID, payload Payload) error
+	}
+
+	// Payload describes the document event a notification is sent for
+	Payload struct {
+		Event      string    `json:"event"`
+		Time       time.Time `json:"time"`
+		DocumentID uuid.UUID `json:"document_id"`
+		SourceName string    `json:"source_name"`
+		Bundle     string    `json:"bundle"`
+		Status     string    `json:"status"`
+		Stage      string    `json:"stage,omitempty"`
+		Error      string    `json:"error,omitempty"`
+		Outputs    *Outputs  `json:"outputs,omitempty"`
+	}
+
+	// Outputs are the files written for a processed document
+	Outputs struct {
+		Note       string `json:"note"`
+		Attachment string `json:"attachment"`
+	}
+
+	// DeliveryStore is used to keep the delivery log
+	DeliveryStore interface {
+		CreateNotificationDelivery(ctx context.Context, arg database.CreateNotificationDeliveryParams) (database.NotificationDelivery, error)
+		UpdateNotificationDelivery(ctx context.Context, arg database.UpdateNotificationDeliveryParams) (database.NotificationDelivery, error)
+		GetPendingNotificationDeliveries(ctx context.Context) ([]database.NotificationDelivery, error)
+	}
+
+	// EventSource is used to subscribe to the document lifecycle events
+	EventSource interface {
+		SubscribeUnbounded(filter events.Filter) *events.Subscription
+	}
+
+	// Dispatcher sends the notifications the bundles ask for to every configured target
+	Dispatcher struct {
+		ctx        context.Context
+		cancelFunc context.CancelFunc
+		wg         *sync.WaitGroup
+		store      DeliveryStore
+		source     EventSource
+		settings   func() config.Config
+		client     *http.Client
+	}
+)
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/google/uuid"
+)
+
+// webhook will POST the payload as JSON.  When the target has a secret the body is signed with HMAC-SHA256 and
+// the signature is sent in the X-Scriptoria-Signature header so the receiver can verify it.
+type webhook struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func newWebhook(t config.NotificationTarget, client *http.Client) *webhook {
+	return &webhook{
+		url:    t.URL,
+		secret: t.Secret,
+		client: client,
+	}
+}
+
+func (wh *webhook) Send(ctx context.Context, deliveryID uuid.UUID, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "scriptoria")
+	req.Header.Set("X-Scriptoria-Event", payload.Event)
+	req.Header.Set("X-Scriptoria-Delivery", deliveryID.String())
+	if len(wh.secret) != 0 {
+		req.Header.Set("X-Scriptoria-Signature", "sha256="+Sign(wh.secret, body))
+	}
+
+	return send(wh.client, req)
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the body, receivers compute the same to verify a webhook
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// send will make the request and treat any status other than 2xx as a failure
+func send(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected response %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+
+	return nil
+}
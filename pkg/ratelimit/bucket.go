@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// setRate will change how many tokens the bucket allows per minute, zero removes the limit
+func (b *Bucket) setRate(perMinute int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.refill(now)
+
+	unlimited := b.rate == 0
+	b.rate = float64(perMinute) / 60
+	b.capacity = float64(perMinute)
+
+	// a new limit starts with a full bucket
+	if unlimited {
+		b.tokens = b.capacity
+	}
+
+	b.tokens = math.Min(b.tokens, b.capacity)
+	b.last = now
+}
+
+// Wait will block until n tokens can be taken or the context is done
+func (b *Bucket) Wait(ctx context.Context, n int) error {
+	for {
+		wait := b.take(float64(n))
+		if wait == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Adjust will take n more tokens from the bucket, or give them back when n is negative
+func (b *Bucket) Adjust(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rate == 0 {
+		return
+	}
+
+	b.refill(time.Now())
+	b.tokens = math.Min(b.tokens-float64(n), b.capacity)
+}
+
+// take will remove the tokens and return zero, or return how long to wait before trying again.  A request for
+// more than the bucket holds is let through once the bucket is full.
+func (b *Bucket) take(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rate == 0 {
+		return 0
+	}
+
+	b.refill(time.Now())
+
+	need := math.Min(n, b.capacity)
+	if b.tokens >= need {
+		b.tokens -= n
+		return 0
+	}
+
+	return time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+}
+
+// refill will add the tokens earned since the last call, the lock must be held
+func (b *Bucket) refill(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.tokens+now.Sub(b.last).Seconds()*b.rate, b.capacity)
+	}
+
+	b.last = now
+}
@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// the time that passes while a test runs is refilled at a token a second, it stays well under the tolerance
+const (
+	tokenTolerance = 0.1
+	waitTolerance  = 100 * time.Millisecond
+)
+
+// newBucket returns a bucket of a token a second that last refilled the age ago
+func newBucket(tokens float64, age time.Duration) *Bucket {
+	return &Bucket{
+		rate:     1,
+		capacity: 60,
+		tokens:   tokens,
+		last:     time.Now().Add(-age),
+	}
+}
+
+func TestBucketTake(t *testing.T) {
+	tests := []struct {
+		name       string
+		bucket     *Bucket
+		take       float64
+		wantWait   time.Duration
+		wantTokens float64
+	}{
+		{name: "unlimited", bucket: &Bucket{}, take: 1000},
+		{name: "enough tokens", bucket: newBucket(10, 0), take: 4, wantTokens: 6},
+		{name: "all the tokens", bucket: newBucket(10, 0), take: 10, wantTokens: 0},
+		{name: "not enough tokens", bucket: newBucket(2, 0), take: 5, wantWait: 3 * time.Second, wantTokens: 2},
+		{name: "refilled since the last take", bucket: newBucket(0, 10*time.Second), take: 4, wantTokens: 6},
+		{name: "refill stops at the capacity", bucket: newBucket(0, time.Hour), take: 1, wantTokens: 59},
+		{name: "more than the capacity once full", bucket: newBucket(60, 0), take: 100, wantTokens: -40},
+		{name: "more than the capacity waits to be full", bucket: newBucket(50, 0), take: 100, wantWait: 10 * time.Second, wantTokens: 50},
+		{name: "in debt", bucket: newBucket(-40, 0), take: 1, wantWait: 41 * time.Second, wantTokens: -40},
+		{name: "debt paid off by the refill", bucket: newBucket(-40, 45*time.Second), take: 1, wantTokens: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait := tt.bucket.take(tt.take)
+			if (wait - tt.wantWait).Abs() > waitTolerance {
+				t.Errorf("take(%g) = %s, want %s", tt.take, wait, tt.wantWait)
+			}
+
+			if math.Abs(tt.bucket.tokens-tt.wantTokens) > tokenTolerance {
+				t.Errorf("tokens = %g, want %g", tt.bucket.tokens, tt.wantTokens)
+			}
+		})
+	}
+}
+
+func TestBucketAdjust(t *testing.T) {
+	tests := []struct {
+		name       string
+		bucket     *Bucket
+		adjust     int
+		wantTokens float64
+	}{
+		{name: "unlimited", bucket: &Bucket{}, adjust: 10},
+		{name: "used more than estimated", bucket: newBucket(10, 0), adjust: 15, wantTokens: -5},
+		{name: "used less than estimated", bucket: newBucket(10, 0), adjust: -15, wantTokens: 25},
+		{name: "given back up to the capacity", bucket: newBucket(50, 0), adjust: -15, wantTokens: 60},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.bucket.Adjust(tt.adjust)
+			if math.Abs(tt.bucket.tokens-tt.wantTokens) > tokenTolerance {
+				t.Errorf("tokens = %g, want %g", tt.bucket.tokens, tt.wantTokens)
+			}
+		})
+	}
+}
+
+func TestBucketSetRate(t *testing.T) {
+	tests := []struct {
+		name         string
+		bucket       *Bucket
+		perMinute    int
+		wantRate     float64
+		wantCapacity float64
+		wantTokens   float64
+	}{
+		{name: "new limit starts full", bucket: &Bucket{}, perMinute: 120, wantRate: 2, wantCapacity: 120, wantTokens: 120},
+		{name: "raised limit keeps the tokens", bucket: newBucket(10, 0), perMinute: 120, wantRate: 2, wantCapacity: 120, wantTokens: 10},
+		{name: "lowered limit caps the tokens", bucket: newBucket(50, 0), perMinute: 30, wantRate: 0.5, wantCapacity: 30, wantTokens: 30},
+		{name: "debt is kept", bucket: newBucket(-20, 0), perMinute: 30, wantRate: 0.5, wantCapacity: 30, wantTokens: -20},
+		{name: "zero removes the limit", bucket: newBucket(10, 0), perMinute: 0, wantTokens: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.bucket.setRate(tt.perMinute)
+			if tt.bucket.rate != tt.wantRate || tt.bucket.capacity != tt.wantCapacity {
+				t.Errorf("rate = %g, capacity = %g, want %g, %g", tt.bucket.rate, tt.bucket.capacity, tt.wantRate, tt.wantCapacity)
+			}
+
+			if math.Abs(tt.bucket.tokens-tt.wantTokens) > tokenTolerance {
+				t.Errorf("tokens = %g, want %g", tt.bucket.tokens, tt.wantTokens)
+			}
+
+			if tt.perMinute == 0 && tt.bucket.take(1) != 0 {
+				t.Error("a bucket without a limit made the caller wait")
+			}
+		})
+	}
+}
+
+func TestBucketWaitCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := newBucket(-40, 0).Wait(ctx, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
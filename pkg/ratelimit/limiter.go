@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/pkg/metrics"
+)
+
+// Get returns the limiter of the provider, a provider that was not configured is not limited
+func Get(provider string) *Limiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	l, ok := limiters[provider]
+	if !ok {
+		l = &Limiter{
+			provider: provider,
+			requests: &Bucket{},
+			tokens:   &Bucket{},
+		}
+		limiters[provider] = l
+	}
+
+	return l
+}
+
+// Configure will set the requests and tokens per minute of the provider, zero removes the limit.  The clients
+// already created for the provider use the new rates.
+func Configure(provider string, requestsPerMinute, tokensPerMinute int) {
+	l := Get(provider)
+	l.requests.setRate(requestsPerMinute)
+	l.tokens.setRate(tokensPerMinute)
+}
+
+// Wait will block until a request can be sent to the provider or the context is done
+func (l *Limiter) Wait(ctx context.Context) error {
+	start := time.Now()
+	defer func() {
+		metrics.RateLimitWait.WithLabelValues(l.provider, "requests").Observe(time.Since(start).Seconds())
+	}()
+
+	err := l.waitPause(ctx)
+	if err != nil {
+		return err
+	}
+
+	return l.requests.Wait(ctx, 1)
+}
+
+// WaitTokens will block until the estimated number of tokens can be spent or the context is done.  The estimate
+// is corrected with AdjustTokens once the provider reports what was used.
+func (l *Limiter) WaitTokens(ctx context.Context, estimate int) error {
+	start := time.Now()
+	defer func() {
+		metrics.RateLimitWait.WithLabelValues(l.provider, "tokens").Observe(time.Since(start).Seconds())
+	}()
+
+	err := l.waitPause(ctx)
+	if err != nil {
+		return err
+	}
+
+	return l.tokens.Wait(ctx, estimate)
+}
+
+// AdjustTokens will take the tokens used beyond the estimate, or give back the ones that were not used
+func (l *Limiter) AdjustTokens(n int) {
+	l.tokens.Adjust(n)
+}
+
+// Pause will hold every request to the provider for the duration
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+func (l *Limiter) waitPause(ctx context.Context) error {
+	l.mu.Lock()
+	wait := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+)
+
+// Transport will wrap the round tripper so every request waits for the limiter of the provider.  A response with
+// a Retry-After pauses the provider, and the request is sent again once the pause is over.  A nil round tripper
+// uses http.DefaultTransport.
+func Transport(provider string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &transport{
+		limiter: Get(provider),
+		next:    next,
+	}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		err := t.limiter.Wait(req.Context())
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+			return resp, err
+		}
+
+		delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			return resp, nil
+		}
+
+		t.limiter.Pause(delay)
+		if attempt > maxRetries || delay > maxRetryAfter {
+			return resp, nil
+		}
+
+		// the body was read by the first attempt, it has to be read again from the start
+		retry := req.Clone(req.Context())
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, nil
+			}
+
+			retry.Body, err = req.GetBody()
+			if err != nil {
+				return resp, nil
+			}
+		}
+
+		logging.FromContext(req.Context()).Warn("The provider asked to be called again later",
+			"provider", t.limiter.provider,
+			"statusCode", resp.StatusCode,
+			"retryAfter", delay,
+			"attempt", attempt)
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		req = retry
+	}
+}
+
+// parseRetryAfter will read the Retry-After header, which is either a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return max(at.Sub(now), 0), true
+}
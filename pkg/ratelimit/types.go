@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The most times a request is sent again after the provider answered with a Retry-After
+const maxRetries = 3
+
+// The longest Retry-After that is waited out before sending the request again, the response of a longer one is
+// returned to the caller.  The limiter still pauses the provider for the whole time.
+const maxRetryAfter = 2 * time.Minute
+
+type (
+	// Bucket is a token bucket that refills at a steady rate up to one minute of tokens.  Taking more than is left
+	// puts it in debt and the callers after it wait until it has refilled.  A bucket without a rate never waits.
+	Bucket struct {
+		mu       sync.Mutex
+		rate     float64
+		capacity float64
+		tokens   float64
+		last     time.Time
+	}
+
+	// Limiter paces the requests sent to an external provider, and the tokens for a provider with a token quota.
+	// The provider can also be paused when it asks to be called again later.
+	Limiter struct {
+		provider string
+		requests *Bucket
+		tokens   *Bucket
+
+		mu          sync.Mutex
+		pausedUntil time.Time
+	}
+
+	// transport waits for the limiter before every request and honors the Retry-After of the responses
+	transport struct {
+		limiter *Limiter
+		next    http.RoundTripper
+	}
+)
+
+// the limiters are shared by every processor and pipeline that calls the provider
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*Limiter)
+)
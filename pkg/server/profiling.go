@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/KyleBrandon/scriptoria/pkg/auth"
+	"github.com/KyleBrandon/scriptoria/pkg/server/services/profiling"
+)
+
+// startProfiling will serve the profiling routes on their own address when profiling is enabled.  Every route
+// requires an admin token.  The returned function stops the server and waits for the running capture.
+func (cfg *ServerConfig) startProfiling() func() {
+	settings := cfg.Config.Profiling
+	if !settings.Enabled {
+		return func() {}
+	}
+
+	mux := http.NewServeMux()
+	h := profiling.NewHandler(cfg.ctx, mux, settings)
+
+	m := auth.NewMiddleware(cfg.queries, mux)
+	m.Protect("/debug/", auth.ScopeAdmin)
+
+	server := &http.Server{
+		Addr:    settings.Address,
+		Handler: m.Handler(mux),
+	}
+
+	go func() {
+		slog.Info("Starting the profiling server", "address", settings.Address)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Profiling server failed", "address", settings.Address, "error", err)
+		}
+	}()
+
+	return func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Failed to shutdown the profiling server", "error", err)
+		}
+
+		h.Wait()
+	}
+}
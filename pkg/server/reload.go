@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/pkg/document/manager"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+)
+
+// How often the config file is checked for changes
+const configPollInterval = 5 * time.Second
+
+// reloadConfig will read and validate the config file and apply it to the document manager.  The running
+// configuration is kept if the file is not valid.
+func (cfg *ServerConfig) reloadConfig() (manager.ReloadSummary, error) {
+	defer logging.Span("reloadConfig")()
+
+	settings, err := config.Load(cfg.ConfigFileLocation, manager.Registry())
+	if err != nil {
+		logConfigProblems(cfg.ConfigFileLocation, err)
+		return manager.ReloadSummary{}, err
+	}
+
+	return cfg.documentManager.Reload(settings)
+}
+
+// watchConfigFile will reload the configuration whenever the config file is modified
+func (cfg *ServerConfig) watchConfigFile(ctx context.Context) {
+	defer logging.SpanContext(ctx, "watchConfigFile")()
+
+	var lastModified time.Time
+	if info, err := os.Stat(cfg.ConfigFileLocation); err == nil {
+		lastModified = info.ModTime()
+	}
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			info, err := os.Stat(cfg.ConfigFileLocation)
+			if err != nil || info.ModTime().Equal(lastModified) {
+				continue
+			}
+
+			lastModified = info.ModTime()
+			slog.Info("The config file changed, reloading", "file", cfg.ConfigFileLocation)
+
+			// problems are logged, the running configuration is kept until the file is fixed
+			cfg.reloadConfig()
+		}
+	}
+}
+
+// logConfigProblems will log every problem found in the config file on its own line
+func logConfigProblems(location string, err error) {
+	var problems config.ValidationErrors
+	if !errors.As(err, &problems) {
+		slog.Error("Failed to load config file", "file", location, "error", err)
+		return
+	}
+
+	for _, p := range problems {
+		slog.Error("Invalid configuration", "file", location, "path", p.Path, "error", p.Message)
+	}
+}
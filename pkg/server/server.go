@@ -3,18 +3,32 @@ package server
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
-
-	_ "net/http/pprof"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/KyleBrandon/scriptoria/internal/config"
 	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/auth"
 	"github.com/KyleBrandon/scriptoria/pkg/document/manager"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/KyleBrandon/scriptoria/pkg/metrics"
+	"github.com/KyleBrandon/scriptoria/pkg/notify"
+	"github.com/KyleBrandon/scriptoria/pkg/server/services/bundles"
+	"github.com/KyleBrandon/scriptoria/pkg/server/services/configuration"
+	"github.com/KyleBrandon/scriptoria/pkg/server/services/costs"
+	"github.com/KyleBrandon/scriptoria/pkg/server/services/dashboard"
+	"github.com/KyleBrandon/scriptoria/pkg/server/services/documents"
 	"github.com/KyleBrandon/scriptoria/pkg/server/services/health"
+	"github.com/KyleBrandon/scriptoria/pkg/server/services/stream"
+	"github.com/KyleBrandon/scriptoria/pkg/tracing"
 	"github.com/KyleBrandon/scriptoria/pkg/utils"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq" // Import for side effects (PostgreSQL driver)
@@ -25,15 +39,20 @@ const (
 	DEFAULT_CONFIG_FILE_LOCATION = "./config/config.json"
 )
 
+// How long to wait for open connections to close once the documents have drained
+const serverShutdownTimeout = 10 * time.Second
+
 type ServerConfig struct {
 	ctx        context.Context
 	cancelFunc context.CancelFunc
 	mux        *http.ServeMux
+	draining   atomic.Bool
 
 	// environment settings
 	DatabaseURL        string
 	ServerPort         string
 	LogFileLocation    string
+	LogFormat          string
 	ConfigFileLocation string
 
 	// config file settings
@@ -47,20 +66,24 @@ type ServerConfig struct {
 	queries         *database.Queries
 	DBConnection    *sql.DB
 	documentManager *manager.DocumentManager
+	notifications   *notify.Dispatcher
+	documentLogs    *logging.Recorder
+	authorization   *auth.Middleware
 }
 
 // Used by "flag" to read command line argument
 var (
 	cmdLineFlagLogLevel string
+	cmdLineFlagMigrate  bool
 )
 
 func init() {
 	flag.StringVar(&cmdLineFlagLogLevel, "log_level", config.DefaultLogLevel.String(), "The log level to start the server at")
+	flag.BoolVar(&cmdLineFlagMigrate, "migrate", false, "Apply any pending database migrations at startup")
 }
 
 func InitializeServer() error {
-	slog.Debug(">>InitializeServer")
-	defer slog.Debug("<<InitializeServer")
+	defer logging.Span("InitializeServer")()
 
 	var err error
 
@@ -73,31 +96,129 @@ func InitializeServer() error {
 	cfg.openDatabase()
 	defer cfg.DBConnection.Close()
 
+	if cmdLineFlagMigrate {
+		err = cfg.migrateDatabase()
+		if err != nil {
+			return err
+		}
+	}
+
+	// start the trace exporter before anything creates spans
+	shutdownTracing, err := tracing.Initialize(context.Background(), cfg.Config.Tracing)
+	if err != nil {
+		slog.Error("Failed to initialize tracing", "error", err)
+		return err
+	}
+	defer shutdownTracing(context.Background())
+
 	cfg.mux = http.NewServeMux()
 	cfg.ctx, cfg.cancelFunc = context.WithCancel(context.Background())
 
+	// keep the log lines of every document so they can be read through the API
+	cfg.startDocumentLogs()
+
 	err = cfg.initializeStorageManager()
 	if err != nil {
 		return err
 	}
 
 	// initialize the health endpoint for the server
-	health.NewHandler(cfg.mux, cfg.LoggerLevel, cfg.Logger)
+	healthHandler := health.NewHandler(cfg.mux, cfg.LoggerLevel, cfg.Logger)
+	cfg.addReadinessChecks(healthHandler)
 
-	// start the profiler
-	go func() {
-		slog.Debug("Start profiling server")
-		err := http.ListenAndServe("localhost:6060", nil)
-		if err != nil {
-			slog.Info("Profiling server failed to start", "error", err)
-		}
-	}()
+	// expose the Prometheus metrics
+	metrics.RegisterRoutes(cfg.mux)
+
+	// reload the configuration when the file changes or when asked to
+	configuration.NewHandler(cfg.mux, cfg.reloadConfig)
+
+	// manage the bundles through the API, changes are applied to the running document manager
+	bundles.NewHandler(cfg.mux, cfg.queries, cfg.documentManager.ReloadBundles)
+	go cfg.watchConfigFile(cfg.ctx)
+
+	// browse, reprocess and cancel documents and follow their progress through the API and the dashboard built on it
+	documents.NewHandler(cfg.mux, cfg.queries, cfg.documentManager)
+	stream.NewHandler(cfg.mux, cfg.documentManager.Events())
+
+	// report what the documents used from Mathpix and OpenAI and what it cost at the configured prices
+	costs.NewHandler(cfg.mux, cfg.queries, func() config.PricesConfig {
+		return cfg.documentManager.Config().Prices
+	})
+	_, err = dashboard.NewHandler(cfg.mux)
+	if err != nil {
+		return err
+	}
+
+	// every /v1 route requires an API token, the routes are registered first so the middleware can match them
+	cfg.authorization = cfg.newAuthorization()
+
+	// serve pprof on its own address when it is enabled
+	stopProfiling := cfg.startProfiling()
 
 	cfg.runServer()
+	stopProfiling()
+	cfg.notifications.CancelAndWait()
+	cfg.documentLogs.CancelAndWait()
 
 	return nil
 }
 
+// startDocumentLogs will record the log lines that carry a document ID, unless it is disabled in the config
+func (cfg *ServerConfig) startDocumentLogs() {
+	defer logging.Span("startDocumentLogs")()
+
+	settings := cfg.Config.DocumentLogs
+	cfg.documentLogs = logging.NewRecorder(cfg.queries, settings.MaxPerDocument)
+	cfg.documentLogs.Start(cfg.ctx)
+
+	if settings.Disabled {
+		return
+	}
+
+	// the level was checked when the config was loaded
+	level, err := utils.ParseLogLevel(settings.Level)
+	if err != nil {
+		level = slog.LevelInfo
+	}
+
+	cfg.Logger = slog.New(logging.NewCaptureHandler(cfg.Logger.Handler(), cfg.documentLogs, level))
+	slog.SetDefault(cfg.Logger)
+}
+
+// newAuthorization will create the middleware that checks the API tokens.  The health checks are left open for
+// orchestrators, routes that change the server or its bundles require the admin scope.
+func (cfg *ServerConfig) newAuthorization() *auth.Middleware {
+	m := auth.NewMiddleware(cfg.queries, cfg.mux)
+	m.Exempt("GET /v1/health")
+	m.Exempt("GET /v1/ready")
+	for _, pattern := range cfg.documentManager.PublicRoutes() {
+		m.Exempt(pattern)
+	}
+
+	for _, pattern := range []string{
+		"PUT /v1/logger",
+		"POST /v1/config/reload",
+		"POST /v1/bundles",
+		"PUT /v1/bundles/{id}",
+		"DELETE /v1/bundles/{id}",
+		"POST /v1/bundles/{id}/enable",
+		"POST /v1/bundles/{id}/disable",
+	} {
+		m.Require(pattern, auth.ScopeAdmin)
+	}
+
+	return m
+}
+
+// addReadinessChecks will register the database and document pipeline dependencies with the readiness endpoint
+func (cfg *ServerConfig) addReadinessChecks(h *health.Handler) {
+	h.AddCheck("database", cfg.DBConnection.PingContext)
+
+	for name, check := range cfg.documentManager.ReadinessChecks() {
+		h.AddCheck(name, health.CheckFunc(check))
+	}
+}
+
 func (cfg *ServerConfig) initializeStorageManager() error {
 	dm, err := manager.New(cfg.ctx, cfg.queries, cfg.Config, cfg.mux)
 	if err != nil {
@@ -106,16 +227,20 @@ func (cfg *ServerConfig) initializeStorageManager() error {
 	}
 
 	cfg.documentManager = dm
+
+	// subscribe to the document events before any document starts
+	cfg.notifications = notify.NewDispatcher(cfg.queries, dm.Events(), dm.Config)
+	cfg.notifications.Start(cfg.ctx)
+
 	cfg.documentManager.StartMonitoring()
 
 	return nil
 }
 
-func initializeServerConfig() (ServerConfig, error) {
-	slog.Debug(">>initalizeServerConfig")
-	defer slog.Debug("<<initalizeServerConfig")
+func initializeServerConfig() (*ServerConfig, error) {
+	defer logging.Span("initalizeServerConfig")()
 
-	cfg := ServerConfig{}
+	cfg := &ServerConfig{}
 
 	// MUST BE FIRST FOR LOGGER
 	cfg.readEnvironmentVariables()
@@ -123,21 +248,20 @@ func initializeServerConfig() (ServerConfig, error) {
 	// configure slog
 	cfg.configureLogger()
 
-	// load the configuration file and environment settings
-	config, err := config.LoadConfigSettings(cfg.ConfigFileLocation)
+	// load the configuration file and report every problem with it before exiting
+	settings, err := config.Load(cfg.ConfigFileLocation, manager.Registry())
 	if err != nil {
-		slog.Error("Failed to load config file", "error", err)
+		logConfigProblems(cfg.ConfigFileLocation, err)
 		os.Exit(1)
 	}
 
-	cfg.Config = config
+	cfg.Config = settings
 
 	return cfg, nil
 }
 
 func (sc *ServerConfig) readEnvironmentVariables() {
-	slog.Debug(">>loadConfiguration")
-	defer slog.Debug("<<loadConfiguration")
+	defer logging.Span("loadConfiguration")()
 
 	// load the environment
 	err := godotenv.Load()
@@ -157,6 +281,7 @@ func (sc *ServerConfig) readEnvironmentVariables() {
 	}
 
 	sc.LogFileLocation = os.Getenv("LOG_FILE_LOCATION")
+	sc.LogFormat = os.Getenv("LOG_FORMAT")
 
 	sc.ConfigFileLocation = os.Getenv("CONFIG_FILE_LOCATION")
 	if len(sc.ConfigFileLocation) == 0 {
@@ -165,8 +290,7 @@ func (sc *ServerConfig) readEnvironmentVariables() {
 }
 
 func (sc *ServerConfig) configureLogger() {
-	slog.Debug(">>configureLogger")
-	defer slog.Debug("<<configureLogger")
+	defer logging.Span("configureLogger")()
 
 	// craete a variable to store the current log level
 	currentLevel := new(slog.LevelVar)
@@ -193,10 +317,14 @@ func (sc *ServerConfig) configureLogger() {
 
 	}
 
-	// create new text handler for log file
-	fileHandler := slog.NewTextHandler(logFile, &slog.HandlerOptions{Level: currentLevel})
+	// write the log as text unless JSON was asked for
+	handler, err := logging.NewHandler(logFile, sc.LogFormat, &slog.HandlerOptions{Level: currentLevel})
+	if err != nil {
+		slog.Error("Failed to create the log handler, writing text", "error", err, "log_format", sc.LogFormat)
+		handler = slog.NewTextHandler(logFile, &slog.HandlerOptions{Level: currentLevel})
+	}
 
-	logger := slog.New(fileHandler)
+	logger := slog.New(handler)
 
 	slog.SetDefault(logger)
 
@@ -205,22 +333,70 @@ func (sc *ServerConfig) configureLogger() {
 	sc.LogFile = logFile
 }
 
-// runServer will start listening for connections
+// runServer will start listening for connections and block until the server is signaled to stop
 func (config *ServerConfig) runServer() {
-	slog.Debug(">>runServer")
-	defer slog.Debug("<<runServer")
+	defer logging.Span("runServer")()
 
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%s", config.ServerPort),
-		Handler: config.mux,
+		Handler: config.rejectWhileDraining(config.authorization.Handler(config.mux)),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		slog.Info("Starting server", "port", config.ServerPort)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Server failed", "error", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	slog.Info("Shutting down the server")
+
+	// stop accepting webhooks and uploads, then give the documents in flight time to finish
+	config.draining.Store(true)
+	config.documentManager.Shutdown(config.documentManager.Config().ShutdownGracePeriod.Duration())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to shutdown the server", "error", err)
 	}
 
-	slog.Info("Starting server", "port", config.ServerPort)
-	if err := server.ListenAndServe(); err != nil {
-		slog.Error("Server failed", "error", err)
+	config.cancelFunc()
+}
+
+// rejectWhileDraining will refuse any request that could start new work once the server is shutting down.
+// Read only requests such as health checks are still served.
+func (config *ServerConfig) rejectWhileDraining(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if config.draining.Load() && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Retry-After", "30")
+			utils.RespondWithError(w, http.StatusServiceUnavailable, "Server is shutting down", nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// migrateDatabase will apply the migrations embedded in the binary
+func (config *ServerConfig) migrateDatabase() error {
+	results, err := database.MigrateUp(context.Background(), config.DBConnection)
+	if err != nil {
+		slog.Error("Failed to migrate the database", "error", err)
+		return err
+	}
+
+	for _, r := range results {
+		slog.Info("Applied database migration", "version", r.Source.Version, "file", r.Source.Path, "duration", r.Duration)
 	}
 
-	config.documentManager.CancelAndWait()
+	return nil
 }
 
 func (config *ServerConfig) openDatabase() {
@@ -0,0 +1,373 @@
+package bundles
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/KyleBrandon/scriptoria/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Postgres error code for a unique constraint violation
+const uniqueViolation = "23505"
+
+func NewHandler(mux *http.ServeMux, store BundleStore, apply ApplyFunc) *Handler {
+	h := &Handler{}
+	h.store = store
+	h.apply = apply
+	h.RegisterRoutes(mux)
+
+	return h
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /v1/bundles", h.handlerBundlesGet)
+	mux.HandleFunc("POST /v1/bundles", h.handlerBundlesPost)
+	mux.HandleFunc("GET /v1/bundles/{id}", h.handlerBundleGet)
+	mux.HandleFunc("PUT /v1/bundles/{id}", h.handlerBundlePut)
+	mux.HandleFunc("DELETE /v1/bundles/{id}", h.handlerBundleDelete)
+	mux.HandleFunc("POST /v1/bundles/{id}/enable", h.handlerBundleEnable)
+	mux.HandleFunc("POST /v1/bundles/{id}/disable", h.handlerBundleDisable)
+	mux.HandleFunc("GET /v1/watch-channels", h.handlerWatchChannelsGet)
+}
+
+func (h *Handler) handlerBundlesGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerBundlesGet")()
+
+	rows, err := h.store.ListBundles(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to list the bundles", err)
+		return
+	}
+
+	response := make([]bundleResponse, 0, len(rows))
+	for _, b := range rows {
+		response = append(response, toResponse(b))
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handlerBundlesPost(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerBundlesPost")()
+
+	req, ok := decodeBundleRequest(w, r)
+	if !ok {
+		return
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	b, err := h.store.CreateBundle(r.Context(), database.CreateBundleParams{
+		Name:                  req.Name,
+		SourceFolder:          req.SourceFolder,
+		ArchiveFolder:         req.ArchiveFolder,
+		DestAttachmentsFolder: req.DestAttachmentsFolder,
+		DestNotesFolder:       req.DestNotesFolder,
+		NotifyOn:              notifyOn(req.NotifyOn),
+		MathpixOptions:        mathpixOptions(req.Mathpix),
+		Enabled:               enabled,
+	})
+	if err != nil {
+		respondWithStoreError(w, "Failed to create the bundle", err)
+		return
+	}
+
+	h.respondApplied(w, http.StatusCreated, b)
+}
+
+func (h *Handler) handlerBundleGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerBundleGet")()
+
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	b, err := h.store.GetBundleById(r.Context(), id)
+	if err != nil {
+		respondWithStoreError(w, "Failed to find the bundle", err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, toResponse(b))
+}
+
+func (h *Handler) handlerBundlePut(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerBundlePut")()
+
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := decodeBundleRequest(w, r)
+	if !ok {
+		return
+	}
+
+	b, err := h.store.UpdateBundle(r.Context(), database.UpdateBundleParams{
+		ID:                    id,
+		Name:                  req.Name,
+		SourceFolder:          req.SourceFolder,
+		ArchiveFolder:         req.ArchiveFolder,
+		DestAttachmentsFolder: req.DestAttachmentsFolder,
+		DestNotesFolder:       req.DestNotesFolder,
+		NotifyOn:              notifyOn(req.NotifyOn),
+		MathpixOptions:        mathpixOptions(req.Mathpix),
+	})
+	if err != nil {
+		respondWithStoreError(w, "Failed to update the bundle", err)
+		return
+	}
+
+	if req.Enabled != nil && *req.Enabled != b.Enabled {
+		b, err = h.store.SetBundleEnabled(r.Context(), database.SetBundleEnabledParams{ID: id, Enabled: *req.Enabled})
+		if err != nil {
+			respondWithStoreError(w, "Failed to update the bundle", err)
+			return
+		}
+	}
+
+	h.respondApplied(w, http.StatusOK, b)
+}
+
+func (h *Handler) handlerBundleDelete(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerBundleDelete")()
+
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := h.store.GetBundleById(r.Context(), id)
+	if err != nil {
+		respondWithStoreError(w, "Failed to find the bundle", err)
+		return
+	}
+
+	err = h.store.DeleteBundle(r.Context(), id)
+	if err != nil {
+		respondWithStoreError(w, "Failed to delete the bundle", err)
+		return
+	}
+
+	if _, err = h.apply(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "The bundle was deleted but the change could not be applied", err)
+		return
+	}
+
+	utils.RespondWithNoContent(w, http.StatusNoContent)
+}
+
+func (h *Handler) handlerBundleEnable(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerBundleEnable")()
+
+	h.setEnabled(w, r, true)
+}
+
+func (h *Handler) handlerBundleDisable(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerBundleDisable")()
+
+	h.setEnabled(w, r, false)
+}
+
+func (h *Handler) setEnabled(w http.ResponseWriter, r *http.Request, enabled bool) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	b, err := h.store.SetBundleEnabled(r.Context(), database.SetBundleEnabledParams{ID: id, Enabled: enabled})
+	if err != nil {
+		respondWithStoreError(w, "Failed to update the bundle", err)
+		return
+	}
+
+	h.respondApplied(w, http.StatusOK, b)
+}
+
+func (h *Handler) handlerWatchChannelsGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerWatchChannelsGet")()
+
+	rows, err := h.store.ListBundles(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to list the bundles", err)
+		return
+	}
+
+	folders := make([]string, 0, len(rows))
+	for _, b := range rows {
+		folders = append(folders, b.SourceFolder)
+	}
+
+	channels, err := h.store.GetWatchEntriesByFolderIDs(r.Context(), folders)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to list the watch channels", err)
+		return
+	}
+
+	response := make([]watchChannelResponse, 0, len(rows))
+	for _, b := range rows {
+		item := watchChannelResponse{
+			BundleID:     b.ID,
+			BundleName:   b.Name,
+			SourceFolder: b.SourceFolder,
+			Enabled:      b.Enabled,
+			State:        "missing",
+		}
+
+		for _, c := range channels {
+			if c.ResourceID != b.SourceFolder {
+				continue
+			}
+
+			expiresAt := time.UnixMilli(c.ExpiresAt)
+			item.ChannelID = c.ChannelID
+			item.ExpiresAt = &expiresAt
+			item.WebhookURL = c.WebhookUrl
+			item.State = "active"
+			if time.Now().After(expiresAt) {
+				item.State = "expired"
+			}
+		}
+
+		response = append(response, item)
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// respondApplied will apply the bundle change to the running storage and processors before responding
+func (h *Handler) respondApplied(w http.ResponseWriter, code int, b database.Bundle) {
+	_, err := h.apply()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "The bundle was saved but the change could not be applied", err)
+		return
+	}
+
+	utils.RespondWithJSON(w, code, toResponse(b))
+}
+
+// decodeBundleRequest will read the bundle from the body and validate it, responding with every problem found
+func decodeBundleRequest(w http.ResponseWriter, r *http.Request) (bundleRequest, bool) {
+	var req bundleRequest
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid bundle", err)
+		return req, false
+	}
+
+	b := config.StorageBundle{
+		Name:                  req.Name,
+		SourceFolder:          req.SourceFolder,
+		ArchiveFolder:         req.ArchiveFolder,
+		DestAttachmentsFolder: req.DestAttachmentsFolder,
+		DestNotesFolder:       req.DestNotesFolder,
+		NotifyOn:              req.NotifyOn,
+		Mathpix:               req.Mathpix,
+	}
+
+	var problems config.ValidationErrors
+	if len(req.Name) == 0 {
+		problems = append(problems, config.ValidationError{Path: "name", Message: "is required"})
+	}
+
+	err = b.Validate()
+	if err != nil {
+		var bundleProblems config.ValidationErrors
+		if !errors.As(err, &bundleProblems) {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid bundle", err)
+			return req, false
+		}
+
+		problems = append(problems, bundleProblems...)
+	}
+
+	if len(problems) != 0 {
+		response := struct {
+			Error    string                  `json:"error"`
+			Problems config.ValidationErrors `json:"problems"`
+		}{
+			Error:    "The bundle is not valid",
+			Problems: problems,
+		}
+
+		utils.RespondWithJSON(w, http.StatusUnprocessableEntity, response)
+		return req, false
+	}
+
+	return req, true
+}
+
+func parseID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid bundle ID", err)
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+// respondWithStoreError will map the database errors to the HTTP status the client can act on
+func respondWithStoreError(w http.ResponseWriter, message string, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Bundle not found", err)
+		return
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		utils.RespondWithError(w, http.StatusConflict, "A bundle with the same name or source folder already exists", err)
+		return
+	}
+
+	utils.RespondWithError(w, http.StatusInternalServerError, message, err)
+}
+
+func toResponse(b database.Bundle) bundleResponse {
+	return bundleResponse{
+		ID:                    b.ID,
+		CreatedAt:             b.CreatedAt,
+		UpdatedAt:             b.UpdatedAt,
+		Name:                  b.Name,
+		SourceFolder:          b.SourceFolder,
+		ArchiveFolder:         b.ArchiveFolder,
+		DestAttachmentsFolder: b.DestAttachmentsFolder,
+		DestNotesFolder:       b.DestNotesFolder,
+		NotifyOn:              notifyOn(b.NotifyOn),
+		Mathpix:               json.RawMessage(b.MathpixOptions),
+		Enabled:               b.Enabled,
+		Source:                b.Source,
+	}
+}
+
+// notifyOn will use an empty list for a bundle that does not send notifications, a nil list would be stored as NULL
+func notifyOn(events []string) []string {
+	if events == nil {
+		return []string{}
+	}
+
+	return events
+}
+
+// mathpixOptions will encode the options to store with the bundle, they only hold strings, booleans and lists so
+// encoding them can not fail
+func mathpixOptions(options config.MathpixOptions) string {
+	data, _ := json.Marshal(options)
+	return string(data)
+}
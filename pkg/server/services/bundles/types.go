@@ -0,0 +1,71 @@
+package bundles
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/document/manager"
+	"github.com/google/uuid"
+)
+
+// ApplyFunc applies the enabled bundles in the database to the storage watchers and processors
+type ApplyFunc func() (manager.ReloadSummary, error)
+
+// BundleStore is used to access the bundles in the database
+type BundleStore interface {
+	CreateBundle(ctx context.Context, arg database.CreateBundleParams) (database.Bundle, error)
+	UpdateBundle(ctx context.Context, arg database.UpdateBundleParams) (database.Bundle, error)
+	SetBundleEnabled(ctx context.Context, arg database.SetBundleEnabledParams) (database.Bundle, error)
+	DeleteBundle(ctx context.Context, id uuid.UUID) error
+	GetBundleById(ctx context.Context, id uuid.UUID) (database.Bundle, error)
+	ListBundles(ctx context.Context) ([]database.Bundle, error)
+	GetWatchEntriesByFolderIDs(ctx context.Context, resourceIds []string) ([]database.GoogleDriveWatch, error)
+}
+
+type Handler struct {
+	store BundleStore
+	apply ApplyFunc
+}
+
+// bundleRequest is the body used to create or update a bundle
+type bundleRequest struct {
+	Name                  string                `json:"name"`
+	SourceFolder          string                `json:"source_folder"`
+	ArchiveFolder         string                `json:"archive_folder"`
+	DestAttachmentsFolder string                `json:"dest_attachments_folder"`
+	DestNotesFolder       string                `json:"dest_notes_folder"`
+	NotifyOn              []string              `json:"notify_on"`
+	Mathpix               config.MathpixOptions `json:"mathpix"`
+	Enabled               *bool                 `json:"enabled"`
+}
+
+// bundleResponse is a bundle as it is returned by the API
+type bundleResponse struct {
+	ID                    uuid.UUID       `json:"id"`
+	CreatedAt             time.Time       `json:"created_at"`
+	UpdatedAt             time.Time       `json:"updated_at"`
+	Name                  string          `json:"name"`
+	SourceFolder          string          `json:"source_folder"`
+	ArchiveFolder         string          `json:"archive_folder"`
+	DestAttachmentsFolder string          `json:"dest_attachments_folder"`
+	DestNotesFolder       string          `json:"dest_notes_folder"`
+	NotifyOn              []string        `json:"notify_on"`
+	Mathpix               json.RawMessage `json:"mathpix"`
+	Enabled               bool            `json:"enabled"`
+	Source                string          `json:"source"`
+}
+
+// watchChannelResponse is the Google Drive watch channel of a bundle folder, the state is active, expired or missing
+type watchChannelResponse struct {
+	BundleID     uuid.UUID  `json:"bundle_id"`
+	BundleName   string     `json:"bundle_name"`
+	SourceFolder string     `json:"source_folder"`
+	Enabled      bool       `json:"enabled"`
+	ChannelID    string     `json:"channel_id,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	WebhookURL   string     `json:"webhook_url,omitempty"`
+	State        string     `json:"state"`
+}
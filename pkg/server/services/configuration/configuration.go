@@ -0,0 +1,58 @@
+package configuration
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/pkg/document/manager"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/KyleBrandon/scriptoria/pkg/utils"
+)
+
+func NewHandler(mux *http.ServeMux, reload ReloadFunc) *Handler {
+	h := &Handler{}
+	h.reload = reload
+	h.RegisterRoutes(mux)
+
+	return h
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /v1/config/reload", h.handlerReloadPost)
+}
+
+func (h *Handler) handlerReloadPost(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerReloadPost")()
+
+	summary, err := h.reload()
+	if err != nil {
+		// report every problem so they can be fixed at once, the running configuration is kept
+		var problems config.ValidationErrors
+		if errors.As(err, &problems) {
+			response := struct {
+				Error    string                  `json:"error"`
+				Problems config.ValidationErrors `json:"problems"`
+			}{
+				Error:    "The configuration is not valid",
+				Problems: problems,
+			}
+
+			utils.RespondWithJSON(w, http.StatusUnprocessableEntity, response)
+			return
+		}
+
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reload the configuration", err)
+		return
+	}
+
+	response := struct {
+		Status string `json:"status"`
+		manager.ReloadSummary
+	}{
+		Status:        "reloaded",
+		ReloadSummary: summary,
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
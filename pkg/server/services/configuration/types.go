@@ -0,0 +1,12 @@
+package configuration
+
+import (
+	"github.com/KyleBrandon/scriptoria/pkg/document/manager"
+)
+
+// ReloadFunc reads the configuration file, validates it and applies it to the running server
+type ReloadFunc func() (manager.ReloadSummary, error)
+
+type Handler struct {
+	reload ReloadFunc
+}
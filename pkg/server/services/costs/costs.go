@@ -0,0 +1,100 @@
+package costs
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/KyleBrandon/scriptoria/pkg/usage"
+	"github.com/KyleBrandon/scriptoria/pkg/utils"
+	"github.com/google/uuid"
+)
+
+func NewHandler(mux *http.ServeMux, store UsageStore, prices PricesFunc) *Handler {
+	h := &Handler{}
+	h.store = store
+	h.prices = prices
+	h.RegisterRoutes(mux)
+
+	return h
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /v1/usage", h.handlerUsageGet)
+	mux.HandleFunc("GET /v1/documents/{id}/usage", h.handlerDocumentUsageGet)
+}
+
+// handlerUsageGet will report the cost of each bundle per month, ?from=YYYY-MM&to=YYYY-MM picks the months and
+// ?bundle= the bundle by name or source folder
+func (h *Handler) handlerUsageGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerUsageGet")()
+
+	query := r.URL.Query()
+	from, to, err := usage.MonthRange(query.Get("from"), query.Get("to"), time.Now())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	rows, err := h.store.GetUsageReport(r.Context(), database.GetUsageReportParams{FromTime: from, ToTime: to})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read the usage", err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, usage.NewReport(usage.FilterBundle(rows, query.Get("bundle")), h.prices(), from, to))
+}
+
+func (h *Handler) handlerDocumentUsageGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerDocumentUsageGet")()
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid document ID", err)
+		return
+	}
+
+	_, err = h.store.GetDocumentById(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Document not found", err)
+		return
+	}
+
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to find the document", err)
+		return
+	}
+
+	rows, err := h.store.GetDocumentUsageByDocumentId(r.Context(), id)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read the usage of the document", err)
+		return
+	}
+
+	prices := h.prices()
+	response := documentUsageResponse{
+		DocumentID: id,
+		Currency:   prices.Currency,
+		Items:      make([]usageItemResponse, 0, len(rows)),
+	}
+
+	for _, u := range rows {
+		cost := usage.Cost(prices, u.Provider, u.Unit, u.Amount)
+		response.Cost += cost
+		response.Items = append(response.Items, usageItemResponse{
+			CreatedAt: u.CreatedAt,
+			Stage:     u.Stage,
+			Provider:  u.Provider,
+			Unit:      u.Unit,
+			Amount:    u.Amount,
+			Cost:      usage.RoundCost(cost),
+		})
+	}
+
+	response.Cost = usage.RoundCost(response.Cost)
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,43 @@
+package costs
+
+import (
+	"context"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/google/uuid"
+)
+
+// PricesFunc returns the prices of the running configuration, so a reload changes the report
+type PricesFunc func() config.PricesConfig
+
+// UsageStore is used to read what the documents used from the providers
+type UsageStore interface {
+	GetUsageReport(ctx context.Context, arg database.GetUsageReportParams) ([]database.GetUsageReportRow, error)
+	GetDocumentUsageByDocumentId(ctx context.Context, documentID uuid.UUID) ([]database.DocumentUsage, error)
+	GetDocumentById(ctx context.Context, id uuid.UUID) (database.Document, error)
+}
+
+type Handler struct {
+	store  UsageStore
+	prices PricesFunc
+}
+
+// documentUsageResponse is what a document used from the providers and what it cost
+type documentUsageResponse struct {
+	DocumentID uuid.UUID           `json:"document_id"`
+	Currency   string              `json:"currency"`
+	Cost       float64             `json:"cost"`
+	Items      []usageItemResponse `json:"items"`
+}
+
+// usageItemResponse is what a stage used from a provider
+type usageItemResponse struct {
+	CreatedAt time.Time `json:"created_at"`
+	Stage     string    `json:"stage"`
+	Provider  string    `json:"provider"`
+	Unit      string    `json:"unit"`
+	Amount    int64     `json:"amount"`
+	Cost      float64   `json:"cost"`
+}
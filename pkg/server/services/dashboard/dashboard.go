@@ -0,0 +1,35 @@
+package dashboard
+
+import (
+	"io/fs"
+	"log/slog"
+	"net/http"
+
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+)
+
+func NewHandler(mux *http.ServeMux) (*Handler, error) {
+	files, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		slog.Error("Failed to read the dashboard files", "error", err)
+		return nil, err
+	}
+
+	h := &Handler{}
+	h.files = files
+	h.RegisterRoutes(mux)
+
+	return h, nil
+}
+
+// RegisterRoutes will serve the dashboard under /dashboard/, the page only calls the REST API of the server
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.Handle("GET /dashboard/", http.StripPrefix("/dashboard/", http.FileServerFS(h.files)))
+	mux.HandleFunc("GET /{$}", h.handlerRootGet)
+}
+
+func (h *Handler) handlerRootGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerRootGet")()
+
+	http.Redirect(w, r, "/dashboard/", http.StatusFound)
+}
@@ -0,0 +1,577 @@
+// Scriptoria dashboard, a single page that only uses the REST API of the server.
+"use strict";
+
+// the views are refreshed when an event arrives, polling only catches what the stream can not report
+const POLL_INTERVAL_MS = 30000;
+const EVENT_REFRESH_DELAY_MS = 300;
+
+// the API token is kept in the browser so the dashboard does not ask for it on every visit
+const TOKEN_KEY = "scriptoria.token";
+
+const state = {
+  view: "documents",
+  documentID: null,
+  offset: 0,
+  limit: 50,
+};
+
+// --- API -------------------------------------------------------------------
+
+class UnauthorizedError extends Error {}
+
+function apiToken() {
+  return localStorage.getItem(TOKEN_KEY) || "";
+}
+
+// withToken adds the token to the URL of requests that can not set a header, the event stream and the PDF
+function withToken(path) {
+  const token = apiToken();
+  if (!token) {
+    return path;
+  }
+  return path + (path.includes("?") ? "&" : "?") + "access_token=" + encodeURIComponent(token);
+}
+
+async function api(method, path, body) {
+  const options = { method, headers: {} };
+  if (apiToken()) {
+    options.headers["Authorization"] = "Bearer " + apiToken();
+  }
+  if (body !== undefined) {
+    options.headers["Content-Type"] = "application/json";
+    options.body = JSON.stringify(body);
+  }
+
+  const response = await fetch(path, options);
+  if (response.status === 401) {
+    throw new UnauthorizedError("An API token is required");
+  }
+  if (!response.ok) {
+    let message = response.status + " " + response.statusText;
+    try {
+      const data = await response.json();
+      if (data.error) {
+        message = data.error;
+      }
+    } catch (e) {
+      // the body was not JSON, keep the status text
+    }
+    throw new Error(message);
+  }
+
+  if (response.status === 202 || response.status === 204) {
+    return null;
+  }
+
+  const type = response.headers.get("Content-Type") || "";
+  return type.startsWith("application/json") ? response.json() : response.text();
+}
+
+// --- helpers ---------------------------------------------------------------
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    if (key === "class") {
+      node.className = value;
+    } else if (key.startsWith("on")) {
+      node.addEventListener(key.slice(2), value);
+    } else if (value !== undefined && value !== null && value !== false) {
+      node.setAttribute(key, value);
+    }
+  }
+
+  for (const child of children.flat()) {
+    if (child === null || child === undefined) {
+      continue;
+    }
+    node.append(child instanceof Node ? child : document.createTextNode(String(child)));
+  }
+
+  return node;
+}
+
+function formatTime(value) {
+  if (!value) {
+    return "-";
+  }
+  return new Date(value).toLocaleString();
+}
+
+function formatDuration(ms) {
+  if (ms === undefined || ms === null) {
+    return "-";
+  }
+  return ms < 1000 ? ms + "ms" : (ms / 1000).toFixed(1) + "s";
+}
+
+function showMessage(text, isError) {
+  const box = document.getElementById("message");
+  box.textContent = text;
+  box.className = "message" + (isError ? " error" : "");
+  box.hidden = false;
+  clearTimeout(showMessage.timer);
+  showMessage.timer = setTimeout(() => { box.hidden = true; }, 4000);
+}
+
+function statusClass(status) {
+  const s = (status || "").toLowerCase();
+  if (s === "failed" || s.includes("error") || s.includes("fail")) {
+    return "status failed";
+  }
+  if (s.includes("cancel")) {
+    return "status canceled";
+  }
+  if (s === "succeeded" || s.includes("finished") || s.includes("complete")) {
+    return "status ok";
+  }
+  return "status";
+}
+
+// --- Markdown --------------------------------------------------------------
+
+function escapeHTML(text) {
+  return text.replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;").replace(/"/g, "&quot;");
+}
+
+function renderInline(text) {
+  return escapeHTML(text)
+    .replace(/`([^`]+)`/g, "<code>$1</code>")
+    .replace(/!\[([^\]]*)\]\(([^)\s]+)\)/g, '<span class="image">[image: $1]</span>')
+    .replace(/\[([^\]]+)\]\((https?:[^)\s]+)\)/g, '<a href="$2" target="_blank" rel="noopener">$1</a>')
+    .replace(/\*\*([^*]+)\*\*/g, "<strong>$1</strong>")
+    .replace(/\*([^*]+)\*/g, "<em>$1</em>");
+}
+
+// renderMarkdown covers the Markdown the pipeline produces: headings, lists, quotes, code blocks and paragraphs.
+function renderMarkdown(source) {
+  const lines = source.replace(/\r\n/g, "\n").split("\n");
+  const html = [];
+  let paragraph = [];
+  let list = null;
+  let code = null;
+
+  const flushParagraph = () => {
+    if (paragraph.length) {
+      html.push("<p>" + renderInline(paragraph.join(" ")) + "</p>");
+      paragraph = [];
+    }
+  };
+  const flushList = () => {
+    if (list) {
+      html.push("<" + list.tag + ">" + list.items.map((i) => "<li>" + renderInline(i) + "</li>").join("") + "</" + list.tag + ">");
+      list = null;
+    }
+  };
+
+  for (const line of lines) {
+    if (code !== null) {
+      if (line.startsWith("```")) {
+        html.push("<pre><code>" + escapeHTML(code.join("\n")) + "</code></pre>");
+        code = null;
+      } else {
+        code.push(line);
+      }
+      continue;
+    }
+
+    if (line.startsWith("```")) {
+      flushParagraph();
+      flushList();
+      code = [];
+      continue;
+    }
+
+    const heading = line.match(/^(#{1,6})\s+(.*)$/);
+    const bullet = line.match(/^\s*[-*+]\s+(.*)$/);
+    const numbered = line.match(/^\s*\d+[.)]\s+(.*)$/);
+
+    if (heading) {
+      flushParagraph();
+      flushList();
+      const level = heading[1].length;
+      html.push("<h" + level + ">" + renderInline(heading[2]) + "</h" + level + ">");
+    } else if (bullet || numbered) {
+      flushParagraph();
+      const tag = bullet ? "ul" : "ol";
+      if (!list || list.tag !== tag) {
+        flushList();
+        list = { tag, items: [] };
+      }
+      list.items.push((bullet || numbered)[1]);
+    } else if (line.startsWith(">")) {
+      flushParagraph();
+      flushList();
+      html.push("<blockquote>" + renderInline(line.replace(/^>\s?/, "")) + "</blockquote>");
+    } else if (/^\s*(---|\*\*\*)\s*$/.test(line)) {
+      flushParagraph();
+      flushList();
+      html.push("<hr>");
+    } else if (line.trim() === "") {
+      flushParagraph();
+      flushList();
+    } else {
+      flushList();
+      paragraph.push(line.trim());
+    }
+  }
+
+  if (code !== null) {
+    html.push("<pre><code>" + escapeHTML(code.join("\n")) + "</code></pre>");
+  }
+  flushParagraph();
+  flushList();
+
+  return html.join("\n");
+}
+
+// --- shared panels ---------------------------------------------------------
+
+async function refreshReadiness() {
+  const badge = document.getElementById("readiness");
+  try {
+    // the report lists the failed components with a 503 so it is read directly
+    const response = await fetch("/v1/ready");
+    const data = await response.json();
+    const failed = Object.entries(data.components || {})
+      .filter(([, c]) => c.status !== "ok")
+      .map(([name, c]) => name + ": " + (c.error || c.status));
+
+    badge.textContent = response.ok ? "ready" : "not ready";
+    badge.className = "badge " + (response.ok ? "ok" : "failed");
+    badge.title = failed.length ? failed.join("\n") : "All components are ready";
+  } catch (e) {
+    badge.textContent = "unreachable";
+    badge.className = "badge failed";
+    badge.title = e.message;
+  }
+}
+
+async function refreshPipeline() {
+  const container = document.getElementById("stages");
+  try {
+    const pipeline = await api("GET", "/v1/pipeline");
+    const byStage = {};
+    for (const doc of pipeline.in_flight) {
+      (byStage[doc.stage || pipeline.stages[0]] ||= []).push(doc);
+    }
+
+    // a stage whose provider budget is used up holds its documents until the budget resets
+    document.getElementById("paused").replaceChildren(...pipeline.paused.map((p) =>
+      el("p", { class: "error" }, "Paused: " + p.message)));
+
+    container.replaceChildren(...pipeline.stages.map((stage) =>
+      el("div", { class: "stage" },
+        el("div", { class: "stage-name" }, stage),
+        el("div", { class: "stage-docs" },
+          (byStage[stage] || []).map((doc) =>
+            el("a", { href: "#/documents/" + doc.id, class: statusClass(doc.status), title: doc.status + " since " + formatTime(doc.since) },
+              doc.source_name))))));
+  } catch (e) {
+    container.replaceChildren(el("div", { class: "error" }, "Failed to load the pipeline: " + e.message));
+  }
+}
+
+// --- views -----------------------------------------------------------------
+
+async function reprocess(id, stage) {
+  try {
+    await api("POST", "/v1/documents/" + id + "/reprocess", stage ? { stage } : {});
+    showMessage("Reprocessing started");
+    refresh();
+  } catch (e) {
+    showMessage(e.message, true);
+  }
+}
+
+async function cancel(id) {
+  try {
+    await api("POST", "/v1/documents/" + id + "/cancel");
+    showMessage("Processing canceled");
+    refresh();
+  } catch (e) {
+    showMessage(e.message, true);
+  }
+}
+
+function documentActions(doc) {
+  return doc.in_flight
+    ? el("button", { onclick: () => cancel(doc.id) }, "Cancel")
+    : el("button", { onclick: () => reprocess(doc.id) }, "Reprocess");
+}
+
+async function renderDocuments(failedOnly) {
+  const query = "?limit=" + state.limit + "&offset=" + state.offset + (failedOnly ? "&status=failed" : "");
+  const docs = await api("GET", "/v1/documents" + query);
+
+  const rows = docs.map((doc) =>
+    el("tr", {},
+      el("td", {}, el("a", { href: "#/documents/" + doc.id }, doc.source_name)),
+      el("td", {}, formatTime(doc.created_at)),
+      el("td", {}, formatTime(doc.processed_at)),
+      el("td", { class: statusClass(doc.status) }, doc.in_flight ? "processing: " + doc.status : doc.status),
+      el("td", {}, documentActions(doc))));
+
+  const pager = el("div", { class: "pager" },
+    el("button", { disabled: state.offset === 0, onclick: () => { state.offset = Math.max(0, state.offset - state.limit); refresh(); } }, "Previous"),
+    el("span", {}, (state.offset + 1) + " - " + (state.offset + docs.length)),
+    el("button", { disabled: docs.length < state.limit, onclick: () => { state.offset += state.limit; refresh(); } }, "Next"));
+
+  return el("section", { class: "panel" },
+    el("h2", {}, failedOnly ? "Failed Documents" : "Documents"),
+    docs.length === 0
+      ? el("p", {}, failedOnly ? "No failed documents." : "No documents yet.")
+      : el("table", {},
+        el("thead", {}, el("tr", {}, el("th", {}, "Name"), el("th", {}, "Created"), el("th", {}, "Processed"), el("th", {}, "Status"), el("th", {}, ""))),
+        el("tbody", {}, rows)),
+    pager);
+}
+
+async function renderDocumentDetail(id) {
+  const [doc, pipeline, logs, usage] = await Promise.all([
+    api("GET", "/v1/documents/" + id),
+    api("GET", "/v1/pipeline"),
+    api("GET", "/v1/documents/" + id + "/logs"),
+    api("GET", "/v1/documents/" + id + "/usage"),
+  ]);
+
+  const stageSelect = el("select", {}, pipeline.stages.map((s) => el("option", { value: s }, s)));
+  const actions = doc.in_flight
+    ? el("div", { class: "actions" }, el("button", { onclick: () => cancel(doc.id) }, "Cancel"))
+    : el("div", { class: "actions" },
+      el("label", {}, "Reprocess from ", stageSelect),
+      el("button", { onclick: () => reprocess(doc.id, stageSelect.value) }, "Reprocess"));
+
+  const timeline = el("table", { class: "timeline" },
+    el("thead", {}, el("tr", {}, el("th", {}, "Time"), el("th", {}, "Stage"), el("th", {}, "Status"), el("th", {}, "Attempt"), el("th", {}, "Duration"), el("th", {}, "Error"))),
+    el("tbody", {}, doc.events.map((e) =>
+      el("tr", {},
+        el("td", {}, formatTime(e.created_at)),
+        el("td", {}, e.stage),
+        el("td", { class: statusClass(e.status) }, e.status),
+        el("td", {}, e.attempt),
+        el("td", {}, formatDuration(e.duration_ms)),
+        el("td", { class: "error" }, e.error || "")))));
+
+  const log = el("table", { class: "log" },
+    el("thead", {}, el("tr", {}, el("th", {}, "Time"), el("th", {}, "Level"), el("th", {}, "Message"), el("th", {}, "Attributes"))),
+    el("tbody", {}, logs.map((l) =>
+      el("tr", {},
+        el("td", {}, formatTime(l.time)),
+        el("td", { class: l.level === "ERROR" ? "error" : "" }, l.level),
+        el("td", {}, l.message),
+        el("td", { class: "attrs" }, Object.entries(l.attrs).map(([k, v]) => k + "=" + v).join(" "))))));
+
+  const info = el("section", { class: "panel", id: "detail-info" },
+    el("h2", {}, doc.source_name),
+    el("dl", {},
+      el("dt", {}, "ID"), el("dd", {}, doc.id),
+      el("dt", {}, "Source"), el("dd", {}, doc.source_store + " " + doc.source_id),
+      el("dt", {}, "Created"), el("dd", {}, formatTime(doc.created_at)),
+      el("dt", {}, "Processed"), el("dd", {}, formatTime(doc.processed_at)),
+      el("dt", {}, "Status"), el("dd", { class: statusClass(doc.status) }, (doc.in_flight ? "processing: " : "") + doc.status),
+      el("dt", {}, "Usage"), el("dd", {}, usage.items.length
+        ? usage.items.map((u) => u.amount + " " + u.provider + " " + u.unit.replace("_", " ")).join(", ") + " (" + usage.cost.toFixed(4) + " " + usage.currency + ")"
+        : "none")),
+    actions,
+    el("h3", {}, "Timeline"),
+    doc.events.length ? timeline : el("p", {}, "No stages have run yet."),
+    el("h3", {}, "Log"),
+    logs.length ? log : el("p", {}, "Nothing was logged for this document."));
+
+  // the document panes are only loaded once, moving the iframe would reload the PDF on every poll
+  const panes = document.getElementById("detail-panes");
+  if (panes && panes.dataset.id === id) {
+    document.getElementById("detail-info").replaceWith(info);
+    return null;
+  }
+
+  const markdown = el("div", { class: "markdown" }, "Loading…");
+  api("GET", "/v1/documents/" + id + "/result")
+    .then((text) => { markdown.innerHTML = renderMarkdown(text); })
+    .catch((e) => { markdown.textContent = e.message; });
+
+  return [info, el("section", { class: "panes", id: "detail-panes", "data-id": id },
+    el("div", { class: "pane" }, el("h3", {}, "Original"), el("iframe", { src: withToken("/v1/documents/" + id + "/original"), title: "Original PDF" })),
+    el("div", { class: "pane" }, el("h3", {}, "Markdown"), markdown))];
+}
+
+async function renderBundles() {
+  const bundles = await api("GET", "/v1/bundles");
+
+  const toggle = async (b) => {
+    try {
+      await api("POST", "/v1/bundles/" + b.id + (b.enabled ? "/disable" : "/enable"));
+      showMessage(b.enabled ? "Bundle disabled" : "Bundle enabled");
+      refresh();
+    } catch (e) {
+      showMessage(e.message, true);
+    }
+  };
+
+  return el("section", { class: "panel" },
+    el("h2", {}, "Bundles"),
+    bundles.length === 0
+      ? el("p", {}, "No bundles are configured.")
+      : el("table", {},
+        el("thead", {}, el("tr", {}, el("th", {}, "Name"), el("th", {}, "Source Folder"), el("th", {}, "Archive Folder"), el("th", {}, "Notes"), el("th", {}, "Attachments"), el("th", {}, "Enabled"), el("th", {}, ""))),
+        el("tbody", {}, bundles.map((b) =>
+          el("tr", {},
+            el("td", {}, b.name),
+            el("td", {}, b.source_folder),
+            el("td", {}, b.archive_folder),
+            el("td", {}, b.dest_notes_folder),
+            el("td", {}, b.dest_attachments_folder),
+            el("td", { class: b.enabled ? "status ok" : "status" }, b.enabled ? "yes" : "no"),
+            el("td", {}, el("button", { onclick: () => toggle(b) }, b.enabled ? "Disable" : "Enable")))))));
+}
+
+async function renderChannels() {
+  const channels = await api("GET", "/v1/watch-channels");
+
+  const stateClass = { active: "status ok", expired: "status failed", missing: "status canceled" };
+
+  return el("section", { class: "panel" },
+    el("h2", {}, "Watch Channels"),
+    channels.length === 0
+      ? el("p", {}, "No bundles are configured.")
+      : el("table", {},
+        el("thead", {}, el("tr", {}, el("th", {}, "Bundle"), el("th", {}, "Folder"), el("th", {}, "Channel"), el("th", {}, "Expires"), el("th", {}, "State"), el("th", {}, "Webhook"))),
+        el("tbody", {}, channels.map((c) =>
+          el("tr", {},
+            el("td", {}, c.bundle_name + (c.enabled ? "" : " (disabled)")),
+            el("td", {}, c.source_folder),
+            el("td", {}, c.channel_id || "-"),
+            el("td", {}, formatTime(c.expires_at)),
+            el("td", { class: stateClass[c.state] }, c.state),
+            el("td", {}, c.webhook_url || "-"))))));
+}
+
+// --- routing ---------------------------------------------------------------
+
+function route() {
+  const parts = location.hash.replace(/^#\/?/, "").split("/");
+  const view = parts[0] || "documents";
+
+  if (view !== state.view) {
+    state.offset = 0;
+  }
+
+  state.view = view;
+  state.documentID = view === "documents" && parts[1] ? parts[1] : null;
+
+  for (const link of document.querySelectorAll("nav a")) {
+    link.classList.toggle("active", link.dataset.view === view && !state.documentID);
+  }
+
+  refresh();
+}
+
+async function refresh() {
+  const container = document.getElementById("view");
+  refreshReadiness();
+  refreshPipeline();
+
+  try {
+    let content;
+    if (state.documentID) {
+      content = await renderDocumentDetail(state.documentID);
+    } else if (state.view === "failed") {
+      content = await renderDocuments(true);
+    } else if (state.view === "bundles") {
+      content = await renderBundles();
+    } else if (state.view === "channels") {
+      content = await renderChannels();
+    } else {
+      content = await renderDocuments(false);
+    }
+
+    if (content !== null) {
+      container.replaceChildren(...[content].flat());
+    }
+  } catch (e) {
+    if (e instanceof UnauthorizedError) {
+      container.replaceChildren(renderSignIn());
+      return;
+    }
+    container.replaceChildren(el("section", { class: "panel error" }, "Failed to load: " + e.message));
+  }
+}
+
+// renderSignIn asks for the API token, a read token is enough to browse and write is needed to reprocess or cancel
+function renderSignIn() {
+  const input = el("input", { type: "password", placeholder: "scr_…", autocomplete: "off", size: 50 });
+  const form = el("form", {
+    onsubmit: (e) => {
+      e.preventDefault();
+      localStorage.setItem(TOKEN_KEY, input.value.trim());
+      connectEvents();
+      refresh();
+    },
+  }, input, el("button", { type: "submit" }, "Sign in"));
+
+  const message = apiToken() ? "The stored API token was not accepted." : "Enter an API token to use the dashboard.";
+  return el("section", { class: "panel" }, el("h2", {}, "Sign in"), el("p", {}, message), form,
+    el("p", {}, "Create one with ", el("code", {}, "scriptoria token create --name dashboard --scopes write"), "."));
+}
+
+function signOut() {
+  localStorage.removeItem(TOKEN_KEY);
+  connectEvents();
+  refresh();
+}
+
+// refreshSoon will refresh once for a burst of events
+function refreshSoon() {
+  clearTimeout(refreshSoon.timer);
+  refreshSoon.timer = setTimeout(refreshIfIdle, EVENT_REFRESH_DELAY_MS);
+}
+
+function refreshIfIdle() {
+  // do not redraw while the user is picking a stage
+  if (document.activeElement && document.activeElement.tagName === "SELECT") {
+    return;
+  }
+  refresh();
+}
+
+function connectEvents() {
+  const live = document.getElementById("live");
+  if (connectEvents.source) {
+    connectEvents.source.close();
+  }
+
+  const source = new EventSource(withToken("/v1/events"));
+  connectEvents.source = source;
+
+  source.onopen = () => {
+    live.textContent = "live";
+    live.className = "badge ok";
+  };
+
+  // the browser reconnects on its own and sends the last event ID to catch up
+  source.onerror = () => {
+    live.textContent = "reconnecting";
+    live.className = "badge failed";
+  };
+
+  for (const type of ["detected", "stage_started", "stage_finished", "failed", "archived", "canceled"]) {
+    source.addEventListener(type, refreshSoon);
+  }
+
+  // reload the Markdown of the open document once it has been processed again
+  source.addEventListener("completed", (message) => {
+    const event = JSON.parse(message.data);
+    const panes = document.getElementById("detail-panes");
+    if (panes && panes.dataset.id === event.document_id) {
+      panes.remove();
+    }
+    refreshSoon();
+  });
+}
+
+document.getElementById("sign-out").addEventListener("click", signOut);
+window.addEventListener("hashchange", route);
+setInterval(refreshIfIdle, POLL_INTERVAL_MS);
+connectEvents();
+route();
@@ -0,0 +1,15 @@
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// the pages of the dashboard are compiled into the binary
+//
+//go:embed static
+var staticFiles embed.FS
+
+type Handler struct {
+	files fs.FS
+}
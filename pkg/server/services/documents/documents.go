@@ -0,0 +1,433 @@
+package documents
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/document/manager"
+	"github.com/KyleBrandon/scriptoria/pkg/document/processor"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/KyleBrandon/scriptoria/pkg/utils"
+	"github.com/google/uuid"
+)
+
+func NewHandler(mux *http.ServeMux, store DocumentStore, controller DocumentController) *Handler {
+	h := &Handler{}
+	h.store = store
+	h.controller = controller
+	h.RegisterRoutes(mux)
+
+	return h
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /v1/documents", h.handlerDocumentsGet)
+	mux.HandleFunc("GET /v1/documents/{id}", h.handlerDocumentGet)
+	mux.HandleFunc("POST /v1/documents/{id}/reprocess", h.handlerDocumentReprocess)
+	mux.HandleFunc("POST /v1/documents/{id}/cancel", h.handlerDocumentCancel)
+	mux.HandleFunc("GET /v1/documents/{id}/original", h.handlerDocumentOriginalGet)
+	mux.HandleFunc("GET /v1/documents/{id}/result", h.handlerDocumentResultGet)
+	mux.HandleFunc("GET /v1/documents/{id}/notifications", h.handlerDocumentNotificationsGet)
+	mux.HandleFunc("GET /v1/documents/{id}/logs", h.handlerDocumentLogsGet)
+	mux.HandleFunc("GET /v1/pipeline", h.handlerPipelineGet)
+}
+
+func (h *Handler) handlerDocumentsGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerDocumentsGet")()
+
+	limit, err := queryInt(r, "limit", defaultListLimit)
+	if err != nil || limit < 1 || limit > maxListLimit {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("The limit must be between 1 and %d", maxListLimit), err)
+		return
+	}
+
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "The offset must not be negative", err)
+		return
+	}
+
+	var docs []database.Document
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+		docs, err = h.store.ListDocuments(r.Context(), database.ListDocumentsParams{Limit: int32(limit), Offset: int32(offset)})
+	case processor.EventStatusFailed:
+		docs, err = h.store.ListFailedDocuments(r.Context(), database.ListFailedDocumentsParams{Limit: int32(limit), Offset: int32(offset)})
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, "Unknown status filter, expected failed", fmt.Errorf("invalid status: %s", status))
+		return
+	}
+
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to list the documents", err)
+		return
+	}
+
+	inFlight := h.controller.InFlight()
+	response := make([]documentResponse, 0, len(docs))
+	for _, d := range docs {
+		response = append(response, toResponse(d, inFlight))
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handlerDocumentGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerDocumentGet")()
+
+	doc, ok := h.findDocument(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := h.store.GetDocumentEventsByDocumentId(r.Context(), doc.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read the document history", err)
+		return
+	}
+
+	response := documentDetailResponse{
+		documentResponse: toResponse(doc, h.controller.InFlight()),
+		Events:           make([]eventResponse, 0, len(events)),
+	}
+
+	for _, e := range events {
+		response.Events = append(response.Events, toEventResponse(e))
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handlerDocumentReprocess(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerDocumentReprocess")()
+
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	// the body is optional, without it the document starts at the first stage
+	var req reprocessRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid reprocess request", err)
+		return
+	}
+
+	err = h.controller.ReprocessDocument(id, req.Stage)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		utils.RespondWithError(w, http.StatusNotFound, "Document not found", err)
+	case errors.Is(err, manager.ErrStageNotFound):
+		message := fmt.Sprintf("Unknown stage, expected one of %s", strings.Join(h.controller.Stages(), ", "))
+		utils.RespondWithError(w, http.StatusBadRequest, message, err)
+	case errors.Is(err, manager.ErrDocumentInFlight):
+		utils.RespondWithError(w, http.StatusConflict, "The document is already being processed", err)
+	case err != nil:
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reprocess the document", err)
+	default:
+		utils.RespondWithNoContent(w, http.StatusAccepted)
+	}
+}
+
+func (h *Handler) handlerDocumentCancel(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerDocumentCancel")()
+
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.controller.CancelDocument(id)
+	if errors.Is(err, manager.ErrDocumentNotInFlight) {
+		utils.RespondWithError(w, http.StatusConflict, "The document is not being processed", err)
+		return
+	}
+
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to cancel the document", err)
+		return
+	}
+
+	utils.RespondWithNoContent(w, http.StatusAccepted)
+}
+
+func (h *Handler) handlerDocumentOriginalGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerDocumentOriginalGet")()
+
+	// the first stage stores the source document unchanged
+	h.respondWithArtifact(w, r, "application/pdf", func(events []database.DocumentEvent) int {
+		return slices.IndexFunc(events, hasArtifact)
+	})
+}
+
+func (h *Handler) handlerDocumentResultGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerDocumentResultGet")()
+
+	// the last stage that produced output holds the finished Markdown
+	h.respondWithArtifact(w, r, "text/markdown; charset=utf-8", func(events []database.DocumentEvent) int {
+		for i := len(events) - 1; i >= 0; i-- {
+			if hasArtifact(events[i]) {
+				return i
+			}
+		}
+
+		return -1
+	})
+}
+
+func (h *Handler) handlerDocumentNotificationsGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerDocumentNotificationsGet")()
+
+	doc, ok := h.findDocument(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.store.GetNotificationDeliveriesByDocumentId(r.Context(), doc.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read the notification deliveries", err)
+		return
+	}
+
+	response := make([]deliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		item := deliveryResponse{
+			ID:         d.ID,
+			CreatedAt:  d.CreatedAt,
+			UpdatedAt:  d.UpdatedAt,
+			Event:      d.EventType,
+			Target:     d.TargetName,
+			TargetType: d.TargetType,
+			Status:     d.Status,
+			Attempts:   d.Attempts,
+			LastError:  d.LastError.String,
+		}
+
+		if d.DeliveredAt.Valid {
+			item.DeliveredAt = &d.DeliveredAt.Time
+		}
+
+		response = append(response, item)
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handlerDocumentLogsGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerDocumentLogsGet")()
+
+	// only return the lines at or above the level when it is passed
+	minLevel := slog.LevelDebug
+	if value := r.URL.Query().Get("level"); len(value) != 0 {
+		level, err := utils.ParseLogLevel(value)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Unknown level, expected debug, info, warn or error", err)
+			return
+		}
+		minLevel = level
+	}
+
+	doc, ok := h.findDocument(w, r)
+	if !ok {
+		return
+	}
+
+	logs, err := h.store.GetDocumentLogsByDocumentId(r.Context(), doc.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read the document log", err)
+		return
+	}
+
+	response := make([]logResponse, 0, len(logs))
+	for _, l := range logs {
+		var level slog.Level
+		if level.UnmarshalText([]byte(l.Level)) == nil && level < minLevel {
+			continue
+		}
+
+		attrs := json.RawMessage(l.Attrs)
+		if !json.Valid(attrs) {
+			attrs = json.RawMessage("{}")
+		}
+
+		response = append(response, logResponse{
+			Time:    l.LoggedAt,
+			Level:   l.Level,
+			Message: l.Message,
+			Attrs:   attrs,
+		})
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handlerPipelineGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerPipelineGet")()
+
+	response := pipelineResponse{
+		Stages:   h.controller.Stages(),
+		InFlight: make([]inFlightResponse, 0),
+		Paused:   make([]pausedResponse, 0),
+	}
+
+	for _, p := range h.controller.Paused() {
+		response.Paused = append(response.Paused, pausedResponse{BudgetError: p, Message: p.Error()})
+	}
+
+	for _, id := range h.controller.InFlight() {
+		doc, err := h.store.GetDocumentById(r.Context(), id)
+		if err != nil {
+			slog.Warn("Failed to read the document in flight", "id", id, "error", err)
+			continue
+		}
+
+		item := inFlightResponse{ID: id, SourceName: doc.SourceName}
+
+		// the latest event is the stage the document is in
+		events, err := h.store.GetDocumentEventsByDocumentId(r.Context(), id)
+		if err == nil && len(events) != 0 {
+			last := events[len(events)-1]
+			item.Stage = last.Stage
+			item.Status = last.Status
+			item.Since = &last.CreatedAt
+		}
+
+		response.InFlight = append(response.InFlight, item)
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// respondWithArtifact will write the artifact of the event picked from the document history
+func (h *Handler) respondWithArtifact(w http.ResponseWriter, r *http.Request, contentType string, pick func([]database.DocumentEvent) int) {
+	doc, ok := h.findDocument(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := h.store.GetDocumentEventsByDocumentId(r.Context(), doc.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read the document history", err)
+		return
+	}
+
+	i := pick(events)
+	if i < 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "The document has no stored output", fmt.Errorf("no artifact for document %s", doc.ID))
+		return
+	}
+
+	reader, err := h.controller.OpenArtifact(events[i].ArtifactHash.String)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "The stored output is no longer available", err)
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+
+	_, err = io.Copy(w, reader)
+	if err != nil {
+		slog.Warn("Failed to write the artifact", "id", doc.ID, "stage", events[i].Stage, "error", err)
+	}
+}
+
+func (h *Handler) findDocument(w http.ResponseWriter, r *http.Request) (database.Document, bool) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return database.Document{}, false
+	}
+
+	doc, err := h.store.GetDocumentById(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Document not found", err)
+		return doc, false
+	}
+
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to find the document", err)
+		return doc, false
+	}
+
+	return doc, true
+}
+
+func hasArtifact(e database.DocumentEvent) bool {
+	return e.Status == processor.EventStatusSucceeded && e.ArtifactHash.Valid
+}
+
+func parseID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid document ID", err)
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+func queryInt(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if len(value) == 0 {
+		return defaultValue, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+func toResponse(d database.Document, inFlight []uuid.UUID) documentResponse {
+	response := documentResponse{
+		ID:             d.ID,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+		SourceStore:    d.SourceStore,
+		SourceID:       d.SourceID,
+		SourceName:     d.SourceName,
+		SourceFolderID: d.SourceFolderID,
+		Status:         d.ProcessingStatus.String,
+		ResumeStage:    d.ResumeStage.String,
+		InFlight:       slices.Contains(inFlight, d.ID),
+	}
+
+	if d.ProcessedAt.Valid {
+		response.ProcessedAt = &d.ProcessedAt.Time
+	}
+
+	return response
+}
+
+func toEventResponse(e database.DocumentEvent) eventResponse {
+	response := eventResponse{
+		CreatedAt:    e.CreatedAt,
+		Stage:        e.Stage,
+		Status:       e.Status,
+		Attempt:      e.Attempt,
+		Error:        e.ErrorMessage.String,
+		ArtifactHash: e.ArtifactHash.String,
+	}
+
+	if e.DurationMs.Valid {
+		response.DurationMs = &e.DurationMs.Int64
+	}
+
+	if e.BytesIn.Valid {
+		response.BytesIn = &e.BytesIn.Int64
+	}
+
+	if e.BytesOut.Valid {
+		response.BytesOut = &e.BytesOut.Int64
+	}
+
+	return response
+}
@@ -0,0 +1,126 @@
+package documents
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/usage"
+	"github.com/google/uuid"
+)
+
+// Default and largest number of documents returned by a single list request
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// DocumentStore is used to read the documents and their history from the database
+type DocumentStore interface {
+	ListDocuments(ctx context.Context, arg database.ListDocumentsParams) ([]database.Document, error)
+	ListFailedDocuments(ctx context.Context, arg database.ListFailedDocumentsParams) ([]database.Document, error)
+	GetDocumentById(ctx context.Context, id uuid.UUID) (database.Document, error)
+	GetDocumentEventsByDocumentId(ctx context.Context, documentID uuid.UUID) ([]database.DocumentEvent, error)
+	GetNotificationDeliveriesByDocumentId(ctx context.Context, documentID uuid.UUID) ([]database.NotificationDelivery, error)
+	GetDocumentLogsByDocumentId(ctx context.Context, documentID uuid.UUID) ([]database.DocumentLog, error)
+}
+
+// DocumentController is used to act on the documents in the running pipeline
+type DocumentController interface {
+	Stages() []string
+	InFlight() []uuid.UUID
+	ReprocessDocument(id uuid.UUID, stage string) error
+	CancelDocument(id uuid.UUID) error
+	OpenArtifact(hash string) (io.ReadCloser, error)
+	Paused() []usage.BudgetError
+}
+
+type Handler struct {
+	store      DocumentStore
+	controller DocumentController
+}
+
+// documentResponse is a document as it is returned by the API
+type documentResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	SourceStore    string     `json:"source_store"`
+	SourceID       string     `json:"source_id"`
+	SourceName     string     `json:"source_name"`
+	SourceFolderID string     `json:"source_folder_id"`
+	ProcessedAt    *time.Time `json:"processed_at,omitempty"`
+	Status         string     `json:"status"`
+	ResumeStage    string     `json:"resume_stage,omitempty"`
+	InFlight       bool       `json:"in_flight"`
+}
+
+// documentDetailResponse is a document with the history of every stage it went through
+type documentDetailResponse struct {
+	documentResponse
+	Events []eventResponse `json:"events"`
+}
+
+// eventResponse is a single stage transition of a document
+type eventResponse struct {
+	CreatedAt    time.Time `json:"created_at"`
+	Stage        string    `json:"stage"`
+	Status       string    `json:"status"`
+	Attempt      int32     `json:"attempt"`
+	Error        string    `json:"error,omitempty"`
+	DurationMs   *int64    `json:"duration_ms,omitempty"`
+	BytesIn      *int64    `json:"bytes_in,omitempty"`
+	BytesOut     *int64    `json:"bytes_out,omitempty"`
+	ArtifactHash string    `json:"artifact_hash,omitempty"`
+}
+
+// deliveryResponse is an entry of the notification delivery log
+type deliveryResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Event       string     `json:"event"`
+	Target      string     `json:"target"`
+	TargetType  string     `json:"target_type"`
+	Status      string     `json:"status"`
+	Attempts    int32      `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+// logResponse is a line of the document log, the attributes are the ones it was logged with
+type logResponse struct {
+	Time    time.Time       `json:"time"`
+	Level   string          `json:"level"`
+	Message string          `json:"message"`
+	Attrs   json.RawMessage `json:"attrs"`
+}
+
+// reprocessRequest is the optional body of a reprocess request, the first stage is used if it is empty
+type reprocessRequest struct {
+	Stage string `json:"stage"`
+}
+
+// pipelineResponse is the live state of the pipeline
+type pipelineResponse struct {
+	Stages   []string           `json:"stages"`
+	InFlight []inFlightResponse `json:"in_flight"`
+	Paused   []pausedResponse   `json:"paused"`
+}
+
+// pausedResponse is a provider whose budget is used up, the stage that calls it waits until the budget resets
+type pausedResponse struct {
+	usage.BudgetError
+	Message string `json:"message"`
+}
+
+// inFlightResponse is a document in the pipeline and the stage it last reached
+type inFlightResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	SourceName string     `json:"source_name"`
+	Stage      string     `json:"stage,omitempty"`
+	Status     string     `json:"status,omitempty"`
+	Since      *time.Time `json:"since,omitempty"`
+}
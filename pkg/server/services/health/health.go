@@ -1,17 +1,22 @@
 package health
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
 	"github.com/KyleBrandon/scriptoria/pkg/utils"
 )
 
 func NewHandler(mux *http.ServeMux, levelVar *slog.LevelVar, logger *slog.Logger) *Handler {
 	h := &Handler{}
+	h.checks = make(map[string]CheckFunc)
 	h.logger = logger
 	h.levelVar = levelVar
 	h.RegisterRoutes(mux)
@@ -21,13 +26,13 @@ func NewHandler(mux *http.ServeMux, levelVar *slog.LevelVar, logger *slog.Logger
 
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /v1/health", h.handlerHealthGet)
+	mux.HandleFunc("GET /v1/ready", h.handlerReadyGet)
 	mux.HandleFunc("GET /v1/logger", h.handlerLoggerGet)
 	mux.HandleFunc("PUT /v1/logger", h.handlerLoggerUpdate)
 }
 
 func (h *Handler) handlerHealthGet(w http.ResponseWriter, r *http.Request) {
-	slog.Debug(">>handlerGetHealth")
-	defer slog.Debug("<<handlerGetHealth")
+	defer logging.SpanContext(r.Context(), "handlerGetHealth")()
 
 	response := struct {
 		Status string `json:"status"`
@@ -38,9 +43,80 @@ func (h *Handler) handlerHealthGet(w http.ResponseWriter, r *http.Request) {
 	utils.RespondWithJSON(w, http.StatusOK, response)
 }
 
+// AddCheck will add a component to the readiness report
+func (h *Handler) AddCheck(name string, check CheckFunc) {
+	h.checksMu.Lock()
+	defer h.checksMu.Unlock()
+
+	h.checks[name] = check
+}
+
+func (h *Handler) handlerReadyGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerReadyGet")()
+
+	h.checksMu.RLock()
+	checks := make(map[string]CheckFunc, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	h.checksMu.RUnlock()
+
+	// run the checks at the same time so a slow component does not hold up the others
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	components := make(map[string]componentStatus, len(checks))
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(ctx)
+
+			status := componentStatus{
+				Status:     "ok",
+				DurationMs: time.Since(start).Milliseconds(),
+			}
+
+			if err != nil {
+				slog.Warn("Readiness check failed", "component", name, "error", err)
+				status.Status = "failed"
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			components[name] = status
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	response := struct {
+		Status     string                     `json:"status"`
+		Components map[string]componentStatus `json:"components"`
+	}{
+		Status:     "ok",
+		Components: components,
+	}
+
+	code := http.StatusOK
+	for _, c := range components {
+		if c.Status != "ok" {
+			response.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	utils.RespondWithJSON(w, code, response)
+}
+
 func (h *Handler) handlerLoggerGet(w http.ResponseWriter, r *http.Request) {
-	slog.Debug(">>handlerLoggerGet")
-	defer slog.Debug("<<handlerLoggerGet")
+	defer logging.SpanContext(r.Context(), "handlerLoggerGet")()
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -57,8 +133,7 @@ func (h *Handler) handlerLoggerGet(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) handlerLoggerUpdate(w http.ResponseWriter, r *http.Request) {
-	slog.Debug(">>handlerLoggerUpdate")
-	defer slog.Debug("<<handlerLoggerUpdate")
+	defer logging.SpanContext(r.Context(), "handlerLoggerUpdate")()
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -1,12 +1,30 @@
 package health
 
 import (
+	"context"
 	"log/slog"
 	"sync"
+	"time"
 )
 
+// How long a single readiness check can take before it is considered failed
+const readinessCheckTimeout = 5 * time.Second
+
+// CheckFunc reports whether a component the server depends on is ready
+type CheckFunc func(ctx context.Context) error
+
 type Handler struct {
 	logger   *slog.Logger
 	levelVar *slog.LevelVar
 	mu       sync.RWMutex
+
+	checksMu sync.RWMutex
+	checks   map[string]CheckFunc
+}
+
+// componentStatus is the readiness of a single component
+type componentStatus struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
 }
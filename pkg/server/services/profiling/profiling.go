@@ -0,0 +1,269 @@
+package profiling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	rpprof "runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/KyleBrandon/scriptoria/pkg/utils"
+)
+
+// NewHandler will serve the pprof routes and the routes that capture profiles to the profiles folder.  The
+// captures still running are stopped when the context is canceled.
+func NewHandler(ctx context.Context, mux *http.ServeMux, settings config.ProfilingConfig) *Handler {
+	h := &Handler{
+		ctx:      ctx,
+		settings: settings,
+	}
+	h.RegisterRoutes(mux)
+
+	return h
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("POST /debug/profiles", h.handlerProfilePost)
+	mux.HandleFunc("GET /debug/profiles", h.handlerProfilesGet)
+	mux.HandleFunc("GET /debug/profiles/{name}", h.handlerProfileGet)
+}
+
+// Wait for the running capture to finish writing its profile
+func (h *Handler) Wait() {
+	h.wg.Wait()
+}
+
+// handlerProfilePost will start capturing a profile in the background and return the files it is written to
+func (h *Handler) handlerProfilePost(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerProfilePost")()
+
+	var req captureRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid profile request", err)
+		return
+	}
+
+	if req.Duration == 0 {
+		req.Duration = config.Duration(defaultCaptureDuration)
+	}
+
+	if req.Duration < 0 || req.Duration > h.settings.MaxDuration {
+		message := fmt.Sprintf("The duration must be between 0 and %s", h.settings.MaxDuration.Duration())
+		utils.RespondWithError(w, http.StatusBadRequest, message, nil)
+		return
+	}
+
+	if req.Type != ProfileCPU && req.Type != ProfileHeap {
+		message := fmt.Sprintf("Unknown profile type %q, expected %s or %s", req.Type, ProfileCPU, ProfileHeap)
+		utils.RespondWithError(w, http.StatusBadRequest, message, nil)
+		return
+	}
+
+	if !h.capturing.CompareAndSwap(false, true) {
+		utils.RespondWithError(w, http.StatusConflict, "A profile is already being captured", nil)
+		return
+	}
+
+	err = os.MkdirAll(h.settings.Folder, 0755)
+	if err != nil {
+		h.capturing.Store(false)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create the profiles folder", err)
+		return
+	}
+
+	name := fmt.Sprintf("%s-%s", req.Type, time.Now().Format(fileTimeFormat))
+	var files []string
+	if req.Type == ProfileCPU {
+		files, err = h.captureCPU(name, req.Duration.Duration())
+	} else {
+		files, err = h.captureHeap(name, req.Duration.Duration())
+	}
+
+	if err != nil {
+		h.capturing.Store(false)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to start the profile", err)
+		return
+	}
+
+	slog.Info("Capturing a profile", "type", req.Type, "duration", req.Duration.Duration(), "files", files)
+
+	utils.RespondWithJSON(w, http.StatusAccepted, captureResponse{
+		Type:     req.Type,
+		Duration: req.Duration,
+		Files:    files,
+	})
+}
+
+// captureCPU will start the CPU profiler and stop it once the duration has passed
+func (h *Handler) captureCPU(name string, duration time.Duration) ([]string, error) {
+	file, err := os.Create(filepath.Join(h.settings.Folder, name+fileExtension))
+	if err != nil {
+		return nil, err
+	}
+
+	// fails when a profile is being served from /debug/pprof/profile
+	err = rpprof.StartCPUProfile(file)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		defer h.capturing.Store(false)
+
+		h.sleep(duration)
+		rpprof.StopCPUProfile()
+
+		err := file.Close()
+		if err != nil {
+			slog.Error("Failed to write the CPU profile", "file", file.Name(), "error", err)
+			return
+		}
+
+		slog.Info("Captured the CPU profile", "file", file.Name())
+	}()
+
+	return []string{filepath.Base(file.Name())}, nil
+}
+
+// captureHeap will write the heap at the start and at the end of the duration, the start is used as the base
+// to see what was allocated in between
+func (h *Handler) captureHeap(name string, duration time.Duration) ([]string, error) {
+	start := name + "-start" + fileExtension
+	end := name + "-end" + fileExtension
+
+	err := h.writeHeap(start)
+	if err != nil {
+		return nil, err
+	}
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		defer h.capturing.Store(false)
+
+		h.sleep(duration)
+
+		err := h.writeHeap(end)
+		if err != nil {
+			slog.Error("Failed to write the heap profile", "file", end, "error", err)
+			return
+		}
+
+		slog.Info("Captured the heap profile", "base", start, "file", end)
+	}()
+
+	return []string{start, end}, nil
+}
+
+func (h *Handler) writeHeap(name string) error {
+	file, err := os.Create(filepath.Join(h.settings.Folder, name))
+	if err != nil {
+		return err
+	}
+
+	// collect the garbage so the profile shows what is still in use
+	runtime.GC()
+	err = rpprof.Lookup("heap").WriteTo(file, 0)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// sleep returns early when the server is shutting down so the profile is still written
+func (h *Handler) sleep(duration time.Duration) {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-h.ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// handlerProfilesGet will list the profiles in the profiles folder, the newest first
+func (h *Handler) handlerProfilesGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerProfilesGet")()
+
+	entries, err := os.ReadDir(h.settings.Folder)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read the profiles folder", err)
+		return
+	}
+
+	response := make([]profileResponse, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), fileExtension) {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		response = append(response, profileResponse{
+			Name:      e.Name(),
+			Size:      info.Size(),
+			CreatedAt: info.ModTime(),
+		})
+	}
+
+	sort.Slice(response, func(i, j int) bool {
+		return response[i].CreatedAt.After(response[j].CreatedAt)
+	})
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerProfileGet will download a profile so it can be opened with go tool pprof
+func (h *Handler) handlerProfileGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerProfileGet")()
+
+	name := r.PathValue("name")
+	if name != filepath.Base(name) || !strings.HasSuffix(name, fileExtension) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid profile name", nil)
+		return
+	}
+
+	file, err := os.Open(filepath.Join(h.settings.Folder, name))
+	if errors.Is(err, os.ErrNotExist) {
+		utils.RespondWithError(w, http.StatusNotFound, "Profile not found", err)
+		return
+	} else if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to open the profile", err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, file)
+}
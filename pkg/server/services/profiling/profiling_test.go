@@ -0,0 +1,101 @@
+package profiling
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	rpprof "runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+)
+
+func TestProfilePostStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		setup func(t *testing.T, h *Handler)
+		want  int
+	}{
+		{
+			name: "heap capture",
+			body: `{"type": "heap", "duration": "1ms"}`,
+			want: http.StatusAccepted,
+		},
+		{
+			name: "unknown type",
+			body: `{"type": "block"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "duration over the maximum",
+			body: `{"type": "heap", "duration": "2h"}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name:  "capture already running",
+			body:  `{"type": "heap", "duration": "1ms"}`,
+			setup: func(t *testing.T, h *Handler) { h.capturing.Store(true) },
+			want:  http.StatusConflict,
+		},
+		{
+			name: "profiles folder can not be created",
+			body: `{"type": "heap", "duration": "1ms"}`,
+			setup: func(t *testing.T, h *Handler) {
+				// the profiles folder would be under a file
+				file := filepath.Join(h.settings.Folder, "file")
+				err := os.WriteFile(file, nil, 0o644)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				h.settings.Folder = filepath.Join(file, "profiles")
+			},
+			want: http.StatusInternalServerError,
+		},
+		{
+			name: "CPU profiler fails to start",
+			body: `{"type": "cpu", "duration": "1ms"}`,
+			setup: func(t *testing.T, h *Handler) {
+				// the CPU profiler is already running for someone else
+				err := rpprof.StartCPUProfile(io.Discard)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(rpprof.StopCPUProfile)
+			},
+			want: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			h := NewHandler(context.Background(), mux, config.ProfilingConfig{
+				Folder:      t.TempDir(),
+				MaxDuration: config.Duration(time.Hour),
+			})
+
+			if tt.setup != nil {
+				tt.setup(t, h)
+			}
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/profiles", strings.NewReader(tt.body)))
+			h.Wait()
+
+			if w.Code != tt.want {
+				t.Errorf("POST /debug/profiles %s = %d, want %d: %s", tt.body, w.Code, tt.want, w.Body)
+			}
+
+			if w.Code == http.StatusInternalServerError && h.capturing.Load() {
+				t.Error("a failed capture still holds the capture slot")
+			}
+		})
+	}
+}
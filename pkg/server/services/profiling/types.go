@@ -0,0 +1,51 @@
+package profiling
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+)
+
+// The kinds of profile that can be captured to the profiles folder
+const (
+	ProfileCPU  = "cpu"
+	ProfileHeap = "heap"
+)
+
+// How long a capture runs when the request does not give a duration
+const defaultCaptureDuration = 30 * time.Second
+
+// Captured profiles are named after their kind and start time so they sort in the order they were taken
+const (
+	fileTimeFormat = "20060102-150405"
+	fileExtension  = ".pprof"
+)
+
+type Handler struct {
+	ctx      context.Context
+	wg       sync.WaitGroup
+	settings config.ProfilingConfig
+
+	// only one capture runs at a time, the CPU profiler can not run twice
+	capturing atomic.Bool
+}
+
+type captureRequest struct {
+	Type     string          `json:"type"`
+	Duration config.Duration `json:"duration"`
+}
+
+type captureResponse struct {
+	Type     string          `json:"type"`
+	Duration config.Duration `json:"duration"`
+	Files    []string        `json:"files"`
+}
+
+type profileResponse struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
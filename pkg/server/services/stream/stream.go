@@ -0,0 +1,123 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/pkg/events"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/KyleBrandon/scriptoria/pkg/utils"
+	"github.com/google/uuid"
+)
+
+func NewHandler(mux *http.ServeMux, source EventSource) *Handler {
+	h := &Handler{}
+	h.source = source
+	h.RegisterRoutes(mux)
+
+	return h
+}
+
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /v1/events", h.handlerEventsGet)
+}
+
+// handlerEventsGet will stream the document lifecycle events as server-sent events.  The events can be filtered
+// with the bundle and document_id query parameters, and a client that reconnects with a Last-Event-ID header
+// receives the events it missed.
+func (h *Handler) handlerEventsGet(w http.ResponseWriter, r *http.Request) {
+	defer logging.SpanContext(r.Context(), "handlerEventsGet")()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Streaming is not supported", errors.New("response writer is not a flusher"))
+		return
+	}
+
+	filter := events.Filter{Bundle: r.URL.Query().Get("bundle")}
+	if value := r.URL.Query().Get("document_id"); len(value) != 0 {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid document ID", err)
+			return
+		}
+
+		filter.DocumentID = id
+	}
+
+	// browsers send the header when they reconnect, other clients can use the query parameter
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if len(lastEventID) == 0 {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	var since uint64
+	if len(lastEventID) != 0 {
+		var err error
+		since, err = strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID", err)
+			return
+		}
+	}
+
+	sub, backlog := h.source.Subscribe(filter, since)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", retryInterval.Milliseconds())
+	for _, e := range backlog {
+		if err := writeEvent(w, e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case e, ok := <-sub.Events():
+			// the server is shutting down or the client fell behind, it reconnects with the last event ID
+			if !ok {
+				return
+			}
+
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e events.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		slog.Error("Failed to encode the event", "id", e.ID, "error", err)
+		return nil
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
@@ -0,0 +1,22 @@
+package stream
+
+import (
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/pkg/events"
+)
+
+// How often a comment is sent so proxies do not close an idle stream
+const keepAliveInterval = 15 * time.Second
+
+// How long the browser waits before reconnecting to a closed stream
+const retryInterval = 3 * time.Second
+
+// EventSource is used to subscribe to the document lifecycle events
+type EventSource interface {
+	Subscribe(filter events.Filter, lastEventID uint64) (*events.Subscription, []events.Event)
+}
+
+type Handler struct {
+	source EventSource
+}
@@ -0,0 +1,191 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/internal/database"
+	"github.com/KyleBrandon/scriptoria/pkg/logging"
+	"github.com/KyleBrandon/scriptoria/pkg/metrics"
+)
+
+// NewLedger will create a ledger that enforces the budgets of the limits
+func NewLedger(store LedgerStore, limits config.LimitsConfig) *Ledger {
+	return &Ledger{
+		store:  store,
+		limits: limits,
+		paused: make(map[string]*BudgetError),
+	}
+}
+
+// SetLimits will change the budgets, a paused stage notices the change on its next check
+func (l *Ledger) SetLimits(limits config.LimitsConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limits = limits
+}
+
+// Record will add what was counted on the meter to the usage of the day.  Days are in UTC.
+func (l *Ledger) Record(ctx context.Context, m *Meter) error {
+	day := startOfDay(time.Now())
+
+	var errs []error
+	for _, a := range m.Amounts() {
+		err := l.store.AddUsage(ctx, database.AddUsageParams{
+			Day:      day,
+			Provider: a.Provider,
+			Unit:     a.Unit,
+			Amount:   a.Amount,
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Check returns a BudgetError if the daily or monthly budget of the provider is used up
+func (l *Ledger) Check(ctx context.Context, provider string) error {
+	l.mu.Lock()
+	limits := l.limits.Provider(provider)
+	l.mu.Unlock()
+
+	units, ok := budgetUnits[provider]
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+	today := startOfDay(now)
+	budgets := []struct {
+		period string
+		budget int64
+		from   time.Time
+		resets time.Time
+	}{
+		{PeriodDaily, limits.DailyBudget, today, today.AddDate(0, 0, 1)},
+		{PeriodMonthly, limits.MonthlyBudget, today.AddDate(0, 0, 1-today.Day()), today.AddDate(0, 1, 1-today.Day())},
+	}
+
+	for _, b := range budgets {
+		if b.budget == 0 {
+			continue
+		}
+
+		used, err := l.store.GetUsageTotal(ctx, database.GetUsageTotalParams{
+			Provider: provider,
+			Units:    units.units,
+			FromDay:  b.from,
+			ToDay:    today,
+		})
+		if err != nil {
+			return err
+		}
+
+		if used >= b.budget {
+			return &BudgetError{
+				Provider: provider,
+				Period:   b.period,
+				Unit:     units.name,
+				Used:     used,
+				Budget:   b.budget,
+				ResetsAt: b.resets,
+			}
+		}
+	}
+
+	return nil
+}
+
+// Wait will block while the budget of the provider is used up.  The first time the document has to wait, paused
+// is called with the reason.  The budget is checked again every minute so a new day, a new month or a larger
+// budget from a reload lets the document continue.  A failure to read the ledger does not hold the document.
+func (l *Ledger) Wait(ctx context.Context, provider string, paused func(*BudgetError)) error {
+	notified := false
+	for {
+		err := l.Check(ctx, provider)
+
+		var budgetErr *BudgetError
+		if !errors.As(err, &budgetErr) {
+			if err != nil {
+				logging.FromContext(ctx).Warn("Failed to check the budget", "provider", provider, "error", err)
+			}
+
+			l.resume(provider)
+			return nil
+		}
+
+		l.pause(budgetErr)
+		if !notified {
+			notified = true
+			paused(budgetErr)
+		}
+
+		timer := time.NewTimer(budgetCheckInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Paused returns the providers whose budget is used up
+func (l *Ledger) Paused() []BudgetError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	paused := make([]BudgetError, 0, len(l.paused))
+	for _, e := range l.paused {
+		paused = append(paused, *e)
+	}
+
+	slices.SortFunc(paused, func(a, b BudgetError) int {
+		return strings.Compare(a.Provider, b.Provider)
+	})
+
+	return paused
+}
+
+func (l *Ledger) pause(err *BudgetError) {
+	l.mu.Lock()
+	_, ok := l.paused[err.Provider]
+	l.paused[err.Provider] = err
+	l.mu.Unlock()
+
+	if !ok {
+		metrics.BudgetExhausted.WithLabelValues(err.Provider).Set(1)
+		slog.Warn("Pausing the stage until the budget has room", "provider", err.Provider, "error", err)
+	}
+}
+
+func (l *Ledger) resume(provider string) {
+	l.mu.Lock()
+	_, ok := l.paused[provider]
+	delete(l.paused, provider)
+	l.mu.Unlock()
+
+	if ok {
+		metrics.BudgetExhausted.WithLabelValues(provider).Set(0)
+		slog.Info("The budget has room again, resuming the stage", "provider", provider)
+	}
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("the %s %s budget of %d %s is used up (%d used), it resets at %s",
+		e.Period, e.Provider, e.Budget, e.Unit, e.Used, e.ResetsAt.Format("2006-01-02 15:04 MST"))
+}
+
+// startOfDay returns midnight UTC of the day
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,60 @@
+package usage
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+)
+
+// WithMeter returns a context that collects the usage added while a stage runs
+func WithMeter(ctx context.Context) (context.Context, *Meter) {
+	m := &Meter{amounts: make(map[amountKey]int64)}
+
+	return context.WithValue(ctx, contextKey{}, m), m
+}
+
+// Add will count the amount on the meter of the context, nothing is counted without a meter
+func Add(ctx context.Context, provider, unit string, amount int64) {
+	m, ok := ctx.Value(contextKey{}).(*Meter)
+	if !ok || amount == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.amounts[amountKey{provider: provider, unit: unit}] += amount
+}
+
+// Amounts returns what was counted, sorted by provider and unit
+func (m *Meter) Amounts() []Amount {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	amounts := make([]Amount, 0, len(m.amounts))
+	for k, v := range m.amounts {
+		amounts = append(amounts, Amount{Provider: k.provider, Unit: k.unit, Amount: v})
+	}
+
+	slices.SortFunc(amounts, func(a, b Amount) int {
+		return cmp.Or(cmp.Compare(a.Provider, b.Provider), cmp.Compare(a.Unit, b.Unit))
+	})
+
+	return amounts
+}
+
+// Cost returns what the amount costs at the prices, units without a price cost nothing
+func Cost(prices config.PricesConfig, provider, unit string, amount int64) float64 {
+	switch {
+	case provider == ProviderMathpix && unit == UnitPages:
+		return float64(amount) * prices.MathpixPerPage
+	case provider == ProviderOpenAI && unit == UnitPromptTokens:
+		return float64(amount) * prices.OpenAIPerMillionPromptTokens / 1_000_000
+	case provider == ProviderOpenAI && unit == UnitCompletionTokens:
+		return float64(amount) * prices.OpenAIPerMillionCompletionTokens / 1_000_000
+	default:
+		return 0
+	}
+}
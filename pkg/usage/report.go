@@ -0,0 +1,102 @@
+package usage
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/internal/database"
+)
+
+// MonthRange will parse the first and last month of a report, both in the YYYY-MM form and both included.  The
+// report covers the last twelve months when they are empty.  The returned times are the start of the first
+// month and the start of the month after the last, in UTC.
+func MonthRange(from, to string, now time.Time) (time.Time, time.Time, error) {
+	end := time.Date(now.UTC().Year(), now.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	if len(to) != 0 {
+		t, err := time.Parse(monthLayout, to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid month %q, expected YYYY-MM", to)
+		}
+		end = t
+	}
+
+	start := end.AddDate(0, 1-defaultReportMonths, 0)
+	if len(from) != 0 {
+		t, err := time.Parse(monthLayout, from)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid month %q, expected YYYY-MM", from)
+		}
+		start = t
+	}
+
+	if start.After(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("the first month %s is after the last month %s", start.Format(monthLayout), end.Format(monthLayout))
+	}
+
+	return start, end.AddDate(0, 1, 0), nil
+}
+
+// FilterBundle will keep the rows of the bundle with the name or source folder, every row is kept when it is empty
+func FilterBundle(rows []database.GetUsageReportRow, bundle string) []database.GetUsageReportRow {
+	if len(bundle) == 0 {
+		return rows
+	}
+
+	filtered := make([]database.GetUsageReportRow, 0, len(rows))
+	for _, r := range rows {
+		if r.BundleName == bundle || r.SourceFolderID == bundle {
+			filtered = append(filtered, r)
+		}
+	}
+
+	return filtered
+}
+
+// NewReport will work out the cost of the usage rows, which are sorted by month and bundle
+func NewReport(rows []database.GetUsageReportRow, prices config.PricesConfig, from, to time.Time) Report {
+	report := Report{
+		From:     from.Format(monthLayout),
+		To:       to.AddDate(0, -1, 0).Format(monthLayout),
+		Currency: prices.Currency,
+		Months:   make([]BundleMonth, 0),
+	}
+
+	for _, r := range rows {
+		n := len(report.Months)
+		if n == 0 || report.Months[n-1].Month != r.Month || report.Months[n-1].SourceFolder != r.SourceFolderID {
+			report.Months = append(report.Months, BundleMonth{
+				Month:        r.Month,
+				Bundle:       r.BundleName,
+				SourceFolder: r.SourceFolderID,
+				Items:        make([]ReportItem, 0),
+			})
+			n++
+		}
+
+		cost := Cost(prices, r.Provider, r.Unit, r.Amount)
+		month := &report.Months[n-1]
+		month.Items = append(month.Items, ReportItem{
+			Provider:  r.Provider,
+			Unit:      r.Unit,
+			Amount:    r.Amount,
+			Documents: r.Documents,
+			Cost:      RoundCost(cost),
+		})
+		month.Cost += cost
+		report.Cost += cost
+	}
+
+	for i := range report.Months {
+		report.Months[i].Cost = RoundCost(report.Months[i].Cost)
+	}
+	report.Cost = RoundCost(report.Cost)
+
+	return report
+}
+
+// RoundCost will round the cost to a hundredth of a cent
+func RoundCost(cost float64) float64 {
+	return math.Round(cost*10000) / 10000
+}
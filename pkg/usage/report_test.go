@@ -0,0 +1,158 @@
+package usage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/internal/database"
+)
+
+func TestMonthRange(t *testing.T) {
+	// late in the day so the local time zone of a caller could be in the next month
+	now := time.Date(2024, time.March, 31, 23, 30, 0, 0, time.FixedZone("UTC-5", -5*60*60))
+
+	tests := []struct {
+		name      string
+		from      string
+		to        string
+		wantStart string
+		wantEnd   string
+		wantErr   bool
+	}{
+		{name: "last twelve months", wantStart: "2023-05", wantEnd: "2024-05"},
+		{name: "up to a month", to: "2023-12", wantStart: "2023-01", wantEnd: "2024-01"},
+		{name: "from a month", from: "2024-02", wantStart: "2024-02", wantEnd: "2024-05"},
+		{name: "single month", from: "2023-07", to: "2023-07", wantStart: "2023-07", wantEnd: "2023-08"},
+		{name: "across a year", from: "2023-11", to: "2024-02", wantStart: "2023-11", wantEnd: "2024-03"},
+		{name: "first month after the last", from: "2024-03", to: "2024-01", wantErr: true},
+		{name: "invalid first month", from: "2024-3", wantErr: true},
+		{name: "invalid last month", to: "March", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := MonthRange(tt.from, tt.to, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MonthRange(%q, %q) error = %v, want error %v", tt.from, tt.to, err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if start.Format(monthLayout) != tt.wantStart || end.Format(monthLayout) != tt.wantEnd {
+				t.Errorf("MonthRange(%q, %q) = %s, %s, want %s, %s",
+					tt.from, tt.to, start.Format(monthLayout), end.Format(monthLayout), tt.wantStart, tt.wantEnd)
+			}
+
+			if start.Location() != time.UTC || start.Day() != 1 || start.Hour() != 0 {
+				t.Errorf("start %s is not the start of a month in UTC", start)
+			}
+		})
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	prices := config.PricesConfig{
+		Currency:                         "USD",
+		MathpixPerPage:                   0.005,
+		OpenAIPerMillionPromptTokens:     2.5,
+		OpenAIPerMillionCompletionTokens: 10,
+	}
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		rows []database.GetUsageReportRow
+		want Report
+	}{
+		{
+			name: "no usage",
+			want: Report{From: "2024-01", To: "2024-02", Currency: "USD", Months: []BundleMonth{}},
+		},
+		{
+			name: "rows are grouped by month and bundle",
+			rows: []database.GetUsageReportRow{
+				{SourceFolderID: "a", BundleName: "notes", Month: "2024-01", Provider: ProviderMathpix, Unit: UnitPages, Amount: 10, Documents: 2},
+				{SourceFolderID: "a", BundleName: "notes", Month: "2024-01", Provider: ProviderOpenAI, Unit: UnitPromptTokens, Amount: 200_000, Documents: 2},
+				{SourceFolderID: "b", BundleName: "papers", Month: "2024-01", Provider: ProviderOpenAI, Unit: UnitCompletionTokens, Amount: 1000, Documents: 1},
+				{SourceFolderID: "a", BundleName: "notes", Month: "2024-02", Provider: ProviderMathpix, Unit: UnitPages, Amount: 3, Documents: 1},
+			},
+			want: Report{
+				From:     "2024-01",
+				To:       "2024-02",
+				Currency: "USD",
+				Cost:     0.575,
+				Months: []BundleMonth{
+					{
+						Month:        "2024-01",
+						Bundle:       "notes",
+						SourceFolder: "a",
+						Cost:         0.55,
+						Items: []ReportItem{
+							{Provider: ProviderMathpix, Unit: UnitPages, Amount: 10, Documents: 2, Cost: 0.05},
+							{Provider: ProviderOpenAI, Unit: UnitPromptTokens, Amount: 200_000, Documents: 2, Cost: 0.5},
+						},
+					},
+					{
+						Month:        "2024-01",
+						Bundle:       "papers",
+						SourceFolder: "b",
+						Cost:         0.01,
+						Items: []ReportItem{
+							{Provider: ProviderOpenAI, Unit: UnitCompletionTokens, Amount: 1000, Documents: 1, Cost: 0.01},
+						},
+					},
+					{
+						Month:        "2024-02",
+						Bundle:       "notes",
+						SourceFolder: "a",
+						Cost:         0.015,
+						Items: []ReportItem{
+							{Provider: ProviderMathpix, Unit: UnitPages, Amount: 3, Documents: 1, Cost: 0.015},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "costs are rounded after they are added up",
+			rows: []database.GetUsageReportRow{
+				{SourceFolderID: "a", BundleName: "notes", Month: "2024-01", Provider: ProviderOpenAI, Unit: UnitPromptTokens, Amount: 30, Documents: 1},
+				{SourceFolderID: "a", BundleName: "notes", Month: "2024-01", Provider: ProviderOpenAI, Unit: UnitCompletionTokens, Amount: 10, Documents: 1},
+				{SourceFolderID: "a", BundleName: "notes", Month: "2024-01", Provider: "other", Unit: "calls", Amount: 5, Documents: 1},
+			},
+			want: Report{
+				From:     "2024-01",
+				To:       "2024-02",
+				Currency: "USD",
+				Cost:     0.0002,
+				Months: []BundleMonth{
+					{
+						Month:        "2024-01",
+						Bundle:       "notes",
+						SourceFolder: "a",
+						Cost:         0.0002,
+						Items: []ReportItem{
+							{Provider: ProviderOpenAI, Unit: UnitPromptTokens, Amount: 30, Documents: 1, Cost: 0.0001},
+							{Provider: ProviderOpenAI, Unit: UnitCompletionTokens, Amount: 10, Documents: 1, Cost: 0.0001},
+							{Provider: "other", Unit: "calls", Amount: 5, Documents: 1, Cost: 0},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewReport(tt.rows, prices, from, to)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewReport() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package usage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/KyleBrandon/scriptoria/internal/config"
+	"github.com/KyleBrandon/scriptoria/internal/database"
+)
+
+// The external providers that are metered, the names match the provider label of the metrics
+const (
+	ProviderMathpix = "mathpix"
+	ProviderOpenAI  = "openai"
+)
+
+// The units usage is recorded in
+const (
+	UnitPages            = "pages"
+	UnitPromptTokens     = "prompt_tokens"
+	UnitCompletionTokens = "completion_tokens"
+)
+
+// The periods a budget covers
+const (
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+)
+
+// The layout of the months in the usage report
+const monthLayout = "2006-01"
+
+// The number of months the usage report covers when no range is given
+const defaultReportMonths = 12
+
+// How often a paused stage checks if the budget has room again
+const budgetCheckInterval = time.Minute
+
+// budgetUnits are the units each provider's budget is counted in, and the name of the budget unit
+var budgetUnits = map[string]struct {
+	name  string
+	units []string
+}{
+	ProviderMathpix: {name: "pages", units: []string{UnitPages}},
+	ProviderOpenAI:  {name: "tokens", units: []string{UnitPromptTokens, UnitCompletionTokens}},
+}
+
+type (
+	contextKey struct{}
+
+	// amountKey is a unit of a provider
+	amountKey struct {
+		provider string
+		unit     string
+	}
+
+	// Amount is how much of a unit was used from a provider
+	Amount struct {
+		Provider string `json:"provider"`
+		Unit     string `json:"unit"`
+		Amount   int64  `json:"amount"`
+	}
+
+	// Meter collects what a stage used from the providers while it processed a document
+	Meter struct {
+		mu      sync.Mutex
+		amounts map[amountKey]int64
+	}
+
+	// LedgerStore is used to keep the usage of each day
+	LedgerStore interface {
+		AddUsage(ctx context.Context, arg database.AddUsageParams) error
+		GetUsageTotal(ctx context.Context, arg database.GetUsageTotalParams) (int64, error)
+	}
+
+	// Ledger keeps the usage of the providers per day and holds back the stages whose budget is used up
+	Ledger struct {
+		store LedgerStore
+
+		mu     sync.Mutex
+		limits config.LimitsConfig
+		paused map[string]*BudgetError
+	}
+
+	// Report is the usage and cost of each bundle per month
+	Report struct {
+		From     string        `json:"from"`
+		To       string        `json:"to"`
+		Currency string        `json:"currency"`
+		Cost     float64       `json:"cost"`
+		Months   []BundleMonth `json:"months"`
+	}
+
+	// BundleMonth is what the documents of a bundle used in a month
+	BundleMonth struct {
+		Month        string       `json:"month"`
+		Bundle       string       `json:"bundle"`
+		SourceFolder string       `json:"source_folder"`
+		Cost         float64      `json:"cost"`
+		Items        []ReportItem `json:"items"`
+	}
+
+	// ReportItem is the amount of a unit of a provider and the number of documents that used it
+	ReportItem struct {
+		Provider  string  `json:"provider"`
+		Unit      string  `json:"unit"`
+		Amount    int64   `json:"amount"`
+		Documents int64   `json:"documents"`
+		Cost      float64 `json:"cost"`
+	}
+
+	// BudgetError is returned when the daily or monthly budget of a provider is used up
+	BudgetError struct {
+		Provider string    `json:"provider"`
+		Period   string    `json:"period"`
+		Unit     string    `json:"unit"`
+		Used     int64     `json:"used"`
+		Budget   int64     `json:"budget"`
+		ResetsAt time.Time `json:"resets_at"`
+	}
+)